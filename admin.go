@@ -0,0 +1,218 @@
+package sum
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAdminUnauthorized is returned by admin authentication that rejects a request.
+var ErrAdminUnauthorized = errors.New("admin: unauthorized")
+
+// AdminOption configures the admin server.
+type AdminOption func(*Admin)
+
+// WithAdminAuth rejects admin requests for which check returns an error.
+// Rejected requests receive 401 Unauthorized.
+func WithAdminAuth(check func(*http.Request) error) AdminOption {
+	return func(a *Admin) {
+		a.auth = check
+	}
+}
+
+// WithAdminToken requires admin requests to carry "Authorization: Bearer <token>".
+func WithAdminToken(token string) AdminOption {
+	return WithAdminAuth(func(r *http.Request) error {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return ErrAdminUnauthorized
+		}
+		return nil
+	})
+}
+
+// WithAdminMiddleware wraps every admin endpoint, e.g. for request logging.
+// Middleware runs after authentication.
+func WithAdminMiddleware(mw func(http.Handler) http.Handler) AdminOption {
+	return func(a *Admin) {
+		a.middleware = append(a.middleware, mw)
+	}
+}
+
+// WithAdminProfiling serves net/http/pprof under /debug/pprof/. Profiles
+// expose command lines and memory contents, so pair it with WithAdminAuth or
+// WithAdminToken unless the admin port is otherwise private.
+func WithAdminProfiling() AdminOption {
+	return func(a *Admin) {
+		a.profiling = true
+	}
+}
+
+// Admin is a separately bound HTTP server for operational endpoints.
+// It starts and stops with the Service, so health, metrics and profiling are
+// never exposed on the public port.
+type Admin struct {
+	checks     map[string]func(context.Context) error
+	auth       func(*http.Request) error
+	mux        *http.ServeMux
+	server     *http.Server
+	listener   net.Listener
+	addr       string
+	middleware []func(http.Handler) http.Handler
+	profiling  bool
+	mu         sync.Mutex
+}
+
+// WithAdmin configures the admin server on host and port. It serves:
+//
+//   - GET /healthz: runs health checks, 503 if any fail
+//   - GET /metrics: the Prometheus text-format endpoint
+//   - GET /catalog: data resources as JSON (see Service.Resources)
+//   - /debug/pprof/: runtime profiling, with WithAdminProfiling
+//
+// Every endpoint, profiling included, sits behind the admin authentication.
+// Use Admin to register health checks and further endpoints.
+// Port 0 binds a free port; Admin().Addr reports it once started.
+func (s *Service) WithAdmin(host string, port int, opts ...AdminOption) *Service {
+	a := &Admin{
+		checks: make(map[string]func(context.Context) error),
+		mux:    http.NewServeMux(),
+		addr:   net.JoinHostPort(host, strconv.Itoa(port)),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.mux.HandleFunc("GET /healthz", a.health)
+	a.mux.Handle("GET /metrics", s.metricsHandler())
+	a.mux.Handle("GET /catalog", s.catalogHandler())
+	if a.profiling {
+		a.mux.HandleFunc("/debug/pprof/", pprof.Index)
+		a.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		a.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		a.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		a.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	s.mu.Lock()
+	s.admin = a
+	s.mu.Unlock()
+	return s
+}
+
+// Admin returns the admin server, or nil if WithAdmin was not called.
+func (s *Service) Admin() *Admin {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.admin
+}
+
+// Handle registers an endpoint on the admin server.
+func (a *Admin) Handle(pattern string, h http.Handler) {
+	a.mux.Handle(pattern, h)
+}
+
+// Check registers a named health check run by GET /healthz.
+func (a *Admin) Check(name string, fn func(context.Context) error) {
+	a.mu.Lock()
+	a.checks[name] = fn
+	a.mu.Unlock()
+}
+
+// Addr returns the bound address once started, or the configured one before.
+func (a *Admin) Addr() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.listener != nil {
+		return a.listener.Addr().String()
+	}
+	return a.addr
+}
+
+// ServeHTTP authenticates the request and dispatches it to an admin endpoint.
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.auth != nil {
+		if err := a.auth(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+	var h http.Handler = a.mux
+	for i := len(a.middleware) - 1; i >= 0; i-- {
+		h = a.middleware[i](h)
+	}
+	h.ServeHTTP(w, r)
+}
+
+// healthReport is the GET /healthz response.
+type healthReport struct {
+	Checks map[string]string `json:"checks,omitempty"`
+	Status string            `json:"status"`
+}
+
+// health runs every registered check.
+func (a *Admin) health(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	names := make([]string, 0, len(a.checks))
+	checks := make(map[string]func(context.Context) error, len(a.checks))
+	for name, fn := range a.checks {
+		names = append(names, name)
+		checks[name] = fn
+	}
+	a.mu.Unlock()
+	sort.Strings(names)
+
+	report := healthReport{Status: "ok"}
+	code := http.StatusOK
+	if len(names) > 0 {
+		report.Checks = make(map[string]string, len(names))
+	}
+	for _, name := range names {
+		if err := checks[name](r.Context()); err != nil {
+			report.Checks[name] = err.Error()
+			report.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+			continue
+		}
+		report.Checks[name] = "ok"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// start binds the admin listener and serves in the background.
+// Binding happens synchronously so address errors fail Start.
+func (a *Admin) start() error {
+	ln, err := net.Listen("tcp", a.addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: a, ReadHeaderTimeout: 10 * time.Second}
+	a.mu.Lock()
+	a.listener = ln
+	a.server = srv
+	a.mu.Unlock()
+	go func() { _ = srv.Serve(ln) }()
+	return nil
+}
+
+// shutdown stops the admin server, waiting for in-flight requests.
+func (a *Admin) shutdown(ctx context.Context) error {
+	a.mu.Lock()
+	srv := a.server
+	a.server = nil
+	a.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
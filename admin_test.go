@@ -0,0 +1,138 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func adminRequest(t *testing.T, a *Admin, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	a := New().WithAdmin("127.0.0.1", 0, WithAdminToken("secret")).Admin()
+
+	if rec := adminRequest(t, a, "/healthz", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without token, got %d", rec.Code)
+	}
+	if rec := adminRequest(t, a, "/healthz", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with wrong token, got %d", rec.Code)
+	}
+	if rec := adminRequest(t, a, "/healthz", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 with token, got %d", rec.Code)
+	}
+}
+
+func TestAdminHealthChecks(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	a := New().WithAdmin("127.0.0.1", 0).Admin()
+	a.Check("db", func(context.Context) error { return nil })
+	a.Check("cache", func(context.Context) error { return errors.New("connection refused") })
+
+	rec := adminRequest(t, a, "/healthz", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	var report healthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if report.Status != "unavailable" || report.Checks["db"] != "ok" || report.Checks["cache"] != "connection refused" {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestAdminServesOperationalEndpoints(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	svc := New().WithAdmin("127.0.0.1", 0)
+	a := svc.Admin()
+	a.Handle("GET /custom", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("custom"))
+	}))
+
+	if rec := adminRequest(t, a, "/metrics", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "# TYPE sum_events_total counter") {
+		t.Errorf("unexpected metrics response %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := adminRequest(t, a, "/debug/pprof/", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected pprof off by default, got %d", rec.Code)
+	}
+	if rec := adminRequest(t, a, "/custom", ""); rec.Body.String() != "custom" {
+		t.Errorf("expected custom endpoint, got %q", rec.Body.String())
+	}
+
+	// Operational endpoints stay off the public engine.
+	rec := httptest.NewRecorder()
+	svc.route(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected metrics absent from the engine, got %d", rec.Code)
+	}
+}
+
+func TestAdminProfiling(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	a := New().WithAdmin("127.0.0.1", 0, WithAdminProfiling(), WithAdminToken("secret")).Admin()
+
+	if rec := adminRequest(t, a, "/debug/pprof/", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected pprof behind admin auth, got %d", rec.Code)
+	}
+	if rec := adminRequest(t, a, "/debug/pprof/", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("expected pprof index, got %d", rec.Code)
+	}
+}
+
+func TestAdminStopsWhenStartFails(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	svc := New().WithAdmin("127.0.0.1", 0)
+	if err := svc.Start("127.0.0.1:1", 0); err == nil {
+		t.Fatal("expected Start to fail on a bad engine address")
+	}
+	if _, err := http.Get("http://" + svc.Admin().Addr() + "/healthz"); err == nil {
+		t.Error("expected admin server stopped after a failed Start")
+	}
+}
+
+func TestAdminLifecycle(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	svc := New().WithAdmin("127.0.0.1", 0)
+	a := svc.Admin()
+	if err := a.start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	resp, err := http.Get("http://" + a.Addr() + "/healthz")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+
+	if err := svc.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if _, err := http.Get("http://" + a.Addr() + "/healthz"); err == nil {
+		t.Error("expected admin server stopped after Shutdown")
+	}
+}
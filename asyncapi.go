@@ -0,0 +1,194 @@
+package sum
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/sentinel"
+)
+
+// EventSpec describes a registered event.
+type EventSpec struct {
+	Signal      string            `json:"signal"`
+	Description string            `json:"description"`
+	Severity    capitan.Severity  `json:"severity"`
+	Payload     sentinel.Metadata `json:"payload"`
+	Version     int               `json:"version,omitempty"`
+}
+
+// eventSpecs holds every event created with NewEvent, keyed by signal name.
+// Events are usually package-level variables, so the catalog outlives the
+// Service and is not cleared by Reset.
+var eventSpecs = struct {
+	specs map[string]EventSpec
+	mu    sync.RWMutex
+}{specs: make(map[string]EventSpec)}
+
+// registerEvent records an event in the catalog. When several events share
+// a signal, the one with the highest payload version describes it.
+func registerEvent(signal capitan.Signal, level capitan.Severity, meta sentinel.Metadata, version int) {
+	eventSpecs.mu.Lock()
+	defer eventSpecs.mu.Unlock()
+	if existing, ok := eventSpecs.specs[signal.Name()]; ok && existing.Version > version {
+		return
+	}
+	eventSpecs.specs[signal.Name()] = EventSpec{
+		Signal:      signal.Name(),
+		Description: signal.Description(),
+		Severity:    level,
+		Payload:     meta,
+		Version:     version,
+	}
+}
+
+// Events returns every registered event, sorted by signal name.
+func Events() []EventSpec {
+	eventSpecs.mu.RLock()
+	defer eventSpecs.mu.RUnlock()
+	out := make([]EventSpec, 0, len(eventSpecs.specs))
+	for _, spec := range eventSpecs.specs {
+		out = append(out, spec)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Signal < out[j].Signal })
+	return out
+}
+
+// AsyncAPI returns an AsyncAPI 2.6 document describing every registered event.
+// Each signal is a channel the application publishes on; its message payload is
+// a JSON Schema derived from the payload's sentinel metadata.
+func AsyncAPI(title, version string) map[string]any {
+	channels := make(map[string]any)
+	for _, spec := range Events() {
+		message := map[string]any{
+			"name":       spec.Payload.TypeName,
+			"title":      spec.Payload.FQDN,
+			"summary":    spec.Description,
+			"payload":    PayloadSchema(spec.Payload),
+			"x-severity": spec.Severity,
+		}
+		if spec.Version > 0 {
+			message["x-version"] = spec.Version
+		}
+		channels[spec.Signal] = map[string]any{
+			"description": spec.Description,
+			"subscribe": map[string]any{
+				"operationId": spec.Signal,
+				"message":     message,
+			},
+		}
+	}
+	return map[string]any{
+		"asyncapi": "2.6.0",
+		"info": map[string]any{
+			"title":   title,
+			"version": version,
+		},
+		"defaultContentType": "application/json",
+		"channels":           channels,
+	}
+}
+
+// PayloadSchema converts sentinel metadata into a JSON Schema object.
+// Property names follow json tags; desc and example tags become description
+// and examples, and the remaining tags are preserved under x-tags.
+func PayloadSchema(meta sentinel.Metadata) map[string]any {
+	props := make(map[string]any, len(meta.Fields))
+	var required []string
+	for _, f := range meta.Fields {
+		name := f.Name
+		omitempty := false
+		if tag, ok := f.Tags["json"]; ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" || opt == "omitzero" {
+					omitempty = true
+				}
+			}
+		}
+
+		prop := typeSchema(f.ReflectType)
+		if desc := f.Tags["desc"]; desc != "" {
+			prop["description"] = desc
+		}
+		if ex := f.Tags["example"]; ex != "" {
+			prop["examples"] = []string{ex}
+		}
+		if len(f.Tags) > 0 {
+			prop["x-tags"] = f.Tags
+		}
+		props[name] = prop
+
+		if !omitempty && f.ReflectType != nil && f.ReflectType.Kind() != reflect.Ptr {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{
+		"type":       "object",
+		"title":      meta.TypeName,
+		"properties": props,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// timeType is special-cased as a date-time string.
+var timeType = reflect.TypeOf(time.Time{})
+
+// typeSchema maps a Go type onto its JSON Schema shape.
+func typeSchema(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return map[string]any{"type": "object", "title": t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+// ServeEvents mounts the AsyncAPI document for registered events at path.
+// Serve it next to the engine's OpenAPI spec, e.g. "/asyncapi.json".
+func (s *Service) ServeEvents(path, title, version string) {
+	s.Mount("GET "+path, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(AsyncAPI(title, version))
+	}))
+}
@@ -0,0 +1,129 @@
+//go:build testing
+
+package sum
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/capitan"
+)
+
+type testCatalogPayload struct {
+	CreatedAt time.Time         `json:"created_at"`
+	Note      *string           `json:"note"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	ID        string            `json:"id" desc:"Order identifier" example:"ord_123"`
+	Internal  string            `json:"-"`
+	Items     []int             `json:"items"`
+	Total     float64           `json:"total"`
+}
+
+func TestNewEventRegistersInCatalog(t *testing.T) {
+	NewWarnEvent[testCatalogPayload](capitan.NewSignal("test.catalog.order", "Order flagged"))
+
+	var found *EventSpec
+	for _, spec := range Events() {
+		if spec.Signal == "test.catalog.order" {
+			found = &spec
+			break
+		}
+	}
+	if found == nil {
+		t.Fatal("event not registered in catalog")
+	}
+	if found.Description != "Order flagged" || found.Severity != capitan.SeverityWarn {
+		t.Errorf("unexpected spec %+v", found)
+	}
+	if found.Payload.TypeName != "testCatalogPayload" {
+		t.Errorf("expected payload metadata, got %q", found.Payload.TypeName)
+	}
+}
+
+func TestPayloadSchema(t *testing.T) {
+	NewInfoEvent[testCatalogPayload](capitan.NewSignal("test.catalog.schema", "Schema"))
+	var schema map[string]any
+	for _, spec := range Events() {
+		if spec.Signal == "test.catalog.schema" {
+			schema = PayloadSchema(spec.Payload)
+		}
+	}
+
+	props := schema["properties"].(map[string]any)
+	if _, ok := props["Internal"]; ok {
+		t.Error("json:\"-\" field should be omitted")
+	}
+
+	tests := []struct {
+		name string
+		typ  string
+	}{
+		{"id", "string"},
+		{"total", "number"},
+		{"items", "array"},
+		{"labels", "object"},
+		{"created_at", "string"},
+		{"note", "string"},
+	}
+	for _, tt := range tests {
+		prop, ok := props[tt.name].(map[string]any)
+		if !ok {
+			t.Errorf("missing property %q", tt.name)
+			continue
+		}
+		if prop["type"] != tt.typ {
+			t.Errorf("%s: expected type %q, got %v", tt.name, tt.typ, prop["type"])
+		}
+	}
+
+	id := props["id"].(map[string]any)
+	if id["description"] != "Order identifier" {
+		t.Errorf("expected desc tag as description, got %v", id["description"])
+	}
+	if props["created_at"].(map[string]any)["format"] != "date-time" {
+		t.Error("expected time.Time to be a date-time string")
+	}
+
+	required := schema["required"].([]string)
+	want := map[string]bool{"created_at": true, "id": true, "items": true, "total": true}
+	if len(required) != len(want) {
+		t.Errorf("expected required %v, got %v", want, required)
+	}
+	for _, r := range required {
+		if !want[r] {
+			t.Errorf("unexpected required field %q", r)
+		}
+	}
+}
+
+func TestServeEvents(t *testing.T) {
+	resetAll(t)
+	svc := New()
+
+	NewInfoEvent[testCatalogPayload](capitan.NewSignal("test.catalog.served", "Served"))
+	svc.ServeEvents("/asyncapi.json", "Orders", "1.0.0")
+
+	rec := httptest.NewRecorder()
+	svc.route(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/asyncapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var doc struct {
+		AsyncAPI string                    `json:"asyncapi"`
+		Info     map[string]string         `json:"info"`
+		Channels map[string]map[string]any `json:"channels"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if doc.AsyncAPI != "2.6.0" || doc.Info["title"] != "Orders" {
+		t.Errorf("unexpected document header %+v", doc)
+	}
+	if _, ok := doc.Channels["test.catalog.served"]; !ok {
+		t.Error("expected channel for registered event")
+	}
+}
@@ -1,6 +1,10 @@
 package sum
 
 import (
+	"context"
+	"encoding/json"
+	"reflect"
+
 	"github.com/zoobzio/cereal"
 	"github.com/zoobzio/rocco"
 )
@@ -28,7 +32,8 @@ type Boundary[T cereal.Cloner[T]] struct {
 }
 
 // NewBoundary creates a Boundary[T], applies shared capabilities from the Service,
-// and registers it in the service registry under the given key.
+// and registers it in the service registry under the given key. The logger
+// sink passes T payloads through the boundary's Send before writing them.
 func NewBoundary[T cereal.Cloner[T]](k Key) (*Boundary[T], error) {
 	s := svc()
 	proc, err := cereal.NewProcessor[T]()
@@ -52,10 +57,34 @@ func NewBoundary[T cereal.Cloner[T]](k Key) (*Boundary[T], error) {
 	s.mu.RUnlock()
 
 	b := &Boundary[T]{Processor: proc}
+	s.mu.Lock()
+	s.senders[reflect.TypeFor[T]()] = func(ctx context.Context, v any) (any, error) {
+		return proc.Send(ctx, v.(T))
+	}
+	s.mu.Unlock()
 	Register[*Boundary[T]](k, b)
 	return b, nil
 }
 
+// marshalOutbound encodes v for an external client. When a Boundary is
+// registered for T, v is passed through its Send first so masked, redacted
+// and encrypted fields leave as they would be sent.
+func marshalOutbound[T any](ctx context.Context, codec Codec, v T) ([]byte, error) {
+	s := current()
+	if s == nil {
+		return codec.Marshal(v)
+	}
+	send := s.sender(reflect.TypeFor[T]())
+	if send == nil {
+		return codec.Marshal(v)
+	}
+	out, err := send(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Marshal(out)
+}
+
 // roccoCodec adapts a cereal.Codec to rocco.Codec.
 type roccoCodec struct{ cereal.Codec }
 
@@ -64,3 +93,12 @@ var _ rocco.Codec = roccoCodec{}
 func (r roccoCodec) ContentType() string                { return r.Codec.ContentType() }
 func (r roccoCodec) Marshal(v any) ([]byte, error)      { return r.Codec.Marshal(v) }
 func (r roccoCodec) Unmarshal(data []byte, v any) error { return r.Codec.Unmarshal(data, v) }
+
+// jsonCodec is the fallback used where a codec is required but none is configured.
+type jsonCodec struct{}
+
+var _ cereal.Codec = jsonCodec{}
+
+func (jsonCodec) ContentType() string                { return "application/json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
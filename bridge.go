@@ -0,0 +1,337 @@
+package sum
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zoobzio/cereal"
+)
+
+// Bridge relays events between WebSocket clients and the application.
+// Each message is a signal name, a newline, then the payload encoded with the
+// Service codec. Clients may only send signals registered with BridgeInbound
+// and only receive signals registered with BridgeOutbound. Inbound messages
+// that fail are answered with a BridgeErrorFrame message.
+type Bridge struct {
+	codec     Codec
+	resolve   func(*http.Request) (Token, bool)
+	inbound   map[string]bridgeRoute
+	clients   map[*bridgeClient]struct{}
+	done      chan struct{}
+	listeners []*Listener
+	origins   []string
+	idle      time.Duration
+	closeOnce sync.Once
+	mu        sync.RWMutex
+}
+
+// BridgeOption configures a Bridge.
+type BridgeOption func(*Bridge)
+
+// WithBridgeToken sets how a connection's token is resolved from its upgrade request.
+// The token is placed in the context of every inbound emission and checked against
+// outbound guards.
+func WithBridgeToken(fn func(*http.Request) (Token, bool)) BridgeOption {
+	return func(b *Bridge) {
+		b.resolve = fn
+	}
+}
+
+// WithBridgeOrigins allows browser connections from the given origins, such
+// as "https://app.example.com", in addition to the bridge's own host. "*"
+// allows every origin; only use it when tokens are not resolved from cookies.
+func WithBridgeOrigins(origins ...string) BridgeOption {
+	return func(b *Bridge) {
+		b.origins = append(b.origins, origins...)
+	}
+}
+
+// WithBridgeIdleTimeout sets how long a connection may go without any
+// inbound frame before it is dropped. The bridge pings clients at half this
+// interval, so connected browsers stay alive. Zero disables the timeout.
+func WithBridgeIdleTimeout(d time.Duration) BridgeOption {
+	return func(b *Bridge) {
+		b.idle = d
+	}
+}
+
+// ErrUnknownSignal indicates a client sent a signal that is not bridged inbound.
+var ErrUnknownSignal = errors.New("unknown signal")
+
+// BridgeErrorFrame is the signal name of messages reporting an inbound
+// failure to the client; the payload is the error text. It is namespaced so
+// it cannot collide with an application signal.
+const BridgeErrorFrame = "sum.bridge.error"
+
+// Validator is implemented by payloads that check their own invariants.
+type Validator interface {
+	Validate() error
+}
+
+// bridgeRoute decodes and emits an inbound payload for a single signal.
+type bridgeRoute struct {
+	guard Guard
+	emit  func(ctx context.Context, data []byte) error
+}
+
+// bridgeClient is a single connected WebSocket peer.
+type bridgeClient struct {
+	ctx    context.Context
+	conn   *wsConn
+	out    chan []byte
+	kicked chan struct{}
+	once   sync.Once
+}
+
+// kick disconnects a client that cannot keep up with outbound traffic.
+func (c *bridgeClient) kick() {
+	c.once.Do(func() { close(c.kicked) })
+}
+
+// bridgeClientBuffer is the per-connection outbound queue depth.
+const bridgeClientBuffer = 64
+
+// bridgeIdleTimeout is the default idle timeout for bridge connections.
+const bridgeIdleTimeout = time.Minute
+
+// NewBridge creates a Bridge encoding with the Service codec.
+// Browser connections are only accepted from the bridge's own host unless
+// WithBridgeOrigins allows others. The bridge is closed automatically when
+// the Service shuts down.
+func NewBridge(opts ...BridgeOption) *Bridge {
+	s := svc()
+	b := &Bridge{
+		codec:   s.codecOrDefault(),
+		inbound: make(map[string]bridgeRoute),
+		clients: make(map[*bridgeClient]struct{}),
+		done:    make(chan struct{}),
+		idle:    bridgeIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	s.onShutdown(func(context.Context) error {
+		b.Close()
+		return nil
+	})
+	return b
+}
+
+// BridgeInbound accepts client messages for e, decoding them with the
+// bridge codec and applying boundary's receive actions, such as hashing.
+// Payloads implementing Validator are validated before emission.
+// If tokens are provided, only connections bearing one of them may emit.
+func BridgeInbound[T cereal.Cloner[T]](b *Bridge, e Event[T], boundary *Boundary[T], tokens ...Token) {
+	var guard Guard
+	if len(tokens) > 0 {
+		guard = Require(tokens...)
+	}
+	route := bridgeRoute{
+		guard: guard,
+		emit: func(ctx context.Context, data []byte) error {
+			var v T
+			if err := b.codec.Unmarshal(data, &v); err != nil {
+				return err
+			}
+			v, err := boundary.Receive(ctx, v)
+			if err != nil {
+				return err
+			}
+			if val, ok := any(v).(Validator); ok {
+				if err := val.Validate(); err != nil {
+					return err
+				}
+			}
+			e.Emit(ctx, v)
+			return nil
+		},
+	}
+	b.mu.Lock()
+	b.inbound[e.Signal.Name()] = route
+	b.mu.Unlock()
+}
+
+// BridgeOutbound forwards emissions of e to connected clients.
+// If tokens are provided, only connections bearing one of them receive the event.
+// Payloads with a Boundary are sent as its Send output.
+func BridgeOutbound[T any](b *Bridge, e Event[T], tokens ...Token) {
+	var guard Guard
+	if len(tokens) > 0 {
+		guard = Require(tokens...)
+	}
+	name := e.Signal.Name()
+	l := e.Listen(func(ctx context.Context, data T) {
+		encoded, err := marshalOutbound(ctx, b.codec, data)
+		if err != nil {
+			return
+		}
+		b.broadcast(bridgeFrame(name, encoded), guard)
+	})
+	if l == nil {
+		return
+	}
+	b.mu.Lock()
+	b.listeners = append(b.listeners, l)
+	b.mu.Unlock()
+}
+
+// bridgeFrame joins a signal name and payload into a wire message.
+func bridgeFrame(signal string, payload []byte) []byte {
+	msg := make([]byte, 0, len(signal)+1+len(payload))
+	msg = append(msg, signal...)
+	msg = append(msg, '\n')
+	return append(msg, payload...)
+}
+
+// broadcast queues a message for every client permitted by guard.
+func (b *Bridge) broadcast(msg []byte, guard Guard) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for c := range b.clients {
+		if guard != nil && guard(c.ctx) != nil {
+			continue
+		}
+		select {
+		case c.out <- msg:
+		default:
+			c.kick()
+		}
+	}
+}
+
+// ServeHTTP upgrades the connection and relays messages until either side closes.
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-b.done:
+		http.Error(w, "bridge closed", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+
+	ctx := context.WithoutCancel(r.Context())
+	if b.resolve != nil {
+		if t, ok := b.resolve(r); ok {
+			ctx = WithToken(ctx, t)
+		}
+	}
+
+	// Register before the handshake completes so nothing emitted after the
+	// client sees the upgrade response is missed.
+	c := &bridgeClient{
+		ctx:    ctx,
+		out:    make(chan []byte, bridgeClientBuffer),
+		kicked: make(chan struct{}),
+	}
+	b.mu.Lock()
+	b.clients[c] = struct{}{}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, c)
+		b.mu.Unlock()
+	}()
+
+	conn, err := wsUpgrade(w, r, b.origins)
+	if err != nil {
+		return
+	}
+	conn.idle = b.idle
+	c.conn = conn
+
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		b.readLoop(c)
+	}()
+
+	b.writeLoop(c, readDone)
+	_ = conn.Close()
+	<-readDone
+}
+
+// readLoop dispatches inbound messages until the connection fails.
+func (b *Bridge) readLoop(c *bridgeClient) {
+	for {
+		msg, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := b.dispatch(c.ctx, msg); err != nil {
+			select {
+			case c.out <- bridgeFrame(BridgeErrorFrame, []byte(err.Error())):
+			default:
+			}
+		}
+	}
+}
+
+// writeLoop drains the outbound queue until the client or bridge goes away.
+// Clients are pinged at half the idle timeout.
+func (b *Bridge) writeLoop(c *bridgeClient, readDone <-chan struct{}) {
+	var ping <-chan time.Time
+	if b.idle > 0 {
+		t := time.NewTicker(b.idle / 2)
+		defer t.Stop()
+		ping = t.C
+	}
+	for {
+		select {
+		case msg := <-c.out:
+			if err := c.conn.WriteText(msg); err != nil {
+				return
+			}
+		case <-ping:
+			if err := c.conn.Ping(); err != nil {
+				return
+			}
+		case <-readDone:
+			return
+		case <-c.kicked:
+			return
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// dispatch routes a single inbound message to its registered event.
+func (b *Bridge) dispatch(ctx context.Context, msg []byte) error {
+	name, payload, ok := bytes.Cut(msg, []byte{'\n'})
+	if !ok {
+		return fmt.Errorf("malformed message: missing signal")
+	}
+
+	b.mu.RLock()
+	route, ok := b.inbound[string(name)]
+	b.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSignal, name)
+	}
+	if route.guard != nil {
+		if err := route.guard(ctx); err != nil {
+			return err
+		}
+	}
+	return route.emit(ctx, payload)
+}
+
+// Close unregisters all listeners and disconnects every client.
+// Safe to call multiple times.
+func (b *Bridge) Close() {
+	b.closeOnce.Do(func() {
+		b.mu.Lock()
+		close(b.done)
+		listeners := b.listeners
+		b.listeners = nil
+		b.mu.Unlock()
+
+		for _, l := range listeners {
+			l.Close()
+		}
+	})
+}
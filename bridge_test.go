@@ -0,0 +1,388 @@
+//go:build testing
+
+package sum
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/cereal"
+)
+
+// testChatMessage is a Cloner payload that validates itself.
+type testChatMessage struct {
+	Text string `json:"text"`
+}
+
+func (m testChatMessage) Clone() testChatMessage { return m }
+
+func (m testChatMessage) Validate() error {
+	if m.Text == "" {
+		return errors.New("text required")
+	}
+	return nil
+}
+
+// testWSClient is a minimal masked-frame client for exercising the bridge.
+type testWSClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialBridge(t *testing.T, srv *httptest.Server, header http.Header) *testWSClient {
+	t.Helper()
+	resp, c := handshakeBridge(t, srv, header)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected accept key %q", got)
+	}
+	return c
+}
+
+// handshakeBridge sends an upgrade request with Host "test" and returns the
+// response without checking it.
+func handshakeBridge(t *testing.T, srv *httptest.Server, header http.Header) (*http.Response, *testWSClient) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	req := "GET / HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n"
+	for k, v := range header {
+		req += k + ": " + v[0] + "\r\n"
+	}
+	if _, err := conn.Write([]byte(req + "\r\n")); err != nil {
+		t.Fatalf("handshake write: %v", err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("handshake read: %v", err)
+	}
+	return resp, &testWSClient{conn: conn, r: r}
+}
+
+func (c *testWSClient) send(t *testing.T, msg string) {
+	t.Helper()
+	c.sendFrame(t, 0x80|wsText, msg)
+}
+
+// sendFrame writes one masked frame with the given first header byte.
+func (c *testWSClient) sendFrame(t *testing.T, head byte, msg string) {
+	t.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{head}
+	if len(msg) > 125 {
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(msg)))
+	} else {
+		frame = append(frame, 0x80|byte(len(msg)))
+	}
+	frame = append(frame, mask[:]...)
+	for i := 0; i < len(msg); i++ {
+		frame = append(frame, msg[i]^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+}
+
+func (c *testWSClient) read(t *testing.T) string {
+	t.Helper()
+	_ = c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		t.Fatalf("read header: %v", err)
+	}
+	length := int(head[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			t.Fatalf("read length: %v", err)
+		}
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	return string(payload)
+}
+
+func newTestBridge(t *testing.T, opts ...BridgeOption) (*Bridge, *httptest.Server) {
+	t.Helper()
+	b := NewBridge(opts...)
+	t.Cleanup(b.Close)
+	srv := httptest.NewServer(b)
+	t.Cleanup(srv.Close)
+	return b, srv
+}
+
+func TestBridgeInboundEmitsWithToken(t *testing.T) {
+	resetAll(t)
+	New().WithCodec(&testCodec{})
+	k := Start()
+
+	boundary, err := NewBoundary[testChatMessage](k)
+	if err != nil {
+		t.Fatalf("NewBoundary: %v", err)
+	}
+	user := NewToken("user")
+	event := NewInfoEvent[testChatMessage](capitan.NewSignal("test.bridge.inbound", "Bridge inbound"))
+
+	received := make(chan Token, 1)
+	l := event.Listen(func(ctx context.Context, m testChatMessage) {
+		tok, _ := tokenFromContext(ctx)
+		if m.Text == "hi" {
+			received <- tok
+		}
+	})
+	defer l.Close()
+
+	b, srv := newTestBridge(t, WithBridgeToken(func(*http.Request) (Token, bool) { return user, true }))
+	BridgeInbound(b, event, boundary, user)
+
+	c := dialBridge(t, srv, nil)
+	c.send(t, "test.bridge.inbound\n"+`{"text":"hi"}`)
+
+	select {
+	case tok := <-received:
+		if tok.id != user.id {
+			t.Errorf("expected connection token in emission context, got %v", tok)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for inbound emission")
+	}
+}
+
+func TestBridgeInboundWithoutServiceCodec(t *testing.T) {
+	resetAll(t)
+	New()
+	k := Start()
+
+	boundary, err := NewBoundary[testChatMessage](k)
+	if err != nil {
+		t.Fatalf("NewBoundary: %v", err)
+	}
+	event := NewInfoEvent[testChatMessage](capitan.NewSignal("test.bridge.default", "Bridge default codec"))
+	received := make(chan string, 1)
+	l := event.Listen(func(_ context.Context, m testChatMessage) { received <- m.Text })
+	defer l.Close()
+
+	b, srv := newTestBridge(t)
+	BridgeInbound(b, event, boundary)
+
+	c := dialBridge(t, srv, nil)
+	c.send(t, "test.bridge.default\n"+`{"text":"hi"}`)
+
+	select {
+	case got := <-received:
+		if got != "hi" {
+			t.Errorf("expected decoded payload, got %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for inbound emission with the default codec")
+	}
+}
+
+func TestBridgeInboundRejections(t *testing.T) {
+	resetAll(t)
+	New()
+	k := Start()
+
+	boundary, err := NewBoundary[testChatMessage](k)
+	if err != nil {
+		t.Fatalf("NewBoundary: %v", err)
+	}
+	admin := NewToken("admin")
+	event := NewInfoEvent[testChatMessage](capitan.NewSignal("test.bridge.reject", "Bridge reject"))
+	guarded := NewInfoEvent[testChatMessage](capitan.NewSignal("test.bridge.guarded", "Bridge guarded"))
+
+	b, srv := newTestBridge(t)
+	BridgeInbound(b, event, boundary)
+	BridgeInbound(b, guarded, boundary, admin)
+
+	c := dialBridge(t, srv, nil)
+
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{"unknown signal", "test.bridge.nope\n{}", "unknown signal"},
+		{"invalid payload", "test.bridge.reject\n" + `{"text":""}`, "text required"},
+		{"missing token", "test.bridge.guarded\n" + `{"text":"x"}`, "token required"},
+		{"malformed", "no-newline", "malformed message"},
+	}
+	for _, tt := range tests {
+		c.send(t, tt.msg)
+		got := c.read(t)
+		if !strings.HasPrefix(got, BridgeErrorFrame+"\n") || !strings.Contains(got, tt.want) {
+			t.Errorf("%s: expected error containing %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestBridgeOutbound(t *testing.T) {
+	resetAll(t)
+	New()
+
+	admin := NewToken("admin")
+	public := NewInfoEvent[testChatMessage](capitan.NewSignal("test.bridge.public", "Bridge public"))
+	secret := NewInfoEvent[testChatMessage](capitan.NewSignal("test.bridge.secret", "Bridge secret"))
+
+	b, srv := newTestBridge(t, WithBridgeToken(func(r *http.Request) (Token, bool) {
+		if r.Header.Get("X-Role") == "admin" {
+			return admin, true
+		}
+		return Token{}, false
+	}))
+	BridgeOutbound(b, public)
+	BridgeOutbound(b, secret, admin)
+
+	anon := dialBridge(t, srv, nil)
+	priv := dialBridge(t, srv, http.Header{"X-Role": {"admin"}})
+
+	ctx := context.Background()
+	secret.Emit(ctx, testChatMessage{Text: "secret"})
+	public.Emit(ctx, testChatMessage{Text: "public"})
+
+	if got := anon.read(t); got != "test.bridge.public\n"+`{"text":"public"}` {
+		t.Errorf("anonymous client got %q", got)
+	}
+	if got := priv.read(t); got != "test.bridge.secret\n"+`{"text":"secret"}` {
+		t.Errorf("admin client got %q", got)
+	}
+}
+
+func TestBridgeOutboundAppliesBoundary(t *testing.T) {
+	resetAll(t)
+	New().WithEncryptor(cereal.EncryptAES, stubEncryptor{}).WithHasher(cereal.HashSHA256, stubHasher{})
+
+	k := Start()
+	if _, err := NewBoundary[testLogAccount](k); err != nil {
+		t.Fatalf("NewBoundary: %v", err)
+	}
+	Freeze(k)
+
+	event := NewInfoEvent[testLogAccount](capitan.NewSignal("test.bridge.masked", "Bridge masked"))
+	b, srv := newTestBridge(t)
+	BridgeOutbound(b, event)
+
+	c := dialBridge(t, srv, nil)
+	event.Emit(context.Background(), testLogAccount{ID: "a1", Email: "alice@example.com", Token: "tok"})
+
+	got := c.read(t)
+	if strings.Contains(got, "alice@example.com") || !strings.Contains(got, `"token":"***"`) {
+		t.Errorf("expected boundary-masked payload, got %q", got)
+	}
+}
+
+func TestBridgeRejectsPlainRequest(t *testing.T) {
+	resetAll(t)
+	New()
+
+	_, srv := newTestBridge(t)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("expected 426, got %d", resp.StatusCode)
+	}
+}
+
+func TestBridgeRejectsInvalidFrames(t *testing.T) {
+	resetAll(t)
+	New()
+
+	_, srv := newTestBridge(t)
+
+	type frame struct {
+		head byte
+		msg  string
+	}
+	tests := []struct {
+		name   string
+		frames []frame
+		code   uint16
+	}{
+		{"reserved bits", []frame{{0x80 | 0x40 | wsText, "hi"}}, 1002},
+		{"fragmented control", []frame{{wsPing, "hi"}}, 1002},
+		{"oversized control", []frame{{0x80 | wsPing, strings.Repeat("x", 126)}}, 1002},
+		{"orphan continuation", []frame{{0x80 | wsContinuation, "hi"}}, 1002},
+		{"interleaved message", []frame{{wsText, "h"}, {0x80 | wsText, "i"}}, 1002},
+		{"invalid utf-8", []frame{{0x80 | wsText, "test.bridge.x\n\xff"}}, 1007},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := dialBridge(t, srv, nil)
+			for _, f := range tt.frames {
+				c.sendFrame(t, f.head, f.msg)
+			}
+			got := c.read(t)
+			if len(got) < 2 || binary.BigEndian.Uint16([]byte(got)) != tt.code {
+				t.Errorf("expected close %d, got %q", tt.code, got)
+			}
+		})
+	}
+}
+
+func TestBridgeChecksOrigin(t *testing.T) {
+	resetAll(t)
+	New()
+
+	_, srv := newTestBridge(t, WithBridgeOrigins("https://app.example.com"))
+
+	tests := []struct {
+		origin string
+		want   int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{"http://test", http.StatusSwitchingProtocols},
+		{"https://app.example.com", http.StatusSwitchingProtocols},
+		{"https://evil.example.com", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+		resp, _ := handshakeBridge(t, srv, header)
+		if resp.StatusCode != tt.want {
+			t.Errorf("origin %q: expected %d, got %d", tt.origin, tt.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestBridgeDropsIdleConnections(t *testing.T) {
+	resetAll(t)
+	New()
+
+	_, srv := newTestBridge(t, WithBridgeIdleTimeout(100*time.Millisecond))
+	c := dialBridge(t, srv, nil)
+
+	// The client never answers pings, so the connection goes idle.
+	_ = c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.Copy(io.Discard, c.r); err != nil {
+		t.Fatalf("expected the bridge to close the idle connection, got %v", err)
+	}
+}
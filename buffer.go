@@ -0,0 +1,252 @@
+package sum
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BufferedListener is a listener that holds emissions before delivering them.
+// Anything still buffered is delivered on Close, and every BufferedListener is
+// closed automatically when the Service shuts down.
+type BufferedListener struct {
+	listener *Listener
+	flush    func()
+	release  func()
+	once     sync.Once
+}
+
+// newBufferedListener wraps a capitan listener and registers it for shutdown.
+func newBufferedListener(l *Listener, flush func()) *BufferedListener {
+	b := &BufferedListener{listener: l, flush: flush}
+	b.release = svc().onShutdown(func(context.Context) error {
+		b.Close()
+		return nil
+	})
+	return b
+}
+
+// Flush delivers anything currently buffered without waiting for its window.
+func (b *BufferedListener) Flush() {
+	b.flush()
+}
+
+// Close unregisters the listener, waits for queued emissions, then delivers
+// whatever remains buffered. Safe to call multiple times.
+func (b *BufferedListener) Close() {
+	b.once.Do(func() {
+		if b.release != nil {
+			b.release()
+		}
+		if b.listener != nil {
+			b.listener.Close()
+		}
+		b.flush()
+	})
+}
+
+// ListenBatch delivers emissions in slices of up to size, flushing a partial
+// batch once window has passed since its first emission. A size of 0 batches
+// by window only; a window of 0 batches by size only. ListenBatch panics if
+// neither is positive, since nothing would ever deliver a batch.
+// The callback receives the context of the last emission in the batch.
+// Requires sum.New() to have been called first.
+func (e Event[T]) ListenBatch(size int, window time.Duration, callback func(context.Context, []T)) *BufferedListener {
+	if size <= 0 && window <= 0 {
+		panic("sum: ListenBatch needs a positive size or window")
+	}
+	b := &batcher[T]{size: size, window: window, callback: callback}
+	return newBufferedListener(e.Listen(b.add), b.flush)
+}
+
+// batcher accumulates emissions for ListenBatch.
+type batcher[T any] struct {
+	ctx      context.Context
+	callback func(context.Context, []T)
+	timer    *time.Timer
+	items    []T
+	size     int
+	window   time.Duration
+	mu       sync.Mutex
+	deliver  sync.Mutex
+}
+
+func (b *batcher[T]) add(ctx context.Context, data T) {
+	b.mu.Lock()
+	b.items = append(b.items, data)
+	b.ctx = context.WithoutCancel(ctx)
+	if b.size > 0 && len(b.items) >= b.size {
+		b.send()
+		return
+	}
+	if b.timer == nil && b.window > 0 {
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+	b.mu.Unlock()
+}
+
+func (b *batcher[T]) flush() {
+	b.mu.Lock()
+	b.send()
+}
+
+// send delivers the pending batch. Called with mu held; releases it only once
+// the delivery lock is taken so batches arrive in order.
+func (b *batcher[T]) send() {
+	items, ctx := b.items, b.ctx
+	b.items = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(items) == 0 {
+		b.mu.Unlock()
+		return
+	}
+	b.deliver.Lock()
+	b.mu.Unlock()
+	defer b.deliver.Unlock()
+	b.callback(ctx, items)
+}
+
+// ListenDebounce delivers the latest emission for each key once wait has passed
+// without another emission for that key.
+// Requires sum.New() to have been called first.
+func (e Event[T]) ListenDebounce(wait time.Duration, key func(T) string, callback func(context.Context, T)) *BufferedListener {
+	d := &debouncer[T]{wait: wait, key: key, callback: callback, pending: make(map[string]*debounced[T])}
+	return newBufferedListener(e.Listen(d.add), d.flush)
+}
+
+// debouncer tracks the quiet period per key for ListenDebounce.
+type debouncer[T any] struct {
+	key      func(T) string
+	callback func(context.Context, T)
+	pending  map[string]*debounced[T]
+	wait     time.Duration
+	mu       sync.Mutex
+	deliver  sync.Mutex
+}
+
+// debounced is the latest emission waiting out its quiet period.
+type debounced[T any] struct {
+	ctx   context.Context
+	data  T
+	timer *time.Timer
+	gen   uint64
+}
+
+func (d *debouncer[T]) add(ctx context.Context, data T) {
+	k := d.key(data)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p, ok := d.pending[k]
+	if !ok {
+		p = &debounced[T]{}
+		d.pending[k] = p
+	} else {
+		p.timer.Stop()
+	}
+	p.ctx = context.WithoutCancel(ctx)
+	p.data = data
+	p.gen++
+	gen := p.gen
+	p.timer = time.AfterFunc(d.wait, func() { d.fire(k, gen) })
+}
+
+// fire delivers a key's emission unless a newer one has reset its timer.
+func (d *debouncer[T]) fire(k string, gen uint64) {
+	d.mu.Lock()
+	p, ok := d.pending[k]
+	if !ok || p.gen != gen {
+		d.mu.Unlock()
+		return
+	}
+	delete(d.pending, k)
+	d.deliver.Lock()
+	d.mu.Unlock()
+	defer d.deliver.Unlock()
+	d.callback(p.ctx, p.data)
+}
+
+func (d *debouncer[T]) flush() {
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = make(map[string]*debounced[T])
+	for _, p := range pending {
+		p.timer.Stop()
+	}
+	d.deliver.Lock()
+	d.mu.Unlock()
+	defer d.deliver.Unlock()
+	for _, p := range pending {
+		d.callback(p.ctx, p.data)
+	}
+}
+
+// ListenThrottle delivers at most one emission per interval. The first emission
+// is delivered immediately; later ones within the interval are collapsed into
+// the most recent, which is delivered when the interval ends.
+// Requires sum.New() to have been called first.
+func (e Event[T]) ListenThrottle(interval time.Duration, callback func(context.Context, T)) *BufferedListener {
+	t := &throttler[T]{interval: interval, callback: callback}
+	return newBufferedListener(e.Listen(t.add), t.flush)
+}
+
+// throttler enforces the interval for ListenThrottle.
+type throttler[T any] struct {
+	pending  *debounced[T]
+	callback func(context.Context, T)
+	timer    *time.Timer
+	interval time.Duration
+	mu       sync.Mutex
+	deliver  sync.Mutex
+}
+
+func (t *throttler[T]) add(ctx context.Context, data T) {
+	t.mu.Lock()
+	if t.timer != nil {
+		t.pending = &debounced[T]{ctx: context.WithoutCancel(ctx), data: data}
+		t.mu.Unlock()
+		return
+	}
+	t.timer = time.AfterFunc(t.interval, t.tick)
+	t.send(&debounced[T]{ctx: ctx, data: data})
+}
+
+// tick ends an interval, delivering the collapsed emission if there is one.
+func (t *throttler[T]) tick() {
+	t.mu.Lock()
+	if t.pending == nil {
+		t.timer = nil
+		t.mu.Unlock()
+		return
+	}
+	p := t.pending
+	t.pending = nil
+	t.timer = time.AfterFunc(t.interval, t.tick)
+	t.send(p)
+}
+
+func (t *throttler[T]) flush() {
+	t.mu.Lock()
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	p := t.pending
+	t.pending = nil
+	if p == nil {
+		t.mu.Unlock()
+		return
+	}
+	t.send(p)
+}
+
+// send delivers p. Called with mu held; releases it once the delivery lock is taken.
+func (t *throttler[T]) send(p *debounced[T]) {
+	t.deliver.Lock()
+	t.mu.Unlock()
+	defer t.deliver.Unlock()
+	t.callback(p.ctx, p.data)
+}
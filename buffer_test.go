@@ -0,0 +1,205 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/capitan"
+)
+
+// testCollector records deliveries from buffered listeners.
+type testCollector[T any] struct {
+	got [][]T
+	mu  sync.Mutex
+}
+
+func (c *testCollector[T]) add(items ...T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.got = append(c.got, items)
+}
+
+func (c *testCollector[T]) snapshot() [][]T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]T(nil), c.got...)
+}
+
+func TestListenBatchBySize(t *testing.T) {
+	resetAll(t)
+	New()
+
+	event := NewDebugEvent[testEventInt](capitan.NewSignal("test.buffer.batch.size", "Batch by size"))
+	var c testCollector[testEventInt]
+	b := event.ListenBatch(3, 0, func(_ context.Context, items []testEventInt) { c.add(items...) })
+	defer b.Close()
+
+	ctx := context.Background()
+	for i := 1; i <= 7; i++ {
+		event.Emit(ctx, testEventInt{Value: i})
+	}
+
+	got := c.snapshot()
+	if len(got) != 2 || len(got[0]) != 3 || got[1][0].Value != 4 {
+		t.Fatalf("expected two full batches, got %v", got)
+	}
+
+	b.Close()
+	got = c.snapshot()
+	if len(got) != 3 || len(got[2]) != 1 || got[2][0].Value != 7 {
+		t.Errorf("expected remainder flushed on Close, got %v", got)
+	}
+}
+
+func TestListenBatchByWindow(t *testing.T) {
+	resetAll(t)
+	New()
+
+	event := NewDebugEvent[testEventInt](capitan.NewSignal("test.buffer.batch.window", "Batch by window"))
+	done := make(chan []testEventInt, 1)
+	b := event.ListenBatch(0, 20*time.Millisecond, func(_ context.Context, items []testEventInt) { done <- items })
+	defer b.Close()
+
+	ctx := context.Background()
+	event.Emit(ctx, testEventInt{Value: 1})
+	event.Emit(ctx, testEventInt{Value: 2})
+
+	select {
+	case items := <-done:
+		if len(items) != 2 {
+			t.Errorf("expected 2 items in window, got %v", items)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for window flush")
+	}
+}
+
+func TestListenDebounce(t *testing.T) {
+	resetAll(t)
+	New()
+
+	event := NewDebugEvent[testEventData](capitan.NewSignal("test.buffer.debounce", "Debounce"))
+	var c testCollector[testEventData]
+	d := event.ListenDebounce(time.Hour, func(d testEventData) string { return d.Message[:1] },
+		func(_ context.Context, data testEventData) { c.add(data) })
+
+	ctx := context.Background()
+	event.Emit(ctx, testEventData{Message: "a1"})
+	event.Emit(ctx, testEventData{Message: "b1"})
+	event.Emit(ctx, testEventData{Message: "a2"})
+
+	if got := c.snapshot(); len(got) != 0 {
+		t.Fatalf("expected nothing before quiet period, got %v", got)
+	}
+
+	d.Close()
+	seen := map[string]bool{}
+	for _, items := range c.snapshot() {
+		seen[items[0].Message] = true
+	}
+	if len(seen) != 2 || !seen["a2"] || !seen["b1"] {
+		t.Errorf("expected latest per key flushed on Close, got %v", seen)
+	}
+}
+
+func TestListenDebounceFiresAfterQuiet(t *testing.T) {
+	resetAll(t)
+	New()
+
+	event := NewDebugEvent[testEventData](capitan.NewSignal("test.buffer.debounce.quiet", "Debounce quiet"))
+	done := make(chan string, 2)
+	d := event.ListenDebounce(20*time.Millisecond, func(testEventData) string { return "k" },
+		func(_ context.Context, data testEventData) { done <- data.Message })
+	defer d.Close()
+
+	ctx := context.Background()
+	event.Emit(ctx, testEventData{Message: "first"})
+	event.Emit(ctx, testEventData{Message: "second"})
+
+	select {
+	case msg := <-done:
+		if msg != "second" {
+			t.Errorf("expected latest emission, got %q", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for debounce")
+	}
+}
+
+func TestListenThrottle(t *testing.T) {
+	resetAll(t)
+	New()
+
+	event := NewDebugEvent[testEventInt](capitan.NewSignal("test.buffer.throttle", "Throttle"))
+	var c testCollector[testEventInt]
+	th := event.ListenThrottle(time.Hour, func(_ context.Context, data testEventInt) { c.add(data) })
+
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		event.Emit(ctx, testEventInt{Value: i})
+	}
+
+	got := c.snapshot()
+	if len(got) != 1 || got[0][0].Value != 1 {
+		t.Fatalf("expected only the leading emission, got %v", got)
+	}
+
+	th.Close()
+	got = c.snapshot()
+	if len(got) != 2 || got[1][0].Value != 5 {
+		t.Errorf("expected trailing emission flushed on Close, got %v", got)
+	}
+}
+
+func TestBufferedListenerFlushedOnShutdown(t *testing.T) {
+	resetAll(t)
+	svc := New()
+
+	event := NewDebugEvent[testEventInt](capitan.NewSignal("test.buffer.shutdown", "Shutdown flush"))
+	var c testCollector[testEventInt]
+	event.ListenBatch(100, time.Hour, func(_ context.Context, items []testEventInt) { c.add(items...) })
+
+	event.Emit(context.Background(), testEventInt{Value: 1})
+	_ = svc.Shutdown(context.Background())
+
+	if got := c.snapshot(); len(got) != 1 || got[0][0].Value != 1 {
+		t.Errorf("expected buffered batch flushed on shutdown, got %v", got)
+	}
+}
+
+func TestBufferedListenerCloseReleasesShutdownHook(t *testing.T) {
+	resetAll(t)
+	svc := New()
+
+	event := NewDebugEvent[testEventInt](capitan.NewSignal("test.buffer.release", "Release hook"))
+	svc.mu.RLock()
+	before := len(svc.closers)
+	svc.mu.RUnlock()
+
+	b := event.ListenBatch(10, time.Hour, func(context.Context, []testEventInt) {})
+	b.Close()
+
+	svc.mu.RLock()
+	after := len(svc.closers)
+	svc.mu.RUnlock()
+	if after != before {
+		t.Errorf("expected the shutdown hook removed on Close, had %d now %d", before, after)
+	}
+}
+
+func TestListenBatchRejectsNoTrigger(t *testing.T) {
+	resetAll(t)
+	New()
+
+	event := NewDebugEvent[testEventInt](capitan.NewSignal("test.buffer.notrigger", "No trigger"))
+	defer func() {
+		if recover() == nil {
+			t.Error("expected ListenBatch(0, 0) to panic")
+		}
+	}()
+	event.ListenBatch(0, 0, func(context.Context, []testEventInt) {})
+}
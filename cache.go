@@ -0,0 +1,320 @@
+package sum
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/grub"
+	"github.com/zoobzio/scio"
+)
+
+// Cache signals. Misses and refreshes are emitted at debug level and cache
+// failures as warnings; hits are only counted, so a hot key stays quiet.
+var (
+	SignalCacheMiss    = capitan.NewSignal("sum.cache.miss", "Cache miss")
+	SignalCacheRefresh = capitan.NewSignal("sum.cache.refresh", "Cache entry refreshed")
+	SignalCacheError   = capitan.NewSignal("sum.cache.error", "Cache operation failed")
+)
+
+// Cache signal fields. KeyCacheNegative marks a refresh that cached a
+// "not found".
+var (
+	KeyCacheResource = capitan.NewStringKey("resource")
+	KeyCacheKey      = capitan.NewStringKey("key")
+	KeyCacheNegative = capitan.NewBoolKey("negative")
+	KeyCacheError    = capitan.NewErrorKey("error")
+)
+
+// CacheMode chooses what a Cached write does to the cache.
+type CacheMode int
+
+// Cache modes.
+const (
+	// CacheInvalidate deletes the cached entry so the next read reloads it.
+	CacheInvalidate CacheMode = iota
+	// CacheWriteThrough stores the written value in the cache.
+	CacheWriteThrough
+)
+
+// Cache defaults.
+const (
+	cacheKeyPrefix          = "cache:"
+	defaultCacheTTL         = 5 * time.Minute
+	defaultNegativeCacheTTL = 30 * time.Second
+)
+
+// cacheConfig holds Cached settings.
+type cacheConfig struct {
+	ttl         time.Duration
+	negativeTTL time.Duration
+	mode        CacheMode
+}
+
+// CacheOption configures a Cached.
+type CacheOption func(*cacheConfig)
+
+// WithCacheTTL sets how long loaded records stay cached.
+// The default is 5 minutes.
+func WithCacheTTL(d time.Duration) CacheOption {
+	return func(c *cacheConfig) {
+		c.ttl = d
+	}
+}
+
+// WithNegativeCacheTTL sets how long a record found missing stays cached as
+// missing. Zero disables negative caching. The default is 30 seconds.
+func WithNegativeCacheTTL(d time.Duration) CacheOption {
+	return func(c *cacheConfig) {
+		c.negativeTTL = d
+	}
+}
+
+// WithCacheMode sets what writes do to the cache.
+// The default is CacheInvalidate.
+func WithCacheMode(m CacheMode) CacheOption {
+	return func(c *cacheConfig) {
+		c.mode = m
+	}
+}
+
+// cacheSource is the database behind a Cached.
+type cacheSource[M any] interface {
+	Get(ctx context.Context, key string) (*M, error)
+	Set(ctx context.Context, key string, v *M) error
+	Delete(ctx context.Context, key string) error
+}
+
+// errCacheLoadAborted is returned to readers waiting on a load that
+// panicked.
+var errCacheLoadAborted = errors.New("cache: load aborted")
+
+// cacheEntry is a cached record, or a cached "not found" when Missing.
+type cacheEntry[M any] struct {
+	Value   *M   `json:"value,omitempty"`
+	Missing bool `json:"missing,omitempty"`
+}
+
+// cacheCall is a database load shared by concurrent readers of one key.
+// A stale call was overtaken by a write and must not fill the cache.
+type cacheCall[M any] struct {
+	done  chan struct{}
+	m     *M
+	err   error
+	stale bool
+}
+
+// Cached reads a Database[M] through a Store[M]. Reads are served from the
+// store when present and loaded from the database otherwise, with concurrent
+// misses on one key sharing a single load. Records the database reports
+// missing are cached as missing for the negative TTL.
+//
+// Loads read from the primary, so a replica lagging behind a write cannot
+// refill the cache with the old record, and a load overtaken by a write is
+// not cached. Inside Transact, reads skip the cache and writes invalidate
+// once the transaction commits. Cache failures never fail an operation;
+// they fall back to the database.
+//
+// The store holds cache entries wrapping M rather than M itself, under
+// "cache:" followed by the record key, so they never collide with records
+// the store holds for other uses.
+type Cached[M any] struct {
+	db      *Database[M]
+	store   *Store[M]
+	entries *grub.Store[cacheEntry[M]]
+	source  cacheSource[M]
+	ops     *Counter
+	calls   map[string]*cacheCall[M]
+	uri     string
+	cfg     cacheConfig
+	mu      sync.Mutex
+}
+
+// NewCached layers store over db and registers the pair in the catalog as
+// "db://<table>@cache", tagged with the database and store it derives from.
+// Hits and misses are counted against that resource.
+// Requires sum.New() to have been called first.
+func NewCached[M any](db *Database[M], store *Store[M], opts ...CacheOption) (*Cached[M], error) {
+	s := svc()
+	cfg := cacheConfig{ttl: defaultCacheTTL, negativeTTL: defaultNegativeCacheTTL}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	uri := db.uri + "@cache"
+	r := newResourceRecord(reflect.TypeFor[M](), "", nil)
+	if err := s.catalog.RegisterDatabase(uri, db.Database.Atomic(),
+		scio.WithTag("role", "cache"), scio.WithTag("database", db.uri), scio.WithTag("store", store.uri)); err != nil {
+		return nil, err
+	}
+	s.addResource(uri, r)
+	return &Cached[M]{
+		db:      db,
+		store:   store,
+		entries: grub.NewStore[cacheEntry[M]](store.provider),
+		source:  db,
+		ops:     s.storeOps(),
+		uri:     uri,
+		cfg:     cfg,
+	}, nil
+}
+
+// Get returns the record for key from the cache, loading it from the
+// database on a miss. A missing record returns grub.ErrNotFound.
+func (c *Cached[M]) Get(ctx context.Context, key string) (*M, error) {
+	if _, ok := c.db.tx(ctx); ok {
+		return c.source.Get(ctx, key)
+	}
+	e, err := c.entries.Get(ctx, cacheKey(key))
+	if err == nil {
+		c.ops.Inc(c.uri, "hit", "ok")
+		if e.Missing || e.Value == nil {
+			return nil, grub.ErrNotFound
+		}
+		return e.Value, nil
+	}
+	if !errors.Is(err, grub.ErrNotFound) {
+		c.fail(ctx, key, err)
+	}
+	c.ops.Inc(c.uri, "miss", "ok")
+	capitan.Debug(ctx, SignalCacheMiss, KeyCacheResource.Field(c.uri), KeyCacheKey.Field(key))
+	return c.load(ctx, key)
+}
+
+// Set writes the record to the database, then updates the cache according
+// to the cache mode. Inside Transact the cached entry is dropped once the
+// transaction commits.
+func (c *Cached[M]) Set(ctx context.Context, key string, v *M) error {
+	if err := c.source.Set(ctx, key, v); err != nil {
+		return err
+	}
+	if _, ok := c.db.tx(ctx); ok || c.cfg.mode == CacheInvalidate {
+		c.invalidateAfterCommit(ctx, key)
+		return nil
+	}
+	c.drop(key)
+	c.fill(ctx, key, &cacheEntry[M]{Value: v}, c.cfg.ttl)
+	return nil
+}
+
+// Delete removes the record from the database and the cache.
+func (c *Cached[M]) Delete(ctx context.Context, key string) error {
+	if err := c.source.Delete(ctx, key); err != nil {
+		return err
+	}
+	c.invalidateAfterCommit(ctx, key)
+	return nil
+}
+
+// Invalidate drops key from the cache, including a cached "not found", and
+// stops any load in flight for key from caching its result.
+func (c *Cached[M]) Invalidate(ctx context.Context, key string) {
+	c.drop(key)
+	c.evict(ctx, key)
+}
+
+// invalidateAfterCommit invalidates key once the transaction in ctx
+// commits, or at once outside Transact.
+func (c *Cached[M]) invalidateAfterCommit(ctx context.Context, key string) {
+	ctx = context.WithoutCancel(ctx)
+	afterCommit(ctx, c.db.db, func() { c.Invalidate(ctx, key) })
+}
+
+// drop marks the load in flight for key stale and detaches it, so later
+// readers start a fresh load.
+func (c *Cached[M]) drop(key string) {
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		call.stale = true
+		delete(c.calls, key)
+	}
+	c.mu.Unlock()
+}
+
+// load reads key from the primary and caches the result. Concurrent
+// callers for the same key wait for the first and receive copies of its
+// result. The read runs detached from the first caller's cancellation, so
+// it completes for everyone waiting on it.
+func (c *Cached[M]) load(ctx context.Context, key string) (*M, error) {
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.m == nil {
+			return nil, call.err
+		}
+		m := *call.m
+		return &m, call.err
+	}
+	call := &cacheCall[M]{done: make(chan struct{}), err: errCacheLoadAborted}
+	if c.calls == nil {
+		c.calls = make(map[string]*cacheCall[M])
+	}
+	c.calls[key] = call
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		if c.calls[key] == call {
+			delete(c.calls, key)
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	ctx = WithPrimaryReads(context.WithoutCancel(ctx))
+	m, err := c.source.Get(ctx, key)
+	var entry *cacheEntry[M]
+	ttl := c.cfg.ttl
+	switch {
+	case err == nil:
+		entry = &cacheEntry[M]{Value: m}
+	case errors.Is(err, grub.ErrNotFound) && c.cfg.negativeTTL > 0:
+		entry, ttl = &cacheEntry[M]{Missing: true}, c.cfg.negativeTTL
+	}
+	if entry != nil {
+		c.fill(ctx, key, entry, ttl)
+		// A write that overtook this load has already invalidated key, or
+		// is about to; drop the fill so the old record does not outlive it.
+		c.mu.Lock()
+		stale := call.stale
+		c.mu.Unlock()
+		if stale {
+			c.evict(ctx, key)
+		}
+	}
+	call.m, call.err = m, err
+	return m, err
+}
+
+// cacheKey is the store key of the cache entry for key.
+func cacheKey(key string) string {
+	return cacheKeyPrefix + key
+}
+
+// fill stores the cache entry for key and signals the refresh.
+func (c *Cached[M]) fill(ctx context.Context, key string, e *cacheEntry[M], ttl time.Duration) {
+	if err := c.entries.Set(ctx, cacheKey(key), e, ttl); err != nil {
+		c.fail(ctx, key, err)
+		return
+	}
+	capitan.Debug(ctx, SignalCacheRefresh, KeyCacheResource.Field(c.uri), KeyCacheKey.Field(key), KeyCacheNegative.Field(e.Missing))
+}
+
+// evict deletes the cache entry for key.
+func (c *Cached[M]) evict(ctx context.Context, key string) {
+	if err := c.store.Delete(ctx, cacheKey(key)); err != nil && !errors.Is(err, grub.ErrNotFound) {
+		c.fail(ctx, key, err)
+	}
+}
+
+// fail signals a cache failure. The operation itself falls back to the
+// database, so the error goes no further.
+func (c *Cached[M]) fail(ctx context.Context, key string, err error) {
+	capitan.Warn(ctx, SignalCacheError, KeyCacheResource.Field(c.uri), KeyCacheKey.Field(key), KeyCacheError.Field(err))
+}
@@ -0,0 +1,414 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/grub"
+)
+
+// testCacheSource is an in-memory cacheSource counting database reads.
+type testCacheSource struct {
+	rows    map[string]testModel
+	block   chan struct{}
+	gets    atomic.Int32
+	primary atomic.Bool
+	mu      sync.Mutex
+}
+
+func (s *testCacheSource) Get(ctx context.Context, key string) (*testModel, error) {
+	s.gets.Add(1)
+	s.primary.Store(primaryRequired(ctx))
+	// Read before blocking, as a query would from its snapshot.
+	s.mu.Lock()
+	m, ok := s.rows[key]
+	s.mu.Unlock()
+	if s.block != nil {
+		<-s.block
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if key == "panic" {
+		panic("source failed")
+	}
+	if !ok {
+		return nil, grub.ErrNotFound
+	}
+	return &m, nil
+}
+
+func (s *testCacheSource) Set(_ context.Context, key string, v *testModel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows[key] = *v
+	return nil
+}
+
+func (s *testCacheSource) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rows, key)
+	return nil
+}
+
+func newTestCached(t *testing.T, opts ...CacheOption) (*Cached[testModel], *testCacheSource, *MemoryStore) {
+	t.Helper()
+	d, _ := newTestDatabase(t)
+	p := NewMemoryStore()
+	store, err := NewStore[testModel](p, "users-cache")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	c, err := NewCached(d, store, opts...)
+	if err != nil {
+		t.Fatalf("NewCached: %v", err)
+	}
+	src := &testCacheSource{rows: map[string]testModel{"a": {ID: "a", Name: "alpha"}}}
+	c.source = src
+	return c, src, p
+}
+
+func TestCachedReadThrough(t *testing.T) {
+	c, src, _ := newTestCached(t)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var got []string
+	obs := capitan.Observe(func(_ context.Context, ev *capitan.Event) {
+		if ev.Signal() != SignalCacheMiss && ev.Signal() != SignalCacheRefresh {
+			return
+		}
+		key, _ := KeyCacheKey.From(ev)
+		mu.Lock()
+		got = append(got, ev.Signal().Name()+"/"+key)
+		mu.Unlock()
+	})
+	t.Cleanup(obs.Close)
+
+	for range 2 {
+		m, err := c.Get(ctx, "a")
+		if err != nil || m.Name != "alpha" {
+			t.Fatalf("expected cached record, got %+v, %v", m, err)
+		}
+	}
+	if n := src.gets.Load(); n != 1 {
+		t.Errorf("expected one database read, got %d", n)
+	}
+	res, _ := svc().Resource("db://users@cache")
+	if res.Stats["miss"].OK != 1 || res.Stats["hit"].OK != 1 {
+		t.Errorf("expected one miss and one hit, got %+v", res.Stats)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 || got[0] != "sum.cache.miss/a" || got[1] != "sum.cache.refresh/a" {
+		t.Errorf("unexpected cache signals %v", got)
+	}
+}
+
+func TestCachedReadsThroughDatabase(t *testing.T) {
+	d, state := newTestDatabase(t)
+	ctx := context.Background()
+
+	var reads atomic.Int32
+	state.mu.Lock()
+	state.rows = func(query string) *testMigrateRows {
+		if !strings.Contains(query, "users") {
+			return nil
+		}
+		reads.Add(1)
+		return &testMigrateRows{cols: []string{"id", "name"}, rows: [][]driver.Value{{"a", "alpha"}}}
+	}
+	state.mu.Unlock()
+
+	store, err := NewStore[testModel](NewMemoryStore(), "users-cache")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	c, err := NewCached(d, store)
+	if err != nil {
+		t.Fatalf("NewCached: %v", err)
+	}
+
+	for range 2 {
+		m, err := c.Get(ctx, "a")
+		if err != nil || m == nil || m.Name != "alpha" {
+			t.Fatalf("expected record from the database, got %+v, %v", m, err)
+		}
+	}
+	if n := reads.Load(); n != 1 {
+		t.Errorf("expected one database query, got %d", n)
+	}
+
+	c.Invalidate(ctx, "a")
+	if _, err := c.Get(ctx, "a"); err != nil {
+		t.Fatalf("Get after Invalidate: %v", err)
+	}
+	if n := reads.Load(); n != 2 {
+		t.Errorf("expected Invalidate to force a database query, got %d", n)
+	}
+	res, _ := svc().Resource("db://users")
+	if res.Stats["get"].OK != 2 {
+		t.Errorf("expected database reads counted, got %+v", res.Stats)
+	}
+}
+
+func TestCachedKeepsEntriesApartFromStoreRecords(t *testing.T) {
+	c, _, p := newTestCached(t, WithCacheMode(CacheWriteThrough))
+	ctx := context.Background()
+	if err := c.store.Set(ctx, "a", &testModel{ID: "a", Name: "session"}, 0); err != nil {
+		t.Fatalf("store Set: %v", err)
+	}
+
+	if m, err := c.Get(ctx, "a"); err != nil || m.Name != "alpha" {
+		t.Fatalf("expected record from the database, got %+v, %v", m, err)
+	}
+	if err := c.Set(ctx, "a", &testModel{ID: "a", Name: "beta"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	c.Invalidate(ctx, "a")
+
+	m, err := c.store.Get(ctx, "a")
+	if err != nil || m.Name != "session" {
+		t.Errorf("expected the store's own record untouched, got %+v, %v", m, err)
+	}
+	if ok, _ := p.Exists(ctx, "cache:a"); ok {
+		t.Error("expected the cache entry invalidated")
+	}
+}
+
+func TestCachedSignalsCacheFailures(t *testing.T) {
+	c, _, p := newTestCached(t)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var errs []error
+	obs := capitan.Observe(func(_ context.Context, ev *capitan.Event) {
+		if ev.Signal() != SignalCacheError {
+			return
+		}
+		err, _ := KeyCacheError.From(ev)
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	})
+	t.Cleanup(obs.Close)
+
+	boom := errors.New("cache down")
+	p.Fail("", boom)
+	if m, err := c.Get(ctx, "a"); err != nil || m.Name != "alpha" {
+		t.Fatalf("expected fallback to the database, got %+v, %v", m, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 2 || !errors.Is(errs[0], boom) || !errors.Is(errs[1], boom) {
+		t.Errorf("expected failed get and fill signalled, got %v", errs)
+	}
+}
+
+func TestCachedNegative(t *testing.T) {
+	c, src, _ := newTestCached(t)
+	ctx := context.Background()
+	for range 2 {
+		if _, err := c.Get(ctx, "missing"); !errors.Is(err, grub.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if n := src.gets.Load(); n != 1 {
+		t.Errorf("expected missing record cached, got %d database reads", n)
+	}
+	if err := c.Set(ctx, "missing", &testModel{ID: "missing"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := c.Get(ctx, "missing"); err != nil {
+		t.Errorf("expected write to clear the negative entry, got %v", err)
+	}
+
+	c, src, _ = newTestCached(t, WithNegativeCacheTTL(0))
+	for range 2 {
+		_, _ = c.Get(ctx, "missing")
+	}
+	if n := src.gets.Load(); n != 2 {
+		t.Errorf("expected negative caching disabled, got %d database reads", n)
+	}
+}
+
+func TestCachedSingleFlight(t *testing.T) {
+	c, src, _ := newTestCached(t)
+	src.block = make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]*testModel, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = c.Get(context.Background(), "a")
+		}()
+	}
+	for src.gets.Load() == 0 {
+		runtime.Gosched()
+	}
+	close(src.block)
+	wg.Wait()
+
+	if n := src.gets.Load(); n != 1 {
+		t.Errorf("expected concurrent misses to share one read, got %d", n)
+	}
+	for i, m := range results {
+		if m == nil || m.Name != "alpha" {
+			t.Errorf("result %d: unexpected %+v", i, m)
+		}
+	}
+}
+
+func TestCachedWriteModes(t *testing.T) {
+	ctx := context.Background()
+
+	c, _, p := newTestCached(t)
+	_, _ = c.Get(ctx, "a")
+	if err := c.Set(ctx, "a", &testModel{ID: "a", Name: "changed"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if ok, _ := p.Exists(context.Background(), "cache:a"); ok {
+		t.Error("expected invalidate mode to drop the cached entry")
+	}
+
+	c, _, p = newTestCached(t, WithCacheMode(CacheWriteThrough))
+	if err := c.Set(ctx, "b", &testModel{ID: "b", Name: "beta"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if ok, _ := p.Exists(context.Background(), "cache:b"); !ok {
+		t.Error("expected write-through mode to cache the written record")
+	}
+	if err := c.Delete(ctx, "b"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ok, _ := p.Exists(context.Background(), "cache:b"); ok {
+		t.Error("expected delete to drop the cached entry")
+	}
+}
+
+func TestCachedBypassesCacheInTransaction(t *testing.T) {
+	c, src, p := newTestCached(t, WithCacheMode(CacheWriteThrough))
+	_ = TransactOn(context.Background(), c.db.db, func(ctx context.Context) error {
+		_, _ = c.Get(ctx, "a")
+		_, _ = c.Get(ctx, "a")
+		if err := c.Set(ctx, "b", &testModel{ID: "b"}); err != nil {
+			t.Errorf("Set: %v", err)
+		}
+		return nil
+	})
+	if n := src.gets.Load(); n != 2 {
+		t.Errorf("expected reads inside Transact to skip the cache, got %d database reads", n)
+	}
+	if keys, _ := p.List(context.Background(), "", 0); len(keys) != 0 {
+		t.Errorf("expected nothing cached inside Transact, got %v", keys)
+	}
+}
+
+func TestCachedRegistersDerivedResource(t *testing.T) {
+	newTestCached(t)
+	res := svc().Catalog().Resource("db://users@cache")
+	if res == nil {
+		t.Fatal("expected cache registered in the catalog")
+	}
+	tags := res.Metadata.Tags
+	if tags["role"] != "cache" || tags["database"] != "db://users" || tags["store"] != "kv://users-cache" {
+		t.Errorf("unexpected cache metadata %+v", res.Metadata)
+	}
+}
+
+func TestCachedInvalidatesAfterCommit(t *testing.T) {
+	c, _, p := newTestCached(t)
+	ctx := context.Background()
+	_, _ = c.Get(ctx, "a")
+
+	_ = TransactOn(ctx, c.db.db, func(ctx context.Context) error {
+		if err := c.Set(ctx, "a", &testModel{ID: "a", Name: "changed"}); err != nil {
+			t.Errorf("Set: %v", err)
+		}
+		if ok, _ := p.Exists(context.Background(), "cache:a"); !ok {
+			t.Error("expected invalidation deferred until commit")
+		}
+		return nil
+	})
+	if ok, _ := p.Exists(context.Background(), "cache:a"); ok {
+		t.Error("expected entry invalidated on commit")
+	}
+
+	_, _ = c.Get(ctx, "a")
+	_ = TransactOn(ctx, c.db.db, func(ctx context.Context) error {
+		_ = c.Delete(ctx, "a")
+		return errors.New("rollback")
+	})
+	if ok, _ := p.Exists(context.Background(), "cache:a"); !ok {
+		t.Error("expected entry kept when the transaction rolls back")
+	}
+}
+
+func TestCachedDropsStaleLoad(t *testing.T) {
+	c, src, p := newTestCached(t)
+	src.block = make(chan struct{})
+	ctx := context.Background()
+
+	done := make(chan *testModel)
+	go func() {
+		m, _ := c.Get(ctx, "a")
+		done <- m
+	}()
+	for src.gets.Load() == 0 {
+		runtime.Gosched()
+	}
+	if err := c.Set(ctx, "a", &testModel{ID: "a", Name: "changed"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	close(src.block)
+	if m := <-done; m == nil || m.Name != "alpha" {
+		t.Errorf("expected the in-flight read's own result, got %+v", m)
+	}
+	if ok, _ := p.Exists(context.Background(), "cache:a"); ok {
+		t.Error("expected load overtaken by a write not cached")
+	}
+	if m, _ := c.Get(ctx, "a"); m == nil || m.Name != "changed" {
+		t.Errorf("expected written record reloaded, got %+v", m)
+	}
+	if !src.primary.Load() {
+		t.Error("expected loads to read from the primary")
+	}
+}
+
+func TestCachedLoadDetached(t *testing.T) {
+	c, _, p := newTestCached(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if m, err := c.Get(ctx, "a"); err != nil || m.Name != "alpha" {
+		t.Errorf("expected load to outlive the caller's cancellation, got %+v, %v", m, err)
+	}
+	if ok, _ := p.Exists(context.Background(), "cache:a"); !ok {
+		t.Error("expected detached load cached")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected source panic to reach the loading caller")
+			}
+		}()
+		_, _ = c.Get(context.Background(), "panic")
+	}()
+	if len(c.calls) != 0 {
+		t.Errorf("expected panicked load cleaned up, got %v", c.calls)
+	}
+}
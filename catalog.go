@@ -0,0 +1,179 @@
+package sum
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/zoobzio/scio"
+)
+
+// ResourceOption configures how a Database, Store or Bucket is described in
+// the catalog.
+type ResourceOption func(*resourceRecord)
+
+// WithOwner records the service contract that owns the resource,
+// e.g. reflect.TypeFor[Users]().
+func WithOwner(contract reflect.Type) ResourceOption {
+	return func(r *resourceRecord) {
+		r.owner = fqdn(contract)
+	}
+}
+
+// WithResourceDescription records a human-readable description.
+func WithResourceDescription(desc string) ResourceOption {
+	return func(r *resourceRecord) {
+		r.description = desc
+	}
+}
+
+// resourceRecord holds what sum knows about a resource beyond scio.
+type resourceRecord struct {
+	model           string
+	provider        string
+	owner           string
+	description     string
+	schema          schemaMode
+	replicaPolicy   ReplicaPolicy
+	replicaInterval time.Duration
+}
+
+// ResourceInfo describes a data resource in the catalog. Stats count
+// database operations always, and store and bucket operations while metrics
+// are served.
+type ResourceInfo struct {
+	Stats       map[string]OperationStats `json:"stats,omitempty"`
+	URI         string                    `json:"uri"`
+	Variant     scio.Variant              `json:"variant"`
+	Name        string                    `json:"name"`
+	Model       string                    `json:"model,omitempty"`
+	Provider    string                    `json:"provider,omitempty"`
+	Owner       string                    `json:"owner,omitempty"`
+	Description string                    `json:"description,omitempty"`
+}
+
+// OperationStats counts the outcomes of one kind of operation.
+type OperationStats struct {
+	OK     uint64 `json:"ok"`
+	Errors uint64 `json:"errors"`
+}
+
+// newResourceRecord describes a resource created through sum. Options are
+// applied after the defaults.
+func newResourceRecord(model reflect.Type, provider string, opts []ResourceOption) *resourceRecord {
+	r := &resourceRecord{model: fqdn(model), provider: provider}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// addResource records a resource once it is in the catalog.
+func (s *Service) addResource(uri string, r *resourceRecord) {
+	s.mu.Lock()
+	if s.resources == nil {
+		s.resources = make(map[string]*resourceRecord)
+	}
+	s.resources[uri] = r
+	s.mu.Unlock()
+}
+
+// registrationOptions carries a resource description into scio.
+func (r *resourceRecord) registrationOptions() []scio.RegistrationOption {
+	if r.description == "" {
+		return nil
+	}
+	return []scio.RegistrationOption{scio.WithDescription(r.description)}
+}
+
+// providerName names the implementation behind a store or bucket, looking
+// through sum's own wrappers.
+func providerName(p any) string {
+	if w, ok := p.(interface{ backing() any }); ok {
+		return providerName(w.backing())
+	}
+	return fmt.Sprintf("%T", p)
+}
+
+// Resources lists every database, store and bucket in the catalog, sorted
+// by URI, with live operation counts. Resources registered on the catalog
+// directly rather than through NewDatabase, NewStore or NewBucket appear
+// without model, provider or owner.
+func (s *Service) Resources() []ResourceInfo {
+	stats := s.resourceStats()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sources := s.catalog.Sources()
+	out := make([]ResourceInfo, 0, len(sources))
+	for _, res := range sources {
+		if res.Variant == scio.VariantIndex {
+			continue
+		}
+		info := ResourceInfo{
+			URI:         res.URI,
+			Variant:     res.Variant,
+			Name:        res.Name,
+			Description: res.Metadata.Description,
+			Stats:       stats[res.URI],
+		}
+		if r, ok := s.resources[res.URI]; ok {
+			info.Model = r.model
+			info.Provider = r.provider
+			info.Owner = r.owner
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].URI < out[j].URI })
+	return out
+}
+
+// Resource returns the catalog entry for uri, e.g. "kv://sessions".
+func (s *Service) Resource(uri string) (ResourceInfo, bool) {
+	for _, r := range s.Resources() {
+		if r.URI == uri {
+			return r, true
+		}
+	}
+	return ResourceInfo{}, false
+}
+
+// resourceStats groups the operation counter by resource.
+func (s *Service) resourceStats() map[string]map[string]OperationStats {
+	out := make(map[string]map[string]OperationStats)
+	s.storeOps().f.each(func(values []string, v float64) {
+		uri, op, result := values[0], values[1], values[2]
+		if out[uri] == nil {
+			out[uri] = make(map[string]OperationStats)
+		}
+		st := out[uri][op]
+		if result == "error" {
+			st.Errors += uint64(v)
+		} else {
+			st.OK += uint64(v)
+		}
+		out[uri][op] = st
+	})
+	return out
+}
+
+// catalogHandler serves the catalog as JSON. With a uri query parameter it
+// serves that resource alone.
+func (s *Service) catalogHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body any = s.Resources()
+		if uri := r.URL.Query().Get("uri"); uri != "" {
+			res, ok := s.Resource(uri)
+			if !ok {
+				http.Error(w, "resource not found", http.StatusNotFound)
+				return
+			}
+			body = res
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	})
+}
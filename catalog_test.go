@@ -0,0 +1,102 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResourcesDescribesCatalog(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New().ServeMetrics("/metrics")
+	ctx := context.Background()
+
+	store, err := NewStore[testInvoice](NewMemoryStore(), "invoices",
+		WithOwner(reflect.TypeFor[testSvc]()),
+		WithResourceDescription("Invoices by ID"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	if _, err := NewBucket[testInvoice](NewMemoryBucket(), "archive"); err != nil {
+		t.Fatalf("NewBucket: %v", err)
+	}
+	if err := store.Set(ctx, "a", &testInvoice{ID: "a"}, 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := store.Get(ctx, "missing"); err == nil {
+		t.Fatal("expected error for missing key")
+	}
+
+	resources := svc().Resources()
+	if len(resources) != 2 || resources[0].URI != "bcs://archive" || resources[1].URI != "kv://invoices" {
+		t.Fatalf("unexpected resources %+v", resources)
+	}
+	kv := resources[1]
+	if kv.Name != "invoices" || !strings.HasSuffix(kv.Model, ".testInvoice") ||
+		kv.Provider != "*sum.MemoryStore" || !strings.HasSuffix(kv.Owner, ".testSvc") ||
+		kv.Description != "Invoices by ID" {
+		t.Errorf("unexpected store entry %+v", kv)
+	}
+	if kv.Stats["set"].OK != 1 || kv.Stats["get"].Errors != 1 {
+		t.Errorf("unexpected stats %+v", kv.Stats)
+	}
+	if resources[0].Provider != "*sum.MemoryBucket" || resources[0].Owner != "" {
+		t.Errorf("unexpected bucket entry %+v", resources[0])
+	}
+
+	if _, ok := svc().Resource("kv://invoices"); !ok {
+		t.Error("expected Resource to find kv://invoices")
+	}
+	if _, ok := svc().Resource("kv://unknown"); ok {
+		t.Error("expected Resource to miss kv://unknown")
+	}
+}
+
+func TestAdminServesCatalog(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	a := New().WithAdmin("127.0.0.1", 0).Admin()
+	if _, err := NewStore[testInvoice](NewMemoryStore(), "invoices"); err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	rec := adminRequest(t, a, "/catalog", "")
+	var resources []ResourceInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &resources); err != nil || len(resources) != 1 {
+		t.Fatalf("unexpected catalog response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = adminRequest(t, a, "/catalog?uri=kv://invoices", "")
+	var res ResourceInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || res.URI != "kv://invoices" {
+		t.Errorf("unexpected resource response: %s", rec.Body.String())
+	}
+
+	if rec := adminRequest(t, a, "/catalog?uri=kv://unknown", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown resource, got %d", rec.Code)
+	}
+}
+
+func TestStoresUnwrappedWithoutObservability(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New()
+	p := NewMemoryStore()
+	store, err := NewStore[testInvoice](p, "plain")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	if store.provider != p {
+		t.Errorf("expected provider used directly, got %T", store.provider)
+	}
+	_ = store.Set(context.Background(), "a", &testInvoice{ID: "a"}, 0)
+	if res, _ := svc().Resource("kv://plain"); len(res.Stats) != 0 {
+		t.Errorf("expected no stats without metrics, got %+v", res.Stats)
+	}
+}
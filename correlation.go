@@ -0,0 +1,102 @@
+package sum
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/zoobzio/capitan"
+)
+
+// HeaderRequestID carries the correlation ID on requests and responses.
+const HeaderRequestID = "X-Request-ID"
+
+// KeyCorrelationID is the field every Event emission carries when its
+// context has a correlation ID.
+var KeyCorrelationID = capitan.NewStringKey("correlation_id")
+
+// maxCorrelationID bounds accepted incoming IDs.
+const maxCorrelationID = 128
+
+// correlationKey carries the correlation ID on a context.
+type correlationKey struct{}
+
+// WithCorrelationID returns ctx carrying id.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID in ctx, or "".
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// NewCorrelationID generates a random correlation ID.
+func NewCorrelationID() string {
+	return newID(16)
+}
+
+// WithCorrelation assigns every request a correlation ID, reusing a valid
+// incoming X-Request-ID header or generating one, and echoes it in the
+// response. The ID travels in the request context, is attached to every
+// Event emission and span, reaches listeners (including replayed emissions),
+// and is recorded on webhook deliveries.
+func (s *Service) WithCorrelation() *Service {
+	s.mu.Lock()
+	s.correlated = true
+	s.mu.Unlock()
+	return s
+}
+
+// correlation reads or generates the request's correlation ID.
+func (s *Service) correlation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		on := s.correlated
+		s.mu.RUnlock()
+		if !on {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id := r.Header.Get(HeaderRequestID)
+		if !validCorrelationID(id) {
+			id = NewCorrelationID()
+		}
+		w.Header().Set(HeaderRequestID, id)
+		next.ServeHTTP(w, r.WithContext(WithCorrelationID(r.Context(), id)))
+	})
+}
+
+// validCorrelationID accepts short, printable ASCII IDs so callers cannot
+// inject header or log content.
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationID {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// correlationFields returns the correlation field for an emission, if any.
+func correlationFields(ctx context.Context) []capitan.Field {
+	if id := CorrelationID(ctx); id != "" {
+		return []capitan.Field{KeyCorrelationID.Field(id)}
+	}
+	return nil
+}
+
+// eventContext restores an emission's correlation ID onto a listener's
+// context when it did not arrive there, as with replayed events.
+func eventContext(ctx context.Context, ev *capitan.Event) context.Context {
+	if CorrelationID(ctx) != "" {
+		return ctx
+	}
+	if id, ok := KeyCorrelationID.From(ev); ok && id != "" {
+		return WithCorrelationID(ctx, id)
+	}
+	return ctx
+}
@@ -0,0 +1,122 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/capitan"
+)
+
+func TestCorrelationMiddleware(t *testing.T) {
+	resetAll(t)
+	svc := New().WithCorrelation()
+
+	var seen string
+	h := svc.correlation(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		seen = CorrelationID(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		reuse  bool
+	}{
+		{name: "generated", header: "", reuse: false},
+		{name: "incoming", header: "req-123", reuse: true},
+		{name: "control characters", header: "bad\tid", reuse: false},
+		{name: "too long", header: strings.Repeat("a", maxCorrelationID+1), reuse: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(HeaderRequestID, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			echoed := rec.Header().Get(HeaderRequestID)
+			if echoed == "" || echoed != seen {
+				t.Fatalf("expected echoed ID %q to match context ID %q", echoed, seen)
+			}
+			if (echoed == tt.header) != tt.reuse {
+				t.Errorf("header %q: got ID %q", tt.header, echoed)
+			}
+		})
+	}
+}
+
+func TestCorrelationReachesListeners(t *testing.T) {
+	resetAll(t)
+	New()
+
+	event := NewInfoEvent[testEventInt](capitan.NewSignal("test.correlation.event", "Correlated event"))
+	var recorded *capitan.Event
+	obs := capitan.Observe(func(_ context.Context, ev *capitan.Event) {
+		if ev.Signal() == event.Signal && !ev.IsReplay() {
+			recorded = ev.Clone()
+		}
+	})
+	t.Cleanup(obs.Close)
+
+	var got []string
+	event.Listen(func(ctx context.Context, _ testEventInt) {
+		got = append(got, CorrelationID(ctx))
+	})
+
+	event.Emit(WithCorrelationID(context.Background(), "abc"), testEventInt{Value: 1})
+	if recorded == nil {
+		t.Fatal("emission not observed")
+	}
+	if id, ok := KeyCorrelationID.From(recorded); !ok || id != "abc" {
+		t.Errorf("expected correlation field on emission, got %q", id)
+	}
+
+	capitan.Replay(context.Background(), recorded)
+	if len(got) != 2 || got[0] != "abc" || got[1] != "abc" {
+		t.Errorf("expected ID for live and replayed emission, got %v", got)
+	}
+
+	event.Emit(context.Background(), testEventInt{Value: 2})
+	if len(got) != 3 || got[2] != "" {
+		t.Errorf("expected no ID without one in context, got %v", got)
+	}
+}
+
+func TestCorrelationOnWebhookDelivery(t *testing.T) {
+	resetAll(t)
+	New()
+
+	got := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get(HeaderRequestID)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	w := newTestWebhooks(t, 1, WebhookEndpoint{ID: "ep_1", URL: srv.URL, Secret: "shh"})
+	event := NewInfoEvent[testInvoice](capitan.NewSignal("test.correlation.webhook", "Correlated webhook"))
+	WebhookEvent(w, event, func(context.Context, testInvoice) []string {
+		return []string{"ep_1"}
+	})
+
+	event.Emit(WithCorrelationID(context.Background(), "req-9"), testInvoice{ID: "inv_9"})
+
+	select {
+	case id := <-got:
+		if id != "req-9" {
+			t.Errorf("expected correlation header, got %q", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for webhook")
+	}
+	if d := waitForDelivery(t, w, WebhookDelivered); d.CorrelationID != "req-9" {
+		t.Errorf("expected correlation ID on delivery record, got %q", d.CorrelationID)
+	}
+}
@@ -1,44 +1,276 @@
 package sum
 
 import (
+	"context"
+	"reflect"
+	"time"
+
 	"github.com/jmoiron/sqlx"
 	"github.com/zoobzio/astql"
+	"github.com/zoobzio/edamame"
 	"github.com/zoobzio/grub"
 )
 
 // Database wraps grub.Database and registers with scio on creation.
 // Embed this type in your store structs to add custom query methods.
+// Operations are traced and counted against the catalog resource, and run
+// inside the transaction when called within Transact; statements run through
+// Executor() directly do not. Databases created with NewReplicatedDatabase
+// read from replicas.
 type Database[M any] struct {
 	*grub.Database[M]
+	db       *sqlx.DB
+	renderer astql.Renderer
+	ops      *Counter
+	replicas *replicaSet
+	uri      string
+
+	replicaDBs []*grub.Database[M]
 }
 
 // NewDatabase creates a Database[M] and registers it with the scio catalog.
 // Requires sum.New() to have been called first.
-func NewDatabase[M any](db *sqlx.DB, table string, renderer astql.Renderer) (*Database[M], error) {
+func NewDatabase[M any](db *sqlx.DB, table string, renderer astql.Renderer, opts ...ResourceOption) (*Database[M], error) {
+	return newDatabase[M](db, table, table, renderer, opts)
+}
+
+// newDatabase creates a Database[M] on table, registered and schema-checked
+// as name. The two differ when the renderer qualifies the table.
+func newDatabase[M any](db *sqlx.DB, table, name string, renderer astql.Renderer, opts []ResourceOption) (*Database[M], error) {
+	s := svc()
 	gdb, err := grub.NewDatabase[M](db, table, renderer)
 	if err != nil {
 		return nil, err
 	}
-	if err := svc().catalog.RegisterDatabase("db://"+table, gdb.Atomic()); err != nil {
+	uri := "db://" + name
+	r := newResourceRecord(reflect.TypeFor[M](), db.DriverName(), opts)
+	if err := r.checkSchema(context.Background(), name, func(ctx context.Context) error {
+		return CheckSchema[M](ctx, db, name)
+	}); err != nil {
+		return nil, err
+	}
+	if err := s.catalog.RegisterDatabase(uri, gdb.Atomic(), r.registrationOptions()...); err != nil {
 		return nil, err
 	}
-	return &Database[M]{Database: gdb}, nil
+	s.addResource(uri, r)
+	s.useDB(db)
+	return &Database[M]{Database: gdb, db: db, renderer: renderer, ops: s.storeOps(), uri: uri}, nil
+}
+
+// Get retrieves a record by primary key.
+func (d *Database[M]) Get(ctx context.Context, key string) (m *M, err error) {
+	gdb, uri := d.reader(ctx)
+	err = observe(ctx, d.ops, uri, "get", func(ctx context.Context) error {
+		if tx, ok := d.tx(ctx); ok {
+			m, err = d.Database.GetTx(ctx, tx, key)
+			return err
+		}
+		m, err = gdb.Get(ctx, key)
+		return err
+	})
+	return m, err
+}
+
+// Set inserts or updates a record by primary key.
+func (d *Database[M]) Set(ctx context.Context, key string, v *M) error {
+	return d.write(ctx, "set", func(ctx context.Context) error {
+		if tx, ok := d.tx(ctx); ok {
+			return d.Database.SetTx(ctx, tx, key, v)
+		}
+		return d.Database.Set(ctx, key, v)
+	})
+}
+
+// Delete removes a record by primary key.
+func (d *Database[M]) Delete(ctx context.Context, key string) error {
+	return d.write(ctx, "delete", func(ctx context.Context) error {
+		if tx, ok := d.tx(ctx); ok {
+			return d.Database.DeleteTx(ctx, tx, key)
+		}
+		return d.Database.Delete(ctx, key)
+	})
+}
+
+// Exists reports whether a record exists.
+func (d *Database[M]) Exists(ctx context.Context, key string) (ok bool, err error) {
+	gdb, uri := d.reader(ctx)
+	err = observe(ctx, d.ops, uri, "exists", func(ctx context.Context) error {
+		if tx, ok := d.tx(ctx); ok {
+			ok, err = d.Database.ExistsTx(ctx, tx, key)
+			return err
+		}
+		ok, err = gdb.Exists(ctx, key)
+		return err
+	})
+	return ok, err
+}
+
+// GetTx retrieves a record by primary key within a transaction.
+func (d *Database[M]) GetTx(ctx context.Context, tx *sqlx.Tx, key string) (m *M, err error) {
+	err = observe(ctx, d.ops, d.uri, "get", func(ctx context.Context) error {
+		m, err = d.Database.GetTx(ctx, tx, key)
+		return err
+	})
+	return m, err
+}
+
+// SetTx inserts or updates a record within a transaction.
+func (d *Database[M]) SetTx(ctx context.Context, tx *sqlx.Tx, key string, v *M) error {
+	return observe(ctx, d.ops, d.uri, "set", func(ctx context.Context) error {
+		return d.Database.SetTx(ctx, tx, key, v)
+	})
+}
+
+// DeleteTx removes a record within a transaction.
+func (d *Database[M]) DeleteTx(ctx context.Context, tx *sqlx.Tx, key string) error {
+	return observe(ctx, d.ops, d.uri, "delete", func(ctx context.Context) error {
+		return d.Database.DeleteTx(ctx, tx, key)
+	})
+}
+
+// ExistsTx reports whether a record exists within a transaction.
+func (d *Database[M]) ExistsTx(ctx context.Context, tx *sqlx.Tx, key string) (ok bool, err error) {
+	err = observe(ctx, d.ops, d.uri, "exists", func(ctx context.Context) error {
+		ok, err = d.Database.ExistsTx(ctx, tx, key)
+		return err
+	})
+	return ok, err
+}
+
+// ExecQuery runs a query statement.
+func (d *Database[M]) ExecQuery(ctx context.Context, stmt edamame.QueryStatement, params map[string]any) (rows []*M, err error) {
+	gdb, uri := d.reader(ctx)
+	err = observe(ctx, d.ops, uri, "query", func(ctx context.Context) error {
+		if tx, ok := d.tx(ctx); ok {
+			rows, err = d.Database.ExecQueryTx(ctx, tx, stmt, params)
+			return err
+		}
+		rows, err = gdb.ExecQuery(ctx, stmt, params)
+		return err
+	})
+	return rows, err
+}
+
+// ExecQueryTx runs a query statement within a transaction.
+func (d *Database[M]) ExecQueryTx(ctx context.Context, tx *sqlx.Tx, stmt edamame.QueryStatement, params map[string]any) (rows []*M, err error) {
+	err = observe(ctx, d.ops, d.uri, "query", func(ctx context.Context) error {
+		rows, err = d.Database.ExecQueryTx(ctx, tx, stmt, params)
+		return err
+	})
+	return rows, err
+}
+
+// ExecSelect runs a select statement.
+func (d *Database[M]) ExecSelect(ctx context.Context, stmt edamame.SelectStatement, params map[string]any) (m *M, err error) {
+	gdb, uri := d.reader(ctx)
+	err = observe(ctx, d.ops, uri, "select", func(ctx context.Context) error {
+		if tx, ok := d.tx(ctx); ok {
+			m, err = d.Database.ExecSelectTx(ctx, tx, stmt, params)
+			return err
+		}
+		m, err = gdb.ExecSelect(ctx, stmt, params)
+		return err
+	})
+	return m, err
+}
+
+// ExecSelectTx runs a select statement within a transaction.
+func (d *Database[M]) ExecSelectTx(ctx context.Context, tx *sqlx.Tx, stmt edamame.SelectStatement, params map[string]any) (m *M, err error) {
+	err = observe(ctx, d.ops, d.uri, "select", func(ctx context.Context) error {
+		m, err = d.Database.ExecSelectTx(ctx, tx, stmt, params)
+		return err
+	})
+	return m, err
+}
+
+// ExecInsert inserts a record and returns it as stored.
+func (d *Database[M]) ExecInsert(ctx context.Context, record *M) (m *M, err error) {
+	err = d.write(ctx, "insert", func(ctx context.Context) error {
+		if tx, ok := d.tx(ctx); ok {
+			m, err = d.Executor().ExecInsertTx(ctx, tx, record)
+			return err
+		}
+		m, err = d.Executor().ExecInsert(ctx, record)
+		return err
+	})
+	return m, err
+}
+
+// ExecUpdate runs an update statement and returns the updated record.
+func (d *Database[M]) ExecUpdate(ctx context.Context, stmt edamame.UpdateStatement, params map[string]any) (m *M, err error) {
+	err = d.write(ctx, "update", func(ctx context.Context) error {
+		if tx, ok := d.tx(ctx); ok {
+			m, err = d.Executor().ExecUpdateTx(ctx, tx, stmt, params)
+			return err
+		}
+		m, err = d.Executor().ExecUpdate(ctx, stmt, params)
+		return err
+	})
+	return m, err
+}
+
+// ExecDelete runs a delete statement and returns the number of rows removed.
+func (d *Database[M]) ExecDelete(ctx context.Context, stmt edamame.DeleteStatement, params map[string]any) (n int64, err error) {
+	err = d.write(ctx, "delete", func(ctx context.Context) error {
+		if tx, ok := d.tx(ctx); ok {
+			n, err = d.Executor().ExecDeleteTx(ctx, tx, stmt, params)
+			return err
+		}
+		n, err = d.Executor().ExecDelete(ctx, stmt, params)
+		return err
+	})
+	return n, err
+}
+
+// ExecAggregate runs an aggregate statement against the primary.
+func (d *Database[M]) ExecAggregate(ctx context.Context, stmt edamame.AggregateStatement, params map[string]any) (v float64, err error) {
+	err = observe(ctx, d.ops, d.uri, "aggregate", func(ctx context.Context) error {
+		if tx, ok := d.tx(ctx); ok {
+			v, err = d.Executor().ExecAggregateTx(ctx, tx, stmt, params)
+			return err
+		}
+		v, err = d.Executor().ExecAggregate(ctx, stmt, params)
+		return err
+	})
+	return v, err
+}
+
+// write observes a write against the primary and records it for
+// WithReadYourWrites.
+func (d *Database[M]) write(ctx context.Context, op string, fn func(context.Context) error) error {
+	err := observe(ctx, d.ops, d.uri, op, fn)
+	if err == nil {
+		markWritten(ctx)
+	}
+	return err
 }
 
 // Store wraps grub.Store and registers with scio on creation.
 // Embed this type in your store structs to add custom methods.
 type Store[M any] struct {
 	*grub.Store[M]
+	provider grub.StoreProvider
+	uri      string
 }
 
 // NewStore creates a Store[M] and registers it with the scio catalog.
+// Operations are counted if metrics are already served, and traced if
+// tracing is already on.
 // Requires sum.New() to have been called first.
-func NewStore[M any](provider grub.StoreProvider, name string) (*Store[M], error) {
-	store := grub.NewStore[M](provider)
-	if err := svc().catalog.RegisterStore("kv://"+name, store.Atomic()); err != nil {
+func NewStore[M any](provider grub.StoreProvider, name string, opts ...ResourceOption) (*Store[M], error) {
+	s := svc()
+	uri := "kv://" + name
+	observed := provider
+	if ops, ok := s.observing(); ok {
+		observed = observedStore{StoreProvider: provider, ops: ops, uri: uri}
+	}
+	store := grub.NewStore[M](observed)
+	r := newResourceRecord(reflect.TypeFor[M](), providerName(provider), opts)
+	if err := s.catalog.RegisterStore(uri, store.Atomic(), r.registrationOptions()...); err != nil {
 		return nil, err
 	}
-	return &Store[M]{Store: store}, nil
+	s.addResource(uri, r)
+	return &Store[M]{Store: store, provider: observed, uri: uri}, nil
 }
 
 // Bucket wraps grub.Bucket and registers with scio on creation.
@@ -48,11 +280,143 @@ type Bucket[M any] struct {
 }
 
 // NewBucket creates a Bucket[M] and registers it with the scio catalog.
+// Operations are counted if metrics are already served, and traced if
+// tracing is already on.
 // Requires sum.New() to have been called first.
-func NewBucket[M any](provider grub.BucketProvider, name string) (*Bucket[M], error) {
-	bucket := grub.NewBucket[M](provider)
-	if err := svc().catalog.RegisterBucket("bcs://"+name, bucket.Atomic()); err != nil {
+func NewBucket[M any](provider grub.BucketProvider, name string, opts ...ResourceOption) (*Bucket[M], error) {
+	s := svc()
+	uri := "bcs://" + name
+	observed := provider
+	if ops, ok := s.observing(); ok {
+		observed = observedBucket{BucketProvider: provider, ops: ops, uri: uri}
+	}
+	bucket := grub.NewBucket[M](observed)
+	r := newResourceRecord(reflect.TypeFor[M](), providerName(provider), opts)
+	if err := s.catalog.RegisterBucket(uri, bucket.Atomic(), r.registrationOptions()...); err != nil {
 		return nil, err
 	}
+	s.addResource(uri, r)
 	return &Bucket[M]{Bucket: bucket}, nil
 }
+
+// observe runs a data operation on a catalog resource inside a span and
+// counts its outcome.
+func observe(ctx context.Context, ops *Counter, uri, op string, fn func(context.Context) error) error {
+	ctx, span := StartSpan(ctx, op+" "+uri)
+	span.SetAttr("data.uri", uri)
+	err := fn(ctx)
+	span.RecordError(err)
+	span.End()
+	if ops != nil {
+		ops.Inc(uri, op, outcome(err))
+	}
+	return err
+}
+
+// outcome labels an operation result.
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// observedStore observes operations against a catalog store.
+type observedStore struct {
+	grub.StoreProvider
+	ops *Counter
+	uri string
+}
+
+func (o observedStore) Get(ctx context.Context, key string) (b []byte, err error) {
+	err = observe(ctx, o.ops, o.uri, "get", func(ctx context.Context) error {
+		b, err = o.StoreProvider.Get(ctx, key)
+		return err
+	})
+	return b, err
+}
+
+func (o observedStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return observe(ctx, o.ops, o.uri, "set", func(ctx context.Context) error {
+		return o.StoreProvider.Set(ctx, key, value, ttl)
+	})
+}
+
+func (o observedStore) Delete(ctx context.Context, key string) error {
+	return observe(ctx, o.ops, o.uri, "delete", func(ctx context.Context) error {
+		return o.StoreProvider.Delete(ctx, key)
+	})
+}
+
+func (o observedStore) Exists(ctx context.Context, key string) (ok bool, err error) {
+	err = observe(ctx, o.ops, o.uri, "exists", func(ctx context.Context) error {
+		ok, err = o.StoreProvider.Exists(ctx, key)
+		return err
+	})
+	return ok, err
+}
+
+func (o observedStore) List(ctx context.Context, prefix string, limit int) (keys []string, err error) {
+	err = observe(ctx, o.ops, o.uri, "list", func(ctx context.Context) error {
+		keys, err = o.StoreProvider.List(ctx, prefix, limit)
+		return err
+	})
+	return keys, err
+}
+
+func (o observedStore) GetBatch(ctx context.Context, keys []string) (items map[string][]byte, err error) {
+	err = observe(ctx, o.ops, o.uri, "get_batch", func(ctx context.Context) error {
+		items, err = o.StoreProvider.GetBatch(ctx, keys)
+		return err
+	})
+	return items, err
+}
+
+func (o observedStore) SetBatch(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	return observe(ctx, o.ops, o.uri, "set_batch", func(ctx context.Context) error {
+		return o.StoreProvider.SetBatch(ctx, items, ttl)
+	})
+}
+
+// observedBucket observes operations against a catalog bucket.
+type observedBucket struct {
+	grub.BucketProvider
+	ops *Counter
+	uri string
+}
+
+func (o observedBucket) Get(ctx context.Context, key string) (data []byte, info *grub.ObjectInfo, err error) {
+	err = observe(ctx, o.ops, o.uri, "get", func(ctx context.Context) error {
+		data, info, err = o.BucketProvider.Get(ctx, key)
+		return err
+	})
+	return data, info, err
+}
+
+func (o observedBucket) Put(ctx context.Context, key string, data []byte, info *grub.ObjectInfo) error {
+	return observe(ctx, o.ops, o.uri, "put", func(ctx context.Context) error {
+		return o.BucketProvider.Put(ctx, key, data, info)
+	})
+}
+
+func (o observedBucket) Delete(ctx context.Context, key string) error {
+	return observe(ctx, o.ops, o.uri, "delete", func(ctx context.Context) error {
+		return o.BucketProvider.Delete(ctx, key)
+	})
+}
+
+func (o observedBucket) Exists(ctx context.Context, key string) (ok bool, err error) {
+	err = observe(ctx, o.ops, o.uri, "exists", func(ctx context.Context) error {
+		ok, err = o.BucketProvider.Exists(ctx, key)
+		return err
+	})
+	return ok, err
+}
+
+func (o observedBucket) List(ctx context.Context, prefix string, limit int) (infos []grub.ObjectInfo, err error) {
+	err = observe(ctx, o.ops, o.uri, "list", func(ctx context.Context) error {
+		infos, err = o.BucketProvider.List(ctx, prefix, limit)
+		return err
+	})
+	return infos, err
+}
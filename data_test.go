@@ -4,6 +4,9 @@ package sum
 
 import (
 	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zoobzio/astql/postgres"
 )
 
 // Note: Full integration tests for Database, Store, and Bucket
@@ -17,6 +20,27 @@ type testModel struct {
 	Name string `db:"name"`
 }
 
+// newTestDB resets the service and opens a fresh pool on the shared test driver.
+func newTestDB(t *testing.T) (*sqlx.DB, *testMigrateDB) {
+	t.Helper()
+	Reset()
+	t.Cleanup(Reset)
+	New()
+	return openTestMigrateDB(t, "postgres")
+}
+
+// newTestDatabase resets the service and registers a users Database on the
+// shared test driver.
+func newTestDatabase(t *testing.T) (*Database[testModel], *testMigrateDB) {
+	t.Helper()
+	db, state := newTestDB(t)
+	d, err := NewDatabase[testModel](db, "users", postgres.New())
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	return d, state
+}
+
 func TestNewDatabase(t *testing.T) {
 	t.Skip("requires database connection - see testing/integration/data_test.go")
 }
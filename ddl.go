@@ -0,0 +1,415 @@
+package sum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zoobzio/astql"
+	"github.com/zoobzio/astql/mariadb"
+	"github.com/zoobzio/astql/mssql"
+	"github.com/zoobzio/astql/postgres"
+	"github.com/zoobzio/astql/sqlite"
+	"github.com/zoobzio/sentinel"
+)
+
+// ErrUnknownDialect is returned when DDL is requested for a renderer sum
+// does not recognise.
+var ErrUnknownDialect = errors.New("ddl: unknown SQL dialect")
+
+// The DDL tags follow the vocabulary grub's query layer already reads:
+//
+//	db:"email"                 column name; fields without one are skipped
+//	type:"VARCHAR(320)"        column type, overriding the inferred one
+//	constraints:"notnull"      any of primary_key, autoincrement, notnull,
+//	                           unique; primarykey, auto_increment and
+//	                           not_null are accepted as spellings
+//	default:"now()"            column default, as raw SQL
+//	check:"age >= 0"           column check constraint, as raw SQL
+//	index:"idx_users_email"    index name; fields sharing a name form one index
+//	references:"orgs(id)"      foreign key
+func init() {
+	for _, tag := range []string{"type", "constraints", "default", "check", "index", "references"} {
+		sentinel.Tag(tag)
+	}
+}
+
+// dialect holds what DDL generation needs to know about a database.
+type dialect struct {
+	quote  func(string) string
+	table  func(string) string
+	types  func(t reflect.Type, keyed bool) string
+	serial func(t reflect.Type) string
+}
+
+// dialectOf maps a renderer to its dialect.
+func dialectOf(renderer astql.Renderer) (dialect, error) {
+	var d dialect
+	switch r := renderer.(type) {
+	case *schemaRenderer:
+		d, err := dialectOf(r.Renderer)
+		if err != nil {
+			return dialect{}, err
+		}
+		d.table = func(name string) string {
+			if name == r.table || name == r.schema+"."+r.table {
+				return r.qualified
+			}
+			return d.quote(name)
+		}
+		return d, nil
+	case *postgres.Renderer:
+		d = dialect{quote: quoteWith(`"`, `"`), types: postgresType, serial: postgresSerial}
+	case *sqlite.Renderer:
+		d = dialect{quote: quoteWith(`"`, `"`), types: sqliteType, serial: sqliteSerial}
+	case *mariadb.Renderer:
+		d = dialect{quote: quoteWith("`", "`"), types: mariadbType, serial: mariadbSerial}
+	case *mssql.Renderer:
+		d = dialect{quote: quoteWith("[", "]"), types: mssqlType, serial: mssqlSerial}
+	default:
+		return dialect{}, fmt.Errorf("%w: %T", ErrUnknownDialect, renderer)
+	}
+	d.table = d.quote
+	return d, nil
+}
+
+// quoteWith quotes identifiers between open and closing delimiters,
+// doubling any closing delimiter inside the name.
+func quoteWith(open, closing string) func(string) string {
+	return func(name string) string {
+		return open + strings.ReplaceAll(name, closing, closing+closing) + closing
+	}
+}
+
+// ddlColumn is one column derived from a model field.
+type ddlColumn struct {
+	name       string
+	sqlType    string
+	def        string
+	check      string
+	references string
+	index      string
+	primaryKey bool
+	serial     bool
+	unique     bool
+	notNull    bool
+}
+
+// ddlColumns reads the columns of M from its sentinel metadata. Columns are
+// NOT NULL only when tagged notnull or primary_key. An integer primary key
+// tagged autoincrement gets the dialect's generated key type. Unknown
+// constraints are rejected rather than silently dropped from the DDL.
+func ddlColumns(meta sentinel.Metadata, d dialect) ([]ddlColumn, error) {
+	var cols []ddlColumn
+	for _, f := range meta.Fields {
+		name, ok := columnName(f)
+		if !ok {
+			continue
+		}
+		col := ddlColumn{
+			name:       name,
+			sqlType:    f.Tags["type"],
+			def:        f.Tags["default"],
+			check:      f.Tags["check"],
+			references: f.Tags["references"],
+			index:      f.Tags["index"],
+		}
+		for _, c := range strings.Split(f.Tags["constraints"], ",") {
+			switch c = strings.TrimSpace(c); c {
+			case "":
+			case "primary_key", "primarykey":
+				col.primaryKey = true
+			case "autoincrement", "auto_increment":
+				col.serial = true
+			case "notnull", "not_null":
+				col.notNull = true
+			case "unique":
+				col.unique = true
+			default:
+				return nil, fmt.Errorf("ddl: field %s: unknown constraint %q", f.Name, c)
+			}
+		}
+		t := f.ReflectType
+		if t == nil {
+			return nil, fmt.Errorf("ddl: field %s has no type", f.Name)
+		}
+		if col.primaryKey {
+			col.notNull = true
+		}
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if col.serial {
+			if !col.primaryKey || !isInteger(t) {
+				return nil, fmt.Errorf("ddl: field %s: autoincrement needs an integer primary key", f.Name)
+			}
+			if col.sqlType == "" {
+				col.sqlType = d.serial(t)
+			}
+		}
+		if col.sqlType == "" {
+			col.sqlType = d.types(t, col.primaryKey || col.unique || col.index != "")
+		}
+		cols = append(cols, col)
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("ddl: %s has no db-tagged fields", meta.FQDN)
+	}
+	return cols, nil
+}
+
+// DDL returns the statements that create table for M in the dialect of
+// renderer: a CREATE TABLE followed by one CREATE INDEX per index tag.
+// Statements carry no trailing semicolon, so each can be executed on its own.
+func DDL[M any](table string, renderer astql.Renderer) ([]string, error) {
+	d, err := dialectOf(renderer)
+	if err != nil {
+		return nil, err
+	}
+	cols, err := ddlColumns(sentinel.Inspect[M](), d)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs, keys []string
+	var indexes []string
+	indexCols := make(map[string][]string)
+	for _, col := range cols {
+		def := d.quote(col.name) + " " + col.sqlType
+		if col.notNull {
+			def += " NOT NULL"
+		}
+		if col.unique && !col.primaryKey {
+			def += " UNIQUE"
+		}
+		if col.def != "" {
+			def += " DEFAULT " + col.def
+		}
+		if col.check != "" {
+			def += " CHECK (" + col.check + ")"
+		}
+		if col.references != "" {
+			refTable, refCol, ok := strings.Cut(strings.TrimSuffix(col.references, ")"), "(")
+			if !ok || refTable == "" || refCol == "" {
+				return nil, fmt.Errorf("ddl: column %s: references must be table(column), got %q", col.name, col.references)
+			}
+			def += " REFERENCES " + d.quote(refTable) + " (" + d.quote(refCol) + ")"
+		}
+		defs = append(defs, def)
+		if col.primaryKey {
+			keys = append(keys, d.quote(col.name))
+		}
+		if col.index != "" {
+			if _, ok := indexCols[col.index]; !ok {
+				indexes = append(indexes, col.index)
+			}
+			indexCols[col.index] = append(indexCols[col.index], d.quote(col.name))
+		}
+	}
+	if len(keys) > 0 {
+		defs = append(defs, "PRIMARY KEY ("+strings.Join(keys, ", ")+")")
+	}
+
+	stmts := []string{"CREATE TABLE " + d.table(table) + " (\n  " + strings.Join(defs, ",\n  ") + "\n)"}
+	for _, name := range indexes {
+		stmts = append(stmts, "CREATE INDEX "+d.quote(name)+" ON "+d.table(table)+" ("+strings.Join(indexCols[name], ", ")+")")
+	}
+	return stmts, nil
+}
+
+// CreateTable creates table for M, e.g. to bootstrap a schema in tests.
+func CreateTable[M any](ctx context.Context, db *sqlx.DB, table string, renderer astql.Renderer) error {
+	stmts, err := DDL[M](table, renderer)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("create %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// TableMigration returns a migration creating table for M, with a down
+// step that drops it. Write it out with Migration.WriteFiles to start a
+// migrations directory for a new model.
+func TableMigration[M any](version int64, table string, renderer astql.Renderer) (Migration, error) {
+	stmts, err := DDL[M](table, renderer)
+	if err != nil {
+		return Migration{}, err
+	}
+	d, err := dialectOf(renderer)
+	if err != nil {
+		return Migration{}, err
+	}
+	return Migration{
+		Version: version,
+		Name:    "create_" + table,
+		Up:      strings.Join(stmts, ";\n\n") + ";\n",
+		Down:    "DROP TABLE " + d.table(table) + ";\n",
+	}, nil
+}
+
+// WriteFiles writes the migration into dir as files NewMigrator loads.
+func (m Migration) WriteFiles(dir string) error {
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", m.Version, m.Name))
+	if err := os.WriteFile(base+".up.sql", []byte(m.Up), 0o644); err != nil {
+		return err
+	}
+	if m.Down == "" {
+		return nil
+	}
+	return os.WriteFile(base+".down.sql", []byte(m.Down), 0o644)
+}
+
+// DDL returns the statements that create this database's table.
+func (d *Database[M]) DDL() ([]string, error) {
+	return DDL[M](strings.TrimPrefix(d.uri, "db://"), d.renderer)
+}
+
+// postgresType infers a PostgreSQL column type.
+func postgresType(t reflect.Type, _ bool) string {
+	switch {
+	case t == timeType:
+		return "TIMESTAMPTZ"
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return "BYTEA"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "TEXT"
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return "SMALLINT"
+	case reflect.Int32, reflect.Uint16:
+		return "INTEGER"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return "BIGINT"
+	case reflect.Float32:
+		return "REAL"
+	case reflect.Float64:
+		return "DOUBLE PRECISION"
+	}
+	return "JSONB"
+}
+
+// sqliteType infers a SQLite column type.
+func sqliteType(t reflect.Type, _ bool) string {
+	switch {
+	case t == timeType:
+		return "TIMESTAMP"
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return "BLOB"
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "INTEGER"
+	case reflect.Float32, reflect.Float64:
+		return "REAL"
+	}
+	return "TEXT"
+}
+
+// mariadbType infers a MariaDB column type. Keyed strings use VARCHAR, since
+// TEXT columns cannot be indexed without a prefix length.
+func mariadbType(t reflect.Type, keyed bool) string {
+	switch {
+	case t == timeType:
+		return "DATETIME(6)"
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return "BLOB"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		if keyed {
+			return "VARCHAR(255)"
+		}
+		return "TEXT"
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return "SMALLINT"
+	case reflect.Int32, reflect.Uint16:
+		return "INT"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return "BIGINT"
+	case reflect.Float32:
+		return "FLOAT"
+	case reflect.Float64:
+		return "DOUBLE"
+	}
+	return "JSON"
+}
+
+// mssqlType infers a SQL Server column type. Keyed strings are limited to
+// 450 characters, the widest NVARCHAR an index key allows.
+func mssqlType(t reflect.Type, keyed bool) string {
+	switch {
+	case t == timeType:
+		return "DATETIME2"
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return "VARBINARY(MAX)"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		if keyed {
+			return "NVARCHAR(450)"
+		}
+		return "NVARCHAR(MAX)"
+	case reflect.Bool:
+		return "BIT"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return "SMALLINT"
+	case reflect.Int32, reflect.Uint16:
+		return "INT"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return "BIGINT"
+	case reflect.Float32:
+		return "REAL"
+	case reflect.Float64:
+		return "FLOAT"
+	}
+	return "NVARCHAR(MAX)"
+}
+
+// isInteger reports whether t is a signed or unsigned integer kind.
+func isInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// postgresSerial is the PostgreSQL type of a generated integer key.
+func postgresSerial(t reflect.Type) string {
+	if postgresType(t, true) == "BIGINT" {
+		return "BIGSERIAL"
+	}
+	return "SERIAL"
+}
+
+// sqliteSerial is the SQLite type of a generated integer key. A single
+// INTEGER primary key aliases the rowid, which SQLite assigns on insert.
+func sqliteSerial(reflect.Type) string {
+	return "INTEGER"
+}
+
+// mariadbSerial is the MariaDB type of a generated integer key.
+func mariadbSerial(t reflect.Type) string {
+	return mariadbType(t, true) + " AUTO_INCREMENT"
+}
+
+// mssqlSerial is the SQL Server type of a generated integer key.
+func mssqlSerial(t reflect.Type) string {
+	return mssqlType(t, true) + " IDENTITY(1,1)"
+}
@@ -0,0 +1,163 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/astql"
+	"github.com/zoobzio/astql/mariadb"
+	"github.com/zoobzio/astql/mssql"
+	"github.com/zoobzio/astql/postgres"
+	"github.com/zoobzio/astql/sqlite"
+)
+
+type testDDLUser struct {
+	CreatedAt time.Time `db:"created_at" constraints:"notnull" default:"now()"`
+	Nickname  *string   `db:"nickname"`
+	Tags      []string  `db:"tags"`
+	ID        string    `db:"id" constraints:"primary_key"`
+	Email     string    `db:"email" constraints:"not_null, unique" type:"VARCHAR(320)"`
+	OrgID     string    `db:"org_id" constraints:"notnull" references:"orgs(id)" index:"idx_users_org"`
+	Age       int       `db:"age" constraints:"notnull" check:"age >= 0" index:"idx_users_org"`
+	Internal  string
+}
+
+func TestDDLPostgres(t *testing.T) {
+	stmts, err := DDL[testDDLUser]("users", postgres.New())
+	if err != nil {
+		t.Fatalf("DDL: %v", err)
+	}
+	want := []string{
+		`CREATE TABLE "users" (
+  "created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+  "nickname" TEXT,
+  "tags" JSONB,
+  "id" TEXT NOT NULL,
+  "email" VARCHAR(320) NOT NULL UNIQUE,
+  "org_id" TEXT NOT NULL REFERENCES "orgs" ("id"),
+  "age" BIGINT NOT NULL CHECK (age >= 0),
+  PRIMARY KEY ("id")
+)`,
+		`CREATE INDEX "idx_users_org" ON "users" ("org_id", "age")`,
+	}
+	if len(stmts) != len(want) {
+		t.Fatalf("expected %d statements, got %q", len(want), stmts)
+	}
+	for i := range want {
+		if stmts[i] != want[i] {
+			t.Errorf("statement %d:\nwant %s\ngot  %s", i, want[i], stmts[i])
+		}
+	}
+}
+
+func TestDDLDialects(t *testing.T) {
+	tests := []struct {
+		renderer astql.Renderer
+		contains []string
+	}{
+		{sqlite.New(), []string{`"age" INTEGER NOT NULL`, `"created_at" TIMESTAMP NOT NULL`}},
+		{mariadb.New(), []string{"`id` VARCHAR(255) NOT NULL", "`nickname` TEXT,", "`tags` JSON,"}},
+		{mssql.New(), []string{"[id] NVARCHAR(450) NOT NULL", "[nickname] NVARCHAR(MAX),", "PRIMARY KEY ([id])"}},
+	}
+	for _, tt := range tests {
+		stmts, err := DDL[testDDLUser]("users", tt.renderer)
+		if err != nil {
+			t.Fatalf("DDL %T: %v", tt.renderer, err)
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(stmts[0], s) {
+				t.Errorf("%T: expected %q in\n%s", tt.renderer, s, stmts[0])
+			}
+		}
+	}
+}
+
+func TestDDLStoredEventDialects(t *testing.T) {
+	tests := []struct {
+		renderer astql.Renderer
+		contains []string
+	}{
+		{postgres.New(), []string{`"id" BIGSERIAL NOT NULL`, `"payload" BYTEA NOT NULL`, `"recorded_at" TIMESTAMPTZ NOT NULL`}},
+		{sqlite.New(), []string{`"id" INTEGER NOT NULL`, `"payload" BLOB NOT NULL`, `"version" INTEGER NOT NULL`}},
+		{mariadb.New(), []string{"`id` BIGINT AUTO_INCREMENT NOT NULL", "`key` VARCHAR(255) NOT NULL UNIQUE", "`recorded_at` DATETIME(6) NOT NULL"}},
+		{mssql.New(), []string{"[id] BIGINT IDENTITY(1,1) NOT NULL", "[payload] VARBINARY(MAX) NOT NULL", "[key] NVARCHAR(450) NOT NULL UNIQUE"}},
+	}
+	for _, tt := range tests {
+		stmts, err := DDL[StoredEvent]("events", tt.renderer)
+		if err != nil {
+			t.Fatalf("DDL %T: %v", tt.renderer, err)
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(stmts[0], s) {
+				t.Errorf("%T: expected %q in\n%s", tt.renderer, s, stmts[0])
+			}
+		}
+	}
+}
+
+type testRenderer struct{ astql.Renderer }
+
+func TestDDLErrors(t *testing.T) {
+	if _, err := DDL[testDDLUser]("users", testRenderer{}); !errors.Is(err, ErrUnknownDialect) {
+		t.Errorf("expected ErrUnknownDialect, got %v", err)
+	}
+	if _, err := DDL[testEventInt]("events", postgres.New()); err == nil {
+		t.Error("expected error for model without db tags")
+	}
+	type badRef struct {
+		OrgID string `db:"org_id" references:"orgs"`
+	}
+	if _, err := DDL[badRef]("users", postgres.New()); err == nil {
+		t.Error("expected error for malformed references tag")
+	}
+	type badConstraint struct {
+		ID string `db:"id" constraints:"primary_key,nonnull"`
+	}
+	if _, err := DDL[badConstraint]("users", postgres.New()); err == nil || !strings.Contains(err.Error(), "nonnull") {
+		t.Errorf("expected error naming the unknown constraint, got %v", err)
+	}
+	type badSerial struct {
+		ID string `db:"id" constraints:"primary_key,autoincrement"`
+	}
+	if _, err := DDL[badSerial]("users", postgres.New()); err == nil || !strings.Contains(err.Error(), "autoincrement") {
+		t.Errorf("expected error for autoincrement on a string key, got %v", err)
+	}
+}
+
+func TestCreateTable(t *testing.T) {
+	db, state := openTestMigrateDB(t, "postgres")
+	if err := CreateTable[testDDLUser](context.Background(), db, "users", postgres.New()); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	if len(state.log) != 2 || !strings.HasPrefix(state.log[0], `CREATE TABLE "users"`) || !strings.HasPrefix(state.log[1], "CREATE INDEX") {
+		t.Errorf("expected table and index created, got %q", state.log)
+	}
+}
+
+func TestTableMigrationRoundTrip(t *testing.T) {
+	m, err := TableMigration[testDDLUser](1, "users", postgres.New())
+	if err != nil {
+		t.Fatalf("TableMigration: %v", err)
+	}
+	dir := t.TempDir()
+	if err := m.WriteFiles(dir); err != nil {
+		t.Fatalf("WriteFiles: %v", err)
+	}
+	loaded, err := LoadMigrations(os.DirFS(dir), ".")
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	if len(loaded) != 1 || loaded[0] != m {
+		t.Fatalf("expected written migration to load back, got %+v", loaded)
+	}
+	if loaded[0].Name != "create_users" || loaded[0].Down != "DROP TABLE \"users\";\n" ||
+		!strings.Contains(loaded[0].Up, ");\n\nCREATE INDEX") {
+		t.Errorf("unexpected migration %+v", loaded[0])
+	}
+}
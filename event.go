@@ -2,6 +2,7 @@ package sum
 
 import (
 	"context"
+	"sync"
 
 	"github.com/zoobzio/capitan"
 	"github.com/zoobzio/sentinel"
@@ -49,48 +50,97 @@ type Event[T any] struct {
 
 	// level determines the severity used when emitting.
 	level capitan.Severity
+
+	// version is the payload version, or 0 if unversioned.
+	version int
 }
 
 // Emit dispatches an event with the configured severity level.
+// A correlation ID in ctx is attached as the KeyCorrelationID field.
 func (e Event[T]) Emit(ctx context.Context, data T) {
+	ctx, span := StartSpan(ctx, "emit "+e.Signal.Name())
+	defer span.End()
+	fields := append([]capitan.Field{e.Key.Field(data)}, correlationFields(ctx)...)
 	switch e.level {
 	case capitan.SeverityDebug:
-		capitan.Debug(ctx, e.Signal, e.Key.Field(data))
+		capitan.Debug(ctx, e.Signal, fields...)
 	case capitan.SeverityInfo:
-		capitan.Info(ctx, e.Signal, e.Key.Field(data))
+		capitan.Info(ctx, e.Signal, fields...)
 	case capitan.SeverityWarn:
-		capitan.Warn(ctx, e.Signal, e.Key.Field(data))
+		capitan.Warn(ctx, e.Signal, fields...)
 	case capitan.SeverityError:
-		capitan.Error(ctx, e.Signal, e.Key.Field(data))
+		capitan.Error(ctx, e.Signal, fields...)
 	default:
-		capitan.Emit(ctx, e.Signal, e.Key.Field(data))
+		capitan.Emit(ctx, e.Signal, fields...)
 	}
 }
 
 // Listen registers a callback for this event.
-// Returns a Listener that can be closed to unregister.
-func (e Event[T]) Listen(callback func(context.Context, T)) *capitan.Listener {
-	return capitan.Hook(e.Signal, func(ctx context.Context, ev *capitan.Event) {
-		if data, ok := e.Key.From(ev); ok {
-			callback(ctx, data)
+// Versioned events also receive older emissions, upcast to T.
+// Returns a Listener that can be closed to unregister; any still open are
+// drained and closed by Service.Shutdown.
+func (e Event[T]) Listen(callback func(context.Context, T)) *Listener {
+	l := capitan.Hook(e.Signal, func(ctx context.Context, ev *capitan.Event) {
+		if data, ok := e.extract(ev); ok {
+			e.call(eventContext(ctx, ev), data, callback)
 		}
 	})
+	return newListener(l)
+}
+
+// call runs a listener callback inside a span.
+func (e Event[T]) call(ctx context.Context, data T, callback func(context.Context, T)) {
+	ctx, span := StartSpan(ctx, "listen "+e.Signal.Name())
+	defer span.End()
+	callback(ctx, data)
+}
+
+// ListenContext registers a callback that is unregistered when ctx is done.
+// Closing the Listener first also releases its hook on ctx.
+func (e Event[T]) ListenContext(ctx context.Context, callback func(context.Context, T)) *Listener {
+	l := e.Listen(callback)
+	stop := context.AfterFunc(ctx, l.Close)
+	l.mu.Lock()
+	l.stop = stop
+	l.mu.Unlock()
+	return l
 }
 
 // ListenOnce registers a callback that fires only once, then automatically unregisters.
 // Returns a Listener that can be closed early to prevent the callback from firing.
-func (e Event[T]) ListenOnce(callback func(context.Context, T)) *capitan.Listener {
-	return capitan.HookOnce(e.Signal, func(ctx context.Context, ev *capitan.Event) {
-		if data, ok := e.Key.From(ev); ok {
-			callback(ctx, data)
+func (e Event[T]) ListenOnce(callback func(context.Context, T)) *Listener {
+	var (
+		mu    sync.Mutex
+		self  *capitan.Listener
+		fired bool
+	)
+	l := capitan.HookOnce(e.Signal, func(ctx context.Context, ev *capitan.Event) {
+		if data, ok := e.extract(ev); ok {
+			e.call(eventContext(ctx, ev), data, callback)
+		}
+		mu.Lock()
+		fired = true
+		done := self
+		mu.Unlock()
+		if done != nil {
+			untrack(done)
 		}
 	})
+	mu.Lock()
+	self = l
+	if !fired {
+		track(l)
+	}
+	mu.Unlock()
+	return &Listener{Listener: l}
 }
 
 // NewEvent creates an Event with the given signal and severity level.
-// The variant is derived automatically from T via sentinel.
+// The variant is derived automatically from T via sentinel, and the event is
+// recorded in the catalog returned by Events.
 func NewEvent[T any](signal capitan.Signal, level capitan.Severity) Event[T] {
 	meta := sentinel.Inspect[T]()
+	registerEvent(signal, level, meta, 0)
 	return Event[T]{
 		Signal: signal,
 		Key:    capitan.NewKey[T]("data", capitan.Variant(meta.FQDN)),
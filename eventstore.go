@@ -0,0 +1,336 @@
+package sum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zoobzio/astql"
+	"github.com/zoobzio/edamame"
+	"github.com/zoobzio/grub"
+)
+
+// ErrConcurrency indicates a stream was appended to since the caller loaded it.
+var ErrConcurrency = errors.New("stream version conflict")
+
+// StoredEvent is a single persisted event in a stream.
+type StoredEvent struct {
+	RecordedAt   time.Time `db:"recorded_at" constraints:"notnull"`
+	Key          string    `db:"key" constraints:"notnull,unique"`
+	Stream       string    `db:"stream" constraints:"notnull"`
+	Signal       string    `db:"signal" constraints:"notnull"`
+	Payload      []byte    `db:"payload" constraints:"notnull"`
+	ID           int64     `db:"id" constraints:"primarykey,autoincrement"`
+	Version      int       `db:"version" constraints:"notnull"`
+	EventVersion int       `db:"event_version" constraints:"notnull"`
+}
+
+// Event store statements.
+var (
+	headLimit = 1
+
+	eventStreamQuery = edamame.NewQueryStatement("event-stream", "Events in a stream after a version", edamame.QuerySpec{
+		Where: []edamame.ConditionSpec{
+			{Field: "stream", Operator: "=", Param: "stream"},
+			{Field: "version", Operator: ">", Param: "after"},
+		},
+		OrderBy: []edamame.OrderBySpec{{Field: "version", Direction: "asc"}},
+	})
+
+	eventHeadQuery = edamame.NewQueryStatement("event-head", "Latest event in a stream", edamame.QuerySpec{
+		Where:      []edamame.ConditionSpec{{Field: "stream", Operator: "=", Param: "stream"}},
+		OrderBy:    []edamame.OrderBySpec{{Field: "version", Direction: "desc"}},
+		Limit:      &headLimit,
+		ForLocking: "update",
+	})
+)
+
+// EventStore persists typed events as append-only streams.
+// Appended events are re-emitted after commit so projections can listen
+// with ordinary Event[T] listeners.
+type EventStore struct {
+	db     *sqlx.DB
+	events *Database[StoredEvent]
+}
+
+// NewEventStore creates an EventStore backed by table.
+// Requires sum.New() to have been called first.
+func NewEventStore(db *sqlx.DB, table string, renderer astql.Renderer) (*EventStore, error) {
+	events, err := NewDatabase[StoredEvent](db, table, renderer)
+	if err != nil {
+		return nil, err
+	}
+	return &EventStore{db: db, events: events}, nil
+}
+
+// Change is a typed event pending append.
+type Change struct {
+	emit    func(context.Context)
+	signal  string
+	payload []byte
+	version int
+}
+
+// NewChange encodes data for e with the Service codec.
+func NewChange[T any](e Event[T], data T) (Change, error) {
+	payload, err := svc().codecOrDefault().Marshal(data)
+	if err != nil {
+		return Change{}, err
+	}
+	return Change{
+		emit:    func(ctx context.Context) { e.Emit(ctx, data) },
+		signal:  e.Signal.Name(),
+		payload: payload,
+		version: e.Version(),
+	}, nil
+}
+
+// record builds the row for a change at a stream position.
+func (c Change) record(stream string, version int, now time.Time) *StoredEvent {
+	return &StoredEvent{
+		Key:          stream + ":" + strconv.Itoa(version),
+		Stream:       stream,
+		Version:      version,
+		Signal:       c.signal,
+		EventVersion: c.version,
+		Payload:      c.payload,
+		RecordedAt:   now,
+	}
+}
+
+// Append writes changes to stream if its current version equals expected,
+// returning the new version. Use 0 as expected for a new stream.
+// Returns ErrConcurrency if another writer got there first, whether caught by
+// the head check or by the unique key on insert.
+//
+// Inside Transact the append joins the transaction as a savepoint, and its
+// events are re-emitted once the outer transaction commits.
+func (s *EventStore) Append(ctx context.Context, stream string, expected int, changes ...Change) (int, error) {
+	if len(changes) == 0 {
+		return expected, nil
+	}
+	emitCtx := ctx
+	err := TransactOn(ctx, s.db, func(ctx context.Context) error {
+		head, err := s.events.ExecQuery(ctx, eventHeadQuery, map[string]any{"stream": stream})
+		if err != nil {
+			return err
+		}
+		current := 0
+		if len(head) > 0 {
+			current = head[0].Version
+		}
+		if current != expected {
+			return fmt.Errorf("%w: %s is at %d, expected %d", ErrConcurrency, stream, current, expected)
+		}
+
+		now := time.Now().UTC()
+		for i, c := range changes {
+			// The unique key column rejects a concurrent writer that passed the head check.
+			if _, err := s.events.ExecInsert(ctx, c.record(stream, expected+i+1, now)); err != nil {
+				if isUniqueViolation(err) {
+					return fmt.Errorf("%w: %s moved past %d", ErrConcurrency, stream, expected)
+				}
+				return fmt.Errorf("append %s: %w", stream, err)
+			}
+		}
+		afterCommit(ctx, s.db, func() {
+			for _, c := range changes {
+				c.emit(emitCtx)
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return expected + len(changes), nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation,
+// judged by the driver's error code: SQLSTATE 23505 on PostgreSQL, error
+// 1062 on MySQL, 2627 or 2601 on SQL Server, and the extended codes 2067
+// or 1555 on SQLite. Drivers that expose codes only as struct fields are
+// read by reflection, so none of them need to be imported.
+func isUniqueViolation(err error) bool {
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) && state.SQLState() == "23505" {
+		return true
+	}
+	var number interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &number) {
+		if n := number.SQLErrorNumber(); n == 2627 || n == 2601 {
+			return true
+		}
+	}
+	var coded interface{ Code() int }
+	if errors.As(err, &coded) {
+		if c := coded.Code(); c == 2067 || c == 1555 {
+			return true
+		}
+	}
+	if n, ok := errorCode(err, "Number"); ok && n == 1062 {
+		return true
+	}
+	if c, ok := errorCode(err, "ExtendedCode"); ok && (c == 2067 || c == 1555) {
+		return true
+	}
+	return false
+}
+
+// errorCode finds the first error in err's chain that is a struct, or a
+// pointer to one, with an integer field called name, and returns its value.
+func errorCode(err error, name string) (int64, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		v := reflect.ValueOf(err)
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		f := v.FieldByName(name)
+		switch {
+		case !f.IsValid():
+		case f.CanInt():
+			return f.Int(), true
+		case f.CanUint():
+			return int64(f.Uint()), true
+		}
+	}
+	return 0, false
+}
+
+// Events returns a stream's events after version, in order.
+func (s *EventStore) Events(ctx context.Context, stream string, after int) ([]*StoredEvent, error) {
+	return s.events.ExecQuery(ctx, eventStreamQuery, map[string]any{"stream": stream, "after": after})
+}
+
+// Snapshot is a folded aggregate state at a stream version.
+type Snapshot[A any] struct {
+	Stream  string `json:"stream"`
+	State   A      `json:"state"`
+	Version int    `json:"version"`
+}
+
+// Aggregate folds a stream's events into state A.
+type Aggregate[A any] struct {
+	store     *EventStore
+	snapshots *Bucket[Snapshot[A]]
+	appliers  map[string]func(*A, *StoredEvent) error
+	every     int
+}
+
+// AggregateOption configures an Aggregate.
+type AggregateOption[A any] func(*Aggregate[A])
+
+// WithSnapshots stores a snapshot in bucket whenever Load folds at least every
+// events past the previous snapshot.
+func WithSnapshots[A any](bucket *Bucket[Snapshot[A]], every int) AggregateOption[A] {
+	return func(a *Aggregate[A]) {
+		a.snapshots = bucket
+		a.every = every
+	}
+}
+
+// NewAggregate creates an Aggregate reading from store.
+func NewAggregate[A any](store *EventStore, opts ...AggregateOption[A]) *Aggregate[A] {
+	a := &Aggregate[A]{
+		store:    store,
+		appliers: make(map[string]func(*A, *StoredEvent) error),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Fold registers how events of e change the aggregate. Stored payloads are
+// decoded and upcast to the current T, so older versions fold like new ones.
+func Fold[A, T any](a *Aggregate[A], e Event[T], fn func(*A, T)) {
+	a.appliers[e.Signal.Name()] = func(state *A, rec *StoredEvent) error {
+		data, err := e.Decode(rec.EventVersion, rec.Payload)
+		if err != nil {
+			return fmt.Errorf("fold %s@%d: %w", rec.Stream, rec.Version, err)
+		}
+		fn(state, data)
+		return nil
+	}
+}
+
+// apply folds a single stored event. Signals without a Fold are skipped.
+func (a *Aggregate[A]) apply(state *A, rec *StoredEvent) error {
+	fn, ok := a.appliers[rec.Signal]
+	if !ok {
+		return nil
+	}
+	return fn(state, rec)
+}
+
+// Load folds a stream into its current state and returns it with the version
+// to pass as expected to Append. Snapshots are an optimisation: a failed
+// snapshot write does not fail the load.
+func (a *Aggregate[A]) Load(ctx context.Context, stream string) (*A, int, error) {
+	var state A
+	version := 0
+	snapped := 0
+
+	if a.snapshots != nil {
+		snap, err := a.snapshot(ctx, stream)
+		if err != nil {
+			return nil, 0, err
+		}
+		if snap != nil {
+			state = snap.State
+			version = snap.Version
+			snapped = snap.Version
+		}
+	}
+
+	records, err := a.store.Events(ctx, stream, version)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, rec := range records {
+		if err := a.apply(&state, rec); err != nil {
+			return nil, 0, err
+		}
+		version = rec.Version
+	}
+
+	if a.snapshots != nil && a.every > 0 && version-snapped >= a.every {
+		_ = a.saveSnapshot(ctx, Snapshot[A]{Stream: stream, State: state, Version: version})
+	}
+	return &state, version, nil
+}
+
+// snapshot returns the stored snapshot for a stream, or nil if there is none.
+func (a *Aggregate[A]) snapshot(ctx context.Context, stream string) (*Snapshot[A], error) {
+	key := "snapshots/" + stream
+	ok, err := a.snapshots.Exists(ctx, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	obj, err := a.snapshots.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &obj.Data, nil
+}
+
+// saveSnapshot writes a snapshot, replacing any previous one. Buckets encode
+// objects as JSON, so the content type is the JSON codec's.
+func (a *Aggregate[A]) saveSnapshot(ctx context.Context, snap Snapshot[A]) error {
+	return a.snapshots.Put(ctx, &grub.Object[Snapshot[A]]{
+		Key:         "snapshots/" + snap.Stream,
+		ContentType: jsonCodec{}.ContentType(),
+		Metadata:    map[string]string{"version": strconv.Itoa(snap.Version)},
+		Data:        snap,
+	})
+}
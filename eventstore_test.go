@@ -0,0 +1,225 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/astql/postgres"
+	"github.com/zoobzio/capitan"
+)
+
+type testAccount struct {
+	Owner   string `json:"owner"`
+	Balance int    `json:"balance"`
+}
+
+type testOpened struct {
+	Owner string `json:"owner"`
+}
+
+type testDeposited struct {
+	Amount int `json:"amount"`
+}
+
+func TestChangeRecord(t *testing.T) {
+	resetAll(t)
+	New()
+
+	event := NewInfoEvent[testDeposited](capitan.NewSignal("test.es.record", "Deposited")).WithVersion(2)
+	c, err := NewChange(event, testDeposited{Amount: 5})
+	if err != nil {
+		t.Fatalf("NewChange: %v", err)
+	}
+
+	now := time.Now()
+	rec := c.record("acct-1", 3, now)
+	if rec.Key != "acct-1:3" || rec.Stream != "acct-1" || rec.Version != 3 {
+		t.Errorf("unexpected position %+v", rec)
+	}
+	if rec.Signal != "test.es.record" || rec.EventVersion != 2 || string(rec.Payload) != `{"amount":5}` {
+		t.Errorf("unexpected payload %+v", rec)
+	}
+
+	var got []testDeposited
+	l := event.Listen(func(_ context.Context, d testDeposited) { got = append(got, d) })
+	defer l.Close()
+	c.emit(context.Background())
+	if len(got) != 1 || got[0].Amount != 5 {
+		t.Errorf("expected change to re-emit its event, got %v", got)
+	}
+}
+
+func TestAggregateFold(t *testing.T) {
+	resetAll(t)
+	New()
+
+	opened := NewInfoEvent[testOpened](capitan.NewSignal("test.es.opened", "Opened"))
+	deposited := NewInfoEvent[testDeposited](capitan.NewSignal("test.es.deposited", "Deposited"))
+
+	agg := NewAggregate[testAccount](nil)
+	Fold(agg, opened, func(a *testAccount, e testOpened) { a.Owner = e.Owner })
+	Fold(agg, deposited, func(a *testAccount, e testDeposited) { a.Balance += e.Amount })
+
+	var changes []Change
+	for _, c := range []func() (Change, error){
+		func() (Change, error) { return NewChange(opened, testOpened{Owner: "ada"}) },
+		func() (Change, error) { return NewChange(deposited, testDeposited{Amount: 10}) },
+		func() (Change, error) { return NewChange(deposited, testDeposited{Amount: 5}) },
+	} {
+		change, err := c()
+		if err != nil {
+			t.Fatalf("NewChange: %v", err)
+		}
+		changes = append(changes, change)
+	}
+
+	var state testAccount
+	now := time.Now()
+	for i, c := range changes {
+		if err := agg.apply(&state, c.record("acct", i+1, now)); err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+	}
+	if state.Owner != "ada" || state.Balance != 15 {
+		t.Errorf("unexpected folded state %+v", state)
+	}
+
+	unknown := &StoredEvent{Signal: "test.es.unknown", Payload: []byte(`{}`)}
+	if err := agg.apply(&state, unknown); err != nil {
+		t.Errorf("unfolded signals should be skipped, got %v", err)
+	}
+}
+
+func TestAggregateSnapshot(t *testing.T) {
+	resetAll(t)
+	New()
+
+	bucket, err := NewBucket[Snapshot[testAccount]](NewMemoryBucket(), "snapshots")
+	if err != nil {
+		t.Fatalf("NewBucket: %v", err)
+	}
+	agg := NewAggregate(nil, WithSnapshots(bucket, 10))
+	ctx := context.Background()
+
+	if snap, err := agg.snapshot(ctx, "acct"); err != nil || snap != nil {
+		t.Fatalf("expected no snapshot, got %v %v", snap, err)
+	}
+
+	want := Snapshot[testAccount]{Stream: "acct", Version: 12, State: testAccount{Owner: "ada", Balance: 7}}
+	if err := agg.saveSnapshot(ctx, want); err != nil {
+		t.Fatalf("saveSnapshot: %v", err)
+	}
+	snap, err := agg.snapshot(ctx, "acct")
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if snap == nil || *snap != want {
+		t.Errorf("expected %+v, got %+v", want, snap)
+	}
+}
+
+// testSQLStateError is a driver error carrying a SQLSTATE code.
+type testSQLStateError string
+
+func (e testSQLStateError) Error() string    { return "driver error " + string(e) }
+func (e testSQLStateError) SQLState() string { return string(e) }
+
+// testMySQLError mirrors a driver error exposing its code only as a field.
+type testMySQLError struct {
+	Message string
+	Number  uint16
+}
+
+func (e *testMySQLError) Error() string { return e.Message }
+
+// testSQLiteError mirrors a driver error with an extended result code field.
+type testSQLiteError struct {
+	Code         int
+	ExtendedCode int
+}
+
+func (e testSQLiteError) Error() string { return "sqlite error" }
+
+func TestAppendMapsUniqueViolation(t *testing.T) {
+	resetAll(t)
+	New()
+	db, state := openTestMigrateDB(t, "postgres")
+	store, err := NewEventStore(db, "events", postgres.New())
+	if err != nil {
+		t.Fatalf("NewEventStore: %v", err)
+	}
+	deposited := NewInfoEvent[testDeposited](capitan.NewSignal("test.es.unique", "Deposited"))
+	change, err := NewChange(deposited, testDeposited{Amount: 1})
+	if err != nil {
+		t.Fatalf("NewChange: %v", err)
+	}
+
+	insertErr := error(testSQLStateError("23505"))
+	state.execErr = func(query string) error {
+		if strings.HasPrefix(query, "INSERT INTO") {
+			return insertErr
+		}
+		return nil
+	}
+	if _, err := store.Append(context.Background(), "acct", 0, change); !errors.Is(err, ErrConcurrency) {
+		t.Errorf("expected unique violation reported as ErrConcurrency, got %v", err)
+	}
+	insertErr = testSQLStateError("23502")
+	if _, err := store.Append(context.Background(), "acct", 0, change); err == nil || errors.Is(err, ErrConcurrency) {
+		t.Errorf("expected other insert errors passed through, got %v", err)
+	}
+}
+
+func TestIsUniqueViolationReadsDriverCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"postgres", testSQLStateError("23505"), true},
+		{"postgres other", testSQLStateError("23502"), false},
+		{"mysql", fmt.Errorf("insert: %w", &testMySQLError{Message: "Duplicate entry", Number: 1062}), true},
+		{"mysql other", &testMySQLError{Message: "Duplicate entry", Number: 1048}, false},
+		{"sqlite", testSQLiteError{Code: 19, ExtendedCode: 2067}, true},
+		{"sqlite not null", testSQLiteError{Code: 19, ExtendedCode: 1299}, false},
+		{"message only", errors.New("UNIQUE constraint failed: events.key"), false},
+	}
+	for _, tt := range tests {
+		if got := isUniqueViolation(tt.err); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestAggregateLoadIgnoresSnapshotWriteFailure(t *testing.T) {
+	resetAll(t)
+	New()
+	db, state := openTestMigrateDB(t, "postgres")
+	store, err := NewEventStore(db, "events", postgres.New())
+	if err != nil {
+		t.Fatalf("NewEventStore: %v", err)
+	}
+	state.rows = func(string) *testMigrateRows {
+		return &testMigrateRows{
+			cols: []string{"stream", "version", "signal", "payload"},
+			rows: [][]driver.Value{{"acct", int64(1), "test.es.unfolded", []byte(`{}`)}},
+		}
+	}
+	p := NewMemoryBucket()
+	bucket, err := NewBucket[Snapshot[testAccount]](p, "snapshots")
+	if err != nil {
+		t.Fatalf("NewBucket: %v", err)
+	}
+	p.Fail("put", errors.New("bucket down"))
+	agg := NewAggregate(store, WithSnapshots(bucket, 1))
+	if _, version, err := agg.Load(context.Background(), "acct"); err != nil || version != 1 {
+		t.Errorf("expected snapshot failure ignored, got version %d, %v", version, err)
+	}
+}
@@ -10,6 +10,7 @@ require (
 	github.com/zoobzio/astql v1.0.5
 	github.com/zoobzio/capitan v1.0.0
 	github.com/zoobzio/cereal v0.1.1
+	github.com/zoobzio/edamame v1.0.1
 	github.com/zoobzio/fig v0.0.1
 	github.com/zoobzio/grub v0.1.5
 	github.com/zoobzio/rocco v0.1.10
@@ -22,7 +23,6 @@ require (
 	github.com/zoobzio/atom v1.0.0 // indirect
 	github.com/zoobzio/check v0.0.3 // indirect
 	github.com/zoobzio/dbml v1.0.0 // indirect
-	github.com/zoobzio/edamame v1.0.1 // indirect
 	github.com/zoobzio/openapi v1.0.0 // indirect
 	github.com/zoobzio/soy v1.0.3 // indirect
 	github.com/zoobzio/vecna v0.0.2 // indirect
@@ -0,0 +1,273 @@
+package sum
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ServiceDetail describes a registered service for introspection.
+type ServiceDetail struct {
+	Contract  string      `json:"contract"`
+	Impl      string      `json:"impl"`
+	Guards    []GuardInfo `json:"guards,omitempty"`
+	DependsOn []string    `json:"depends_on,omitempty"`
+}
+
+// GuardInfo describes one guard on a service. Token guards added with For
+// list their token names; guards added with Guard report the function name.
+type GuardInfo struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name,omitempty"`
+	Tokens []string `json:"tokens,omitempty"`
+}
+
+// Graph is the service dependency graph.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a contract in the dependency graph. Dependencies that were
+// declared but never registered appear with Registered false.
+type GraphNode struct {
+	ID         string `json:"id"`
+	Impl       string `json:"impl,omitempty"`
+	Registered bool   `json:"registered"`
+}
+
+// GraphEdge points from a service to a contract it depends on.
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// serviceRecord holds what sum knows about a registration beyond slush.
+type serviceRecord struct {
+	impl   string
+	guards []GuardInfo
+	deps   []string
+}
+
+// records holds registration details keyed by contract FQDN. Only entries
+// with an implementation, set by Register, describe a live service.
+var records = struct {
+	m  map[string]*serviceRecord
+	mu sync.Mutex
+}{m: make(map[string]*serviceRecord)}
+
+// introspection holds the token that grants registry introspection.
+var introspection = struct {
+	token *Token
+	mu    sync.Mutex
+}{}
+
+// record updates the registration details for a contract.
+func record(contract string, fn func(*serviceRecord)) {
+	records.mu.Lock()
+	defer records.mu.Unlock()
+	r, ok := records.m[contract]
+	if !ok {
+		r = &serviceRecord{}
+		records.m[contract] = r
+	}
+	fn(r)
+}
+
+// fqdn names a type the way the registry does.
+func fqdn(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.PkgPath() == "" {
+		return t.Name()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+// DependsOn declares the contracts this service uses, for the dependency
+// graph. Pass contract types, e.g. reflect.TypeFor[Users]().
+func (h *Handle[T]) DependsOn(contracts ...reflect.Type) *Handle[T] {
+	record(fqdn(reflect.TypeFor[T]()), func(r *serviceRecord) {
+		for _, c := range contracts {
+			r.deps = append(r.deps, fqdn(c))
+		}
+	})
+	return h
+}
+
+// IntrospectionToken returns the token that grants access to Introspect,
+// RegistryGraph and the admin registry endpoints. Issuing it requires the
+// registry Key, since introspection reveals what Services(k) does; the key
+// itself is not retained. Repeated calls return the same token.
+func IntrospectionToken(k Key) (Token, error) {
+	if _, err := Services(k); err != nil {
+		return Token{}, err
+	}
+	introspection.mu.Lock()
+	defer introspection.mu.Unlock()
+	if introspection.token == nil {
+		t := NewToken("sum.introspection")
+		introspection.token = &t
+	}
+	return *introspection.token, nil
+}
+
+// Introspect lists every service registered through Register with its
+// implementation, guards and declared dependencies, sorted by contract.
+// The context must carry the token from IntrospectionToken.
+func Introspect(ctx context.Context) ([]ServiceDetail, error) {
+	introspection.mu.Lock()
+	token := introspection.token
+	introspection.mu.Unlock()
+	if token == nil {
+		return nil, ErrTokenRequired
+	}
+	if err := Require(*token)(ctx); err != nil {
+		return nil, err
+	}
+
+	records.mu.Lock()
+	out := make([]ServiceDetail, 0, len(records.m))
+	for contract, r := range records.m {
+		if r.impl == "" {
+			continue
+		}
+		out = append(out, ServiceDetail{
+			Contract:  contract,
+			Impl:      r.impl,
+			Guards:    append([]GuardInfo(nil), r.guards...),
+			DependsOn: append([]string(nil), r.deps...),
+		})
+	}
+	records.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Contract < out[j].Contract })
+	return out, nil
+}
+
+// RegistryGraph builds the dependency graph of registered services.
+// The context must carry the token from IntrospectionToken.
+func RegistryGraph(ctx context.Context) (Graph, error) {
+	services, err := Introspect(ctx)
+	if err != nil {
+		return Graph{}, err
+	}
+	g := Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	known := make(map[string]bool, len(services))
+	for _, s := range services {
+		known[s.Contract] = true
+		g.Nodes = append(g.Nodes, GraphNode{ID: s.Contract, Impl: s.Impl, Registered: true})
+	}
+	for _, s := range services {
+		for _, dep := range s.DependsOn {
+			g.Edges = append(g.Edges, GraphEdge{From: s.Contract, To: dep})
+			if !known[dep] {
+				known[dep] = true
+				g.Nodes = append(g.Nodes, GraphNode{ID: dep})
+			}
+		}
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	return g, nil
+}
+
+// DOT renders the graph in Graphviz format. Unregistered dependencies are
+// drawn dashed.
+func (g Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph services {\n")
+	for _, n := range g.Nodes {
+		label := n.ID
+		if n.Impl != "" {
+			label += "\n" + n.Impl
+		}
+		attrs := "label=" + dotQuote(label)
+		if !n.Registered {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(n.ID), attrs)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s;\n", dotQuote(e.From), dotQuote(e.To))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotQuote quotes a DOT identifier.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// guardName names a custom guard function.
+func guardName(g Guard) string {
+	if f := runtime.FuncForPC(reflect.ValueOf(g).Pointer()); f != nil {
+		return f.Name()
+	}
+	return ""
+}
+
+// ServeRegistry mounts the registry endpoints, acting with token t for
+// callers that present "Authorization: Bearer <bearer>":
+//
+//   - GET /registry: services as JSON
+//   - GET /registry/graph.json: the dependency graph as JSON
+//   - GET /registry/graph.dot: the dependency graph as Graphviz DOT
+//
+// Requests without a bearer credential receive 401 and requests with the
+// wrong one 403. An empty bearer refuses every request. The check runs after
+// admin authentication, which reads the same header when WithAdminToken is
+// used.
+func (a *Admin) ServeRegistry(t Token, bearer string) {
+	a.Handle("GET /registry", registryHandler(t, bearer, func(ctx context.Context, w http.ResponseWriter) error {
+		services, err := Introspect(ctx)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(services)
+	}))
+	a.Handle("GET /registry/graph.json", registryHandler(t, bearer, func(ctx context.Context, w http.ResponseWriter) error {
+		g, err := RegistryGraph(ctx)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(g)
+	}))
+	a.Handle("GET /registry/graph.dot", registryHandler(t, bearer, func(ctx context.Context, w http.ResponseWriter) error {
+		g, err := RegistryGraph(ctx)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		_, err = w.Write([]byte(g.DOT()))
+		return err
+	}))
+}
+
+// registryHandler runs fn with t in the request context once the caller
+// presents bearer.
+func registryHandler(t Token, bearer string, fn func(context.Context, http.ResponseWriter) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || got == "" {
+			http.Error(w, ErrTokenRequired.Error(), http.StatusUnauthorized)
+			return
+		}
+		if bearer == "" || subtle.ConstantTimeCompare([]byte(got), []byte(bearer)) != 1 {
+			http.Error(w, ErrAccessDenied.Error(), http.StatusForbidden)
+			return
+		}
+		if err := fn(WithToken(r.Context(), t), w); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		}
+	})
+}
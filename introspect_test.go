@@ -0,0 +1,166 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testDenyAll(context.Context) error { return ErrAccessDenied }
+
+func TestIntrospectRequiresToken(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	k := Start()
+	Register[testSvc](k, testSvcImpl{})
+
+	if _, err := Introspect(context.Background()); !errors.Is(err, ErrTokenRequired) {
+		t.Errorf("expected ErrTokenRequired before a token is issued, got %v", err)
+	}
+	token, err := IntrospectionToken(k)
+	if err != nil {
+		t.Fatalf("IntrospectionToken: %v", err)
+	}
+	if _, err := Introspect(context.Background()); !errors.Is(err, ErrTokenRequired) {
+		t.Errorf("expected ErrTokenRequired without token, got %v", err)
+	}
+	if _, err := Introspect(WithToken(context.Background(), NewToken("other"))); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("expected ErrAccessDenied with another token, got %v", err)
+	}
+	if _, err := Introspect(WithToken(context.Background(), token)); err != nil {
+		t.Errorf("expected access with introspection token, got %v", err)
+	}
+}
+
+func TestIntrospectDescribesServices(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	k := Start()
+	admin := NewToken("admin")
+	Register[testGreeterIface](k, testGreeter{})
+	Register[testGuarded](k, testGuardedImpl{}).
+		For(admin).
+		Guard(testDenyAll).
+		DependsOn(reflect.TypeFor[testGreeterIface](), reflect.TypeFor[testMissing]())
+	token, err := IntrospectionToken(k)
+	if err != nil {
+		t.Fatalf("IntrospectionToken: %v", err)
+	}
+	ctx := WithToken(context.Background(), token)
+
+	services, err := Introspect(ctx)
+	if err != nil {
+		t.Fatalf("Introspect: %v", err)
+	}
+	if len(services) != 2 {
+		t.Fatalf("expected 2 services, got %+v", services)
+	}
+	guarded := services[1]
+	if !strings.HasSuffix(guarded.Contract, ".testGuarded") || !strings.HasSuffix(guarded.Impl, ".testGuardedImpl") {
+		t.Errorf("unexpected service %+v", guarded)
+	}
+	if len(guarded.Guards) != 2 ||
+		guarded.Guards[0].Kind != "token" || len(guarded.Guards[0].Tokens) != 1 || guarded.Guards[0].Tokens[0] != "admin" ||
+		guarded.Guards[1].Kind != "custom" || !strings.HasSuffix(guarded.Guards[1].Name, ".testDenyAll") {
+		t.Errorf("unexpected guards %+v", guarded.Guards)
+	}
+
+	g, err := RegistryGraph(ctx)
+	if err != nil {
+		t.Fatalf("RegistryGraph: %v", err)
+	}
+	if len(g.Nodes) != 3 || len(g.Edges) != 2 {
+		t.Fatalf("unexpected graph %+v", g)
+	}
+	var missing GraphNode
+	for _, n := range g.Nodes {
+		if strings.HasSuffix(n.ID, ".testMissing") {
+			missing = n
+		}
+	}
+	if missing.ID == "" || missing.Registered {
+		t.Errorf("expected unregistered dependency node, got %+v", g.Nodes)
+	}
+
+	dot := g.DOT()
+	if !strings.HasPrefix(dot, "digraph services {") ||
+		!strings.Contains(dot, `"`+guarded.Contract+`" -> "`+missing.ID+`";`) ||
+		!strings.Contains(dot, `style=dashed`) {
+		t.Errorf("unexpected DOT:\n%s", dot)
+	}
+}
+
+func TestAdminServesRegistry(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	svc := New()
+	a := svc.WithAdmin("127.0.0.1", 0).Admin()
+	k := Start()
+	Register[testSvc](k, testSvcImpl{})
+	token, err := IntrospectionToken(k)
+	if err != nil {
+		t.Fatalf("IntrospectionToken: %v", err)
+	}
+	a.ServeRegistry(token, "registry-secret")
+
+	if rec := adminRequest(t, a, "/registry", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without credentials, got %d", rec.Code)
+	}
+	if rec := adminRequest(t, a, "/registry", "wrong"); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 with wrong credentials, got %d", rec.Code)
+	}
+
+	rec := adminRequest(t, a, "/registry", "registry-secret")
+	var services []ServiceDetail
+	if err := json.Unmarshal(rec.Body.Bytes(), &services); err != nil || len(services) != 1 {
+		t.Fatalf("unexpected registry response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = adminRequest(t, a, "/registry/graph.json", "registry-secret")
+	var g Graph
+	if err := json.Unmarshal(rec.Body.Bytes(), &g); err != nil || len(g.Nodes) != 1 {
+		t.Errorf("unexpected graph response: %s", rec.Body.String())
+	}
+
+	rec = adminRequest(t, a, "/registry/graph.dot", "registry-secret")
+	if rec.Header().Get("Content-Type") != "text/vnd.graphviz" || !strings.HasPrefix(rec.Body.String(), "digraph") {
+		t.Errorf("unexpected DOT response: %s", rec.Body.String())
+	}
+
+	// A token that does not grant introspection is refused.
+	b := svc.WithAdmin("127.0.0.1", 0).Admin()
+	b.ServeRegistry(NewToken("other"), "registry-secret")
+	if rec := adminRequest(t, b, "/registry", "registry-secret"); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 with wrong token, got %d", rec.Code)
+	}
+
+	// An empty bearer refuses everyone.
+	c := svc.WithAdmin("127.0.0.1", 0).Admin()
+	c.ServeRegistry(token, "")
+	if rec := adminRequest(t, c, "/registry", "anything"); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 with no bearer configured, got %d", rec.Code)
+	}
+}
+
+func TestIntrospectOmitsUnregistered(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	k := Start()
+	Register[testSvc](k, testSvcImpl{})
+	Register[testGreeterIface](k, testGreeter{})
+	Unregister[testSvc]()
+	token, err := IntrospectionToken(k)
+	if err != nil {
+		t.Fatalf("IntrospectionToken: %v", err)
+	}
+	services, err := Introspect(WithToken(context.Background(), token))
+	if err != nil || len(services) != 1 || !strings.HasSuffix(services[0].Contract, ".testGreeterIface") {
+		t.Errorf("expected only the registered service, got %+v, %v", services, err)
+	}
+}
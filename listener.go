@@ -0,0 +1,105 @@
+package sum
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/zoobzio/capitan"
+)
+
+// listeners tracks every open capitan listener created through sum so
+// Shutdown can drain and close them.
+var listeners = struct {
+	set map[*capitan.Listener]struct{}
+	mu  sync.Mutex
+}{set: make(map[*capitan.Listener]struct{})}
+
+// Listener is a capitan listener registered through sum. Open listeners are
+// drained and closed by Service.Shutdown; closing one earlier releases it.
+// It replaces the *capitan.Listener Listen used to return, because closing a
+// bare capitan listener cannot release it from shutdown tracking.
+type Listener struct {
+	*capitan.Listener
+	stop func() bool // stops the ListenContext hook, if any
+	mu   sync.Mutex
+}
+
+// newListener tracks l for shutdown and wraps it.
+func newListener(l *capitan.Listener) *Listener {
+	track(l)
+	return &Listener{Listener: l}
+}
+
+// Close unregisters the listener and stops tracking it for shutdown.
+func (l *Listener) Close() {
+	l.mu.Lock()
+	stop := l.stop
+	l.stop = nil
+	l.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+	l.Listener.Close()
+	untrack(l.Listener)
+}
+
+// live reports whether the listener is still open and tracked.
+func (l *Listener) live() bool {
+	listeners.mu.Lock()
+	defer listeners.mu.Unlock()
+	_, ok := listeners.set[l.Listener]
+	return ok
+}
+
+// track records a listener for shutdown.
+func track(l *capitan.Listener) {
+	if l == nil {
+		return
+	}
+	listeners.mu.Lock()
+	listeners.set[l] = struct{}{}
+	listeners.mu.Unlock()
+}
+
+// untrack forgets a listener that has already been closed.
+func untrack(l *capitan.Listener) {
+	listeners.mu.Lock()
+	delete(listeners.set, l)
+	listeners.mu.Unlock()
+}
+
+// tracked returns a snapshot of the tracked listeners.
+func tracked() []*capitan.Listener {
+	listeners.mu.Lock()
+	defer listeners.mu.Unlock()
+	out := make([]*capitan.Listener, 0, len(listeners.set))
+	for l := range listeners.set {
+		out = append(out, l)
+	}
+	return out
+}
+
+// drainListeners waits for events queued to tracked listeners to be processed.
+func drainListeners(ctx context.Context) error {
+	var errs []error
+	for _, l := range tracked() {
+		if err := l.Drain(ctx); err != nil {
+			errs = append(errs, err)
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeListeners closes and forgets every tracked listener.
+func closeListeners() {
+	listeners.mu.Lock()
+	set := listeners.set
+	listeners.set = make(map[*capitan.Listener]struct{})
+	listeners.mu.Unlock()
+
+	for l := range set {
+		l.Close()
+	}
+}
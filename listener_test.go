@@ -0,0 +1,122 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/capitan"
+)
+
+func TestListenContextUnregistersOnCancel(t *testing.T) {
+	resetAll(t)
+	New()
+
+	event := NewInfoEvent[testEventInt](capitan.NewSignal("test.listener.context", "Context listener"))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	got := make(chan int, 4)
+	l := event.ListenContext(ctx, func(_ context.Context, d testEventInt) { got <- d.Value })
+	if !l.live() {
+		t.Error("expected listener to be tracked")
+	}
+
+	event.Emit(context.Background(), testEventInt{Value: 1})
+	cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for l.live() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if l.live() {
+		t.Fatal("listener not released after cancel")
+	}
+
+	event.Emit(context.Background(), testEventInt{Value: 2})
+	close(got)
+	var values []int
+	for v := range got {
+		values = append(values, v)
+	}
+	if len(values) != 1 || values[0] != 1 {
+		t.Errorf("expected only the emission before cancel, got %v", values)
+	}
+}
+
+func TestListenContextCloseReleasesContext(t *testing.T) {
+	resetAll(t)
+	New()
+
+	event := NewInfoEvent[testEventInt](capitan.NewSignal("test.listener.context.close", "Context listener close"))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	l := event.ListenContext(ctx, func(context.Context, testEventInt) {})
+	l.mu.Lock()
+	stop := l.stop
+	l.mu.Unlock()
+	l.Close()
+
+	if stop() {
+		t.Error("expected Close to stop the context hook")
+	}
+	if l.live() {
+		t.Error("expected listener untracked after Close")
+	}
+}
+
+func TestListenOnceUntracksAfterFiring(t *testing.T) {
+	resetAll(t)
+	New()
+
+	event := NewInfoEvent[testEventInt](capitan.NewSignal("test.listener.once", "Once listener"))
+	l := event.ListenOnce(func(context.Context, testEventInt) {})
+	if !l.live() {
+		t.Fatal("expected listener to be tracked")
+	}
+
+	event.Emit(context.Background(), testEventInt{Value: 1})
+	if l.live() {
+		t.Error("expected listener untracked after firing")
+	}
+}
+
+func TestShutdownClosesTrackedListeners(t *testing.T) {
+	resetAll(t)
+	svc := New()
+
+	event := NewInfoEvent[testEventInt](capitan.NewSignal("test.listener.shutdown", "Shutdown listener"))
+	calls := 0
+	l := event.Listen(func(context.Context, testEventInt) { calls++ })
+
+	ctx := context.Background()
+	event.Emit(ctx, testEventInt{Value: 1})
+	if err := svc.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	event.Emit(ctx, testEventInt{Value: 2})
+
+	if calls != 1 {
+		t.Errorf("expected listener closed by Shutdown, got %d calls", calls)
+	}
+	if l.live() {
+		t.Error("expected listener untracked after Shutdown")
+	}
+}
+
+func TestListenerCloseUntracks(t *testing.T) {
+	resetAll(t)
+	New()
+
+	event := NewInfoEvent[testEventInt](capitan.NewSignal("test.listener.close", "Closed listener"))
+	l := event.Listen(func(context.Context, testEventInt) {})
+	if !l.live() {
+		t.Fatal("expected listener to be tracked")
+	}
+	l.Close()
+	if l.live() {
+		t.Error("expected listener untracked once closed")
+	}
+}
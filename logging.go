@@ -0,0 +1,295 @@
+package sum
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/zoobzio/capitan"
+)
+
+// LogOption configures the slog sink installed by WithLogger.
+type LogOption func(*logSink)
+
+// WithLogLevel overrides the level records are written at for the given signals,
+// e.g. to demote noisy registry access to Debug.
+func WithLogLevel(level slog.Level, signals ...Signal) LogOption {
+	return func(s *logSink) {
+		for _, sig := range signals {
+			s.levels[sig.Name()] = level
+		}
+	}
+}
+
+// WithLogSampling writes only every nth emission of the given signals.
+// With no signals, the rate applies to every signal without its own.
+func WithLogSampling(n int, signals ...Signal) LogOption {
+	return func(s *logSink) {
+		if len(signals) == 0 {
+			s.sampleAll = n
+			return
+		}
+		for _, sig := range signals {
+			s.samples[sig.Name()] = n
+		}
+	}
+}
+
+// logSink writes capitan events to a slog.Handler.
+type logSink struct {
+	svc       *Service
+	handler   slog.Handler
+	levels    map[string]slog.Level
+	samples   map[string]int
+	counts    sync.Map // signal name -> *atomic.Uint64
+	sampleAll int
+}
+
+// WithLogger writes every capitan emission, including registry signals, to h.
+// Severity maps onto slog levels and event fields become attributes, with
+// struct payloads flattened into groups. Payloads with a Boundary are logged
+// as its Send output; cereal-tagged fields are otherwise redacted. Calling it
+// again replaces the sink. The sink is drained and closed when the Service
+// shuts down.
+func (s *Service) WithLogger(h slog.Handler, opts ...LogOption) *Service {
+	sink := &logSink{
+		svc:     s,
+		handler: h,
+		levels:  make(map[string]slog.Level),
+		samples: make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(sink)
+	}
+	obs := capitan.Observe(sink.handle)
+
+	s.mu.Lock()
+	prev := s.logger
+	s.logger = obs
+	first := !s.logging
+	s.logging = true
+	s.mu.Unlock()
+
+	if prev != nil {
+		prev.Close()
+	}
+	if first {
+		s.onShutdown(func(ctx context.Context) error {
+			s.mu.Lock()
+			obs := s.logger
+			s.logger = nil
+			s.mu.Unlock()
+			if obs == nil {
+				return nil
+			}
+			err := obs.Drain(ctx)
+			obs.Close()
+			return err
+		})
+	}
+	return s
+}
+
+// handle converts one event into a log record.
+func (l *logSink) handle(ctx context.Context, ev *capitan.Event) {
+	name := ev.Signal().Name()
+	level, ok := l.levels[name]
+	if !ok {
+		level = severityLevel(ev.Severity())
+	}
+	if !l.handler.Enabled(ctx, level) || !l.sampled(name) {
+		return
+	}
+
+	r := slog.NewRecord(ev.Timestamp(), level, name, 0)
+	if desc := ev.Signal().Description(); desc != "" {
+		r.AddAttrs(slog.String("description", desc))
+	}
+	if ev.IsReplay() {
+		r.AddAttrs(slog.Bool("replay", true))
+	}
+	for _, f := range ev.Fields() {
+		r.AddAttrs(l.attr(ctx, f.Key().Name(), f.Value()))
+	}
+	_ = l.handler.Handle(ctx, r)
+}
+
+// attr converts one field value, passing it through its Boundary first when
+// one is registered for its type so masked and redacted fields are logged as
+// they would be sent.
+func (l *logSink) attr(ctx context.Context, key string, value any) slog.Attr {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return slog.Any(key, nil)
+	}
+	payload := v
+	if payload.Kind() == reflect.Ptr && !payload.IsNil() {
+		payload = payload.Elem()
+	}
+	send := l.svc.sender(payload.Type())
+	if send == nil {
+		return flattenAttr(key, v, false, 0)
+	}
+	out, err := send(ctx, payload.Interface())
+	if err != nil {
+		return slog.String(key, redactedValue)
+	}
+	return flattenAttr(key, reflect.ValueOf(out), true, 0)
+}
+
+// sampled reports whether this emission of a signal should be written.
+func (l *logSink) sampled(name string) bool {
+	n, ok := l.samples[name]
+	if !ok {
+		n = l.sampleAll
+	}
+	if n <= 1 {
+		return true
+	}
+	c, _ := l.counts.LoadOrStore(name, new(atomic.Uint64))
+	return (c.(*atomic.Uint64).Add(1)-1)%uint64(n) == 0
+}
+
+// severityLevel maps capitan severities onto slog levels.
+func severityLevel(s capitan.Severity) slog.Level {
+	switch s {
+	case capitan.SeverityDebug:
+		return slog.LevelDebug
+	case capitan.SeverityWarn:
+		return slog.LevelWarn
+	case capitan.SeverityError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// redactedValue replaces values the sink must not write.
+const redactedValue = "[REDACTED]"
+
+// maxLogDepth bounds how far flattenAttr descends into nested values.
+const maxLogDepth = 8
+
+// sensitiveTags are the cereal tags that mark a field as sensitive.
+var sensitiveTags = []string{"receive.hash", "load.decrypt", "store.encrypt", "send.mask", "send.redact"}
+
+// flattenAttr converts a value into an attribute, expanding structs into
+// groups keyed by their json names and collections of structs into groups
+// keyed by index or map key. Fields tagged json:"-" are omitted and
+// cereal-tagged fields are redacted, except send-tagged fields of a value
+// that has already been through its Boundary (masked). Nesting beyond
+// maxLogDepth is elided.
+func flattenAttr(key string, v reflect.Value, masked bool, depth int) slog.Attr {
+	if !v.IsValid() {
+		return slog.Any(key, nil)
+	}
+	if depth > maxLogDepth {
+		return slog.String(key, "[...]")
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return slog.Any(key, nil)
+		}
+		if err, ok := v.Interface().(error); ok {
+			return slog.String(key, err.Error())
+		}
+		v = v.Elem()
+	}
+	if err, ok := v.Interface().(error); ok {
+		return slog.String(key, err.Error())
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if !holdsStruct(v.Type().Elem()) {
+			return slog.Any(key, v.Interface())
+		}
+		attrs := make([]any, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			attrs = append(attrs, flattenAttr(fmt.Sprint(i), v.Index(i), false, depth+1))
+		}
+		return slog.Group(key, attrs...)
+	case reflect.Map:
+		if !holdsStruct(v.Type().Elem()) {
+			return slog.Any(key, v.Interface())
+		}
+		attrs := make([]any, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			attrs = append(attrs, flattenAttr(fmt.Sprint(iter.Key().Interface()), iter.Value(), false, depth+1))
+		}
+		return slog.Group(key, attrs...)
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return slog.Any(key, v.Interface())
+		}
+	default:
+		return slog.Any(key, v.Interface())
+	}
+
+	t := v.Type()
+	attrs := make([]any, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := sf.Name
+		if tag, ok := sf.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		if sensitive(sf.Tag, masked) {
+			attrs = append(attrs, slog.String(name, redactedValue))
+			continue
+		}
+		// Boundaries only process top-level fields, so nested values are
+		// never treated as masked.
+		attrs = append(attrs, flattenAttr(name, v.Field(i), false, depth+1))
+	}
+	return slog.Group(key, attrs...)
+}
+
+// sensitive reports whether a field's cereal tags require redaction. Send
+// tags are honored as-is on masked values, since Send has applied them.
+func sensitive(tag reflect.StructTag, masked bool) bool {
+	if masked {
+		if _, ok := tag.Lookup("send.mask"); ok {
+			return false
+		}
+		if _, ok := tag.Lookup("send.redact"); ok {
+			return false
+		}
+	}
+	for _, name := range sensitiveTags {
+		if _, ok := tag.Lookup(name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// holdsStruct reports whether values of t are, or point to, structs worth
+// flattening.
+func holdsStruct(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+// sender returns the Boundary send function registered for t, if any.
+func (s *Service) sender(t reflect.Type) func(context.Context, any) (any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.senders[t]
+}
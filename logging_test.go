@@ -0,0 +1,275 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/cereal"
+)
+
+// testLogHandler records slog records for inspection.
+type testLogHandler struct {
+	records []slog.Record
+	min     slog.Level
+	mu      sync.Mutex
+}
+
+func (h *testLogHandler) Enabled(_ context.Context, level slog.Level) bool { return level >= h.min }
+
+func (h *testLogHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *testLogHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *testLogHandler) WithGroup(string) slog.Handler      { return h }
+
+// find returns the records written for a signal.
+func (h *testLogHandler) find(signal string) []slog.Record {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var out []slog.Record
+	for _, r := range h.records {
+		if r.Message == signal {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// recordAttrs flattens a record's attributes into dotted keys.
+func recordAttrs(r slog.Record) map[string]string {
+	out := make(map[string]string)
+	var walk func(prefix string, a slog.Attr)
+	walk = func(prefix string, a slog.Attr) {
+		key := prefix + a.Key
+		if a.Value.Kind() == slog.KindGroup {
+			for _, g := range a.Value.Group() {
+				walk(key+".", g)
+			}
+			return
+		}
+		out[key] = a.Value.String()
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		walk("", a)
+		return true
+	})
+	return out
+}
+
+type testLogPayload struct {
+	Inner  testLogInner `json:"inner"`
+	Secret string       `json:"-"`
+	Name   string       `json:"name"`
+}
+
+type testLogInner struct {
+	Count int
+}
+
+func TestLoggerWritesEvents(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	h := &testLogHandler{min: slog.LevelDebug}
+	New().WithLogger(h)
+
+	event := NewWarnEvent[testLogPayload](capitan.NewSignal("test.log.payload", "Payload logged"))
+	event.Emit(context.Background(), testLogPayload{Name: "widget", Secret: "shh", Inner: testLogInner{Count: 3}})
+
+	records := h.find("test.log.payload")
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	r := records[0]
+	if r.Level != slog.LevelWarn {
+		t.Errorf("expected warn level, got %v", r.Level)
+	}
+	attrs := recordAttrs(r)
+	if attrs["data.name"] != "widget" || attrs["data.inner.Count"] != "3" {
+		t.Errorf("expected flattened payload, got %v", attrs)
+	}
+	if _, ok := attrs["data.Secret"]; ok {
+		t.Error("json:\"-\" field should not be logged")
+	}
+	if attrs["description"] != "Payload logged" {
+		t.Errorf("expected description attr, got %v", attrs)
+	}
+}
+
+func TestLoggerRegistrySignalsAndOverrides(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	h := &testLogHandler{min: slog.LevelInfo}
+	New().WithLogger(h, WithLogLevel(slog.LevelDebug, SignalAccessed))
+
+	k := Start()
+	Register[testGreeterIface](k, testGreeter{})
+	Freeze(k)
+
+	ctx := context.Background()
+	if _, err := Use[testGreeterIface](ctx); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	if got := h.find(SignalAccessed.Name()); len(got) != 0 {
+		t.Errorf("expected demoted access signal filtered at info, got %d", len(got))
+	}
+
+	errKey := NewErrorKey("error")
+	capitan.Error(ctx, capitan.NewSignal("test.log.failure", "Failure"), errKey.Field(errors.New("boom")))
+	records := h.find("test.log.failure")
+	if len(records) != 1 || records[0].Level != slog.LevelError {
+		t.Fatalf("expected one error record, got %v", records)
+	}
+	if recordAttrs(records[0])["error"] != "boom" {
+		t.Errorf("expected error flattened to message, got %v", recordAttrs(records[0]))
+	}
+}
+
+func TestLoggerSampling(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	h := &testLogHandler{min: slog.LevelDebug}
+	signal := capitan.NewSignal("test.log.sampled", "Sampled")
+	New().WithLogger(h, WithLogSampling(3, signal))
+
+	event := NewInfoEvent[testEventInt](signal)
+	for i := 0; i < 9; i++ {
+		event.Emit(context.Background(), testEventInt{Value: i})
+	}
+	if got := len(h.find("test.log.sampled")); got != 3 {
+		t.Errorf("expected every third emission, got %d records", got)
+	}
+}
+
+func TestLoggerClosedOnShutdown(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	h := &testLogHandler{min: slog.LevelDebug}
+	svc := New().WithLogger(h)
+
+	event := NewInfoEvent[testEventInt](capitan.NewSignal("test.log.shutdown", "Shutdown"))
+	event.Emit(context.Background(), testEventInt{Value: 1})
+	if err := svc.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	event.Emit(context.Background(), testEventInt{Value: 2})
+
+	if got := len(h.find("test.log.shutdown")); got != 1 {
+		t.Errorf("expected sink closed by Shutdown, got %d records", got)
+	}
+}
+
+type testLogAccount struct {
+	ID     string           `json:"id"`
+	Email  string           `json:"email" store.encrypt:"aes" load.decrypt:"aes" send.mask:"email"`
+	Token  string           `json:"token" send.redact:"***"`
+	Hash   string           `json:"hash" receive.hash:"sha256"`
+	Owners []testLogAccount `json:"owners"`
+}
+
+func (a testLogAccount) Clone() testLogAccount {
+	a.Owners = append([]testLogAccount(nil), a.Owners...)
+	return a
+}
+
+func TestLoggerRedactsTaggedFields(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	h := &testLogHandler{min: slog.LevelDebug}
+	New().WithLogger(h)
+
+	event := NewInfoEvent[testLogAccount](capitan.NewSignal("test.log.account", "Account"))
+	event.Emit(context.Background(), testLogAccount{
+		ID: "a1", Email: "alice@example.com", Token: "tok", Hash: "pw",
+		Owners: []testLogAccount{{ID: "a2", Email: "bob@example.com"}},
+	})
+
+	records := h.find("test.log.account")
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	attrs := recordAttrs(records[0])
+	if attrs["data.id"] != "a1" {
+		t.Errorf("expected untagged field logged, got %v", attrs)
+	}
+	for _, key := range []string{"data.email", "data.token", "data.hash", "data.owners.0.email"} {
+		if attrs[key] != redactedValue {
+			t.Errorf("expected %s redacted without a boundary, got %q", key, attrs[key])
+		}
+	}
+}
+
+func TestLoggerUsesBoundaryMasking(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	h := &testLogHandler{min: slog.LevelDebug}
+	New().WithLogger(h).WithEncryptor(cereal.EncryptAES, stubEncryptor{}).WithHasher(cereal.HashSHA256, stubHasher{})
+
+	k := Start()
+	if _, err := NewBoundary[testLogAccount](k); err != nil {
+		t.Fatalf("NewBoundary: %v", err)
+	}
+	Freeze(k)
+
+	event := NewInfoEvent[testLogAccount](capitan.NewSignal("test.log.masked", "Masked"))
+	event.Emit(context.Background(), testLogAccount{
+		ID: "a1", Email: "alice@example.com", Token: "tok", Hash: "pw",
+		Owners: []testLogAccount{{ID: "a2", Email: "bob@example.com"}},
+	})
+
+	records := h.find("test.log.masked")
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	attrs := recordAttrs(records[0])
+	if got := attrs["data.email"]; got == "alice@example.com" || got == redactedValue || got == "" {
+		t.Errorf("expected boundary-masked email, got %q", got)
+	}
+	if got := attrs["data.token"]; got != "***" {
+		t.Errorf("expected boundary-redacted token, got %q", got)
+	}
+	if attrs["data.hash"] != redactedValue {
+		t.Errorf("expected receive.hash field redacted, got %q", attrs["data.hash"])
+	}
+	if attrs["data.owners.0.email"] != redactedValue {
+		t.Errorf("expected nested email redacted, got %q", attrs["data.owners.0.email"])
+	}
+}
+
+type testLogNode struct {
+	Next *testLogNode `json:"next"`
+	Name string       `json:"name"`
+}
+
+func TestLoggerBoundsDepth(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	h := &testLogHandler{min: slog.LevelDebug}
+	New().WithLogger(h)
+
+	node := &testLogNode{Name: "loop"}
+	node.Next = node
+	event := NewInfoEvent[*testLogNode](capitan.NewSignal("test.log.cycle", "Cycle"))
+	event.Emit(context.Background(), node)
+
+	records := h.find("test.log.cycle")
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	attrs := recordAttrs(records[0])
+	if attrs["data.name"] != "loop" {
+		t.Errorf("expected top-level field logged, got %v", attrs)
+	}
+	if len(attrs) > maxLogDepth+3 {
+		t.Errorf("expected flattening bounded at depth %d, got %d attrs", maxLogDepth, len(attrs))
+	}
+}
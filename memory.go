@@ -0,0 +1,362 @@
+package sum
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zoobzio/grub"
+)
+
+// Faults injects errors and latency into the in-memory providers. Operations
+// are named "get", "set", "delete", "exists", "list", "get_batch",
+// "set_batch" and "put"; the empty name matches every operation.
+// The zero value injects nothing.
+type Faults struct {
+	errs   map[string]error
+	delays map[string]time.Duration
+	hook   func(ctx context.Context, op, key string) error
+	mu     sync.RWMutex
+}
+
+// Fail makes op return err until cleared.
+func (f *Faults) Fail(op string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.errs == nil {
+		f.errs = make(map[string]error)
+	}
+	f.errs[op] = err
+}
+
+// Delay makes op wait d before running, or until its context is done.
+func (f *Faults) Delay(op string, d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.delays == nil {
+		f.delays = make(map[string]time.Duration)
+	}
+	f.delays[op] = d
+}
+
+// Hook runs fn before every operation; a non-nil error fails the operation.
+// Use it for faults that depend on the key or on call counts.
+func (f *Faults) Hook(fn func(ctx context.Context, op, key string) error) {
+	f.mu.Lock()
+	f.hook = fn
+	f.mu.Unlock()
+}
+
+// Clear removes every injected fault.
+func (f *Faults) Clear() {
+	f.mu.Lock()
+	f.errs, f.delays, f.hook = nil, nil, nil
+	f.mu.Unlock()
+}
+
+// inject applies the faults configured for op.
+func (f *Faults) inject(ctx context.Context, op, key string) error {
+	f.mu.RLock()
+	delay, ok := f.delays[op]
+	if !ok {
+		delay = f.delays[""]
+	}
+	err, ok := f.errs[op]
+	if !ok {
+		err = f.errs[""]
+	}
+	hook := f.hook
+	f.mu.RUnlock()
+
+	if delay > 0 {
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+	if hook != nil {
+		if err := hook(ctx, op, key); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// memoryEntry is a stored value and its expiry; a zero expiry never expires.
+type memoryEntry struct {
+	expires time.Time
+	value   []byte
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// memorySweepEvery is how many writes pass between sweeps of expired entries.
+const memorySweepEvery = 1024
+
+// MemoryStore is a thread-safe in-memory grub.StoreProvider with TTL
+// support, for tests and single-node deployments. Expired entries are
+// dropped when read and swept periodically on write. Values are copied in
+// and out, so callers may reuse their buffers.
+type MemoryStore struct {
+	Faults
+	entries map[string]memoryEntry
+	writes  int
+	mu      sync.Mutex
+}
+
+var _ grub.StoreProvider = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+// Get returns the value for key, or grub.ErrNotFound.
+func (m *MemoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := m.inject(ctx, "get", key); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.lookup(key, time.Now())
+	if !ok {
+		return nil, grub.ErrNotFound
+	}
+	return bytes.Clone(e.value), nil
+}
+
+// Set stores value under key. A positive ttl expires the entry after ttl.
+func (m *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := m.inject(ctx, "set", key); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(key, value, ttl, time.Now())
+	return nil
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	if err := m.inject(ctx, "delete", key); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.entries, key)
+	m.mu.Unlock()
+	return nil
+}
+
+// Exists reports whether key holds an unexpired value.
+func (m *MemoryStore) Exists(ctx context.Context, key string) (bool, error) {
+	if err := m.inject(ctx, "exists", key); err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.lookup(key, time.Now())
+	return ok, nil
+}
+
+// List returns up to limit unexpired keys starting with prefix, sorted.
+// A limit of zero or less returns every match.
+func (m *MemoryStore) List(ctx context.Context, prefix string, limit int) ([]string, error) {
+	if err := m.inject(ctx, "list", prefix); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var keys []string
+	for k, e := range m.entries {
+		if strings.HasPrefix(k, prefix) && !e.expired(now) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys, nil
+}
+
+// GetBatch returns the unexpired values among keys; missing keys are
+// omitted.
+func (m *MemoryStore) GetBatch(ctx context.Context, keys []string) (map[string][]byte, error) {
+	if err := m.inject(ctx, "get_batch", ""); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	out := make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		if e, ok := m.lookup(k, now); ok {
+			out[k] = bytes.Clone(e.value)
+		}
+	}
+	return out, nil
+}
+
+// SetBatch stores every item with the same ttl.
+func (m *MemoryStore) SetBatch(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	if err := m.inject(ctx, "set_batch", ""); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for k, v := range items {
+		m.put(k, v, ttl, now)
+	}
+	return nil
+}
+
+// lookup returns the live entry for key, dropping it if expired.
+// Callers hold m.mu.
+func (m *MemoryStore) lookup(key string, now time.Time) (memoryEntry, bool) {
+	e, ok := m.entries[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if e.expired(now) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+	return e, true
+}
+
+// put stores a copy of value, sweeping expired entries every
+// memorySweepEvery writes. Callers hold m.mu.
+func (m *MemoryStore) put(key string, value []byte, ttl time.Duration, now time.Time) {
+	e := memoryEntry{value: bytes.Clone(value)}
+	if ttl > 0 {
+		e.expires = now.Add(ttl)
+	}
+	m.entries[key] = e
+	m.writes++
+	if m.writes%memorySweepEvery == 0 {
+		for k, e := range m.entries {
+			if e.expired(now) {
+				delete(m.entries, k)
+			}
+		}
+	}
+}
+
+// memoryObject is a stored object and its metadata.
+type memoryObject struct {
+	info grub.ObjectInfo
+	data []byte
+}
+
+// MemoryBucket is a thread-safe in-memory grub.BucketProvider for tests and
+// single-node deployments. Put records the key, size and a SHA-256 ETag
+// alongside the caller's content type and metadata.
+type MemoryBucket struct {
+	Faults
+	objects map[string]memoryObject
+	mu      sync.RWMutex
+}
+
+var _ grub.BucketProvider = (*MemoryBucket)(nil)
+
+// NewMemoryBucket creates an empty MemoryBucket.
+func NewMemoryBucket() *MemoryBucket {
+	return &MemoryBucket{objects: make(map[string]memoryObject)}
+}
+
+// Get returns the object at key and its metadata, or grub.ErrNotFound.
+func (b *MemoryBucket) Get(ctx context.Context, key string) ([]byte, *grub.ObjectInfo, error) {
+	if err := b.inject(ctx, "get", key); err != nil {
+		return nil, nil, err
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	obj, ok := b.objects[key]
+	if !ok {
+		return nil, nil, grub.ErrNotFound
+	}
+	info := cloneInfo(obj.info)
+	return bytes.Clone(obj.data), &info, nil
+}
+
+// Put stores data at key, replacing any existing object.
+func (b *MemoryBucket) Put(ctx context.Context, key string, data []byte, info *grub.ObjectInfo) error {
+	if err := b.inject(ctx, "put", key); err != nil {
+		return err
+	}
+	var stored grub.ObjectInfo
+	if info != nil {
+		stored = cloneInfo(*info)
+	}
+	sum := sha256.Sum256(data)
+	stored.Key = key
+	stored.Size = int64(len(data))
+	stored.ETag = hex.EncodeToString(sum[:])
+	b.mu.Lock()
+	b.objects[key] = memoryObject{info: stored, data: bytes.Clone(data)}
+	b.mu.Unlock()
+	return nil
+}
+
+// Delete removes the object at key. Deleting a missing object is not an
+// error.
+func (b *MemoryBucket) Delete(ctx context.Context, key string) error {
+	if err := b.inject(ctx, "delete", key); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	delete(b.objects, key)
+	b.mu.Unlock()
+	return nil
+}
+
+// Exists reports whether an object is stored at key.
+func (b *MemoryBucket) Exists(ctx context.Context, key string) (bool, error) {
+	if err := b.inject(ctx, "exists", key); err != nil {
+		return false, err
+	}
+	b.mu.RLock()
+	_, ok := b.objects[key]
+	b.mu.RUnlock()
+	return ok, nil
+}
+
+// List returns metadata for up to limit objects whose keys start with
+// prefix, sorted by key. A limit of zero or less returns every match.
+func (b *MemoryBucket) List(ctx context.Context, prefix string, limit int) ([]grub.ObjectInfo, error) {
+	if err := b.inject(ctx, "list", prefix); err != nil {
+		return nil, err
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var out []grub.ObjectInfo
+	for k, obj := range b.objects {
+		if strings.HasPrefix(k, prefix) {
+			out = append(out, cloneInfo(obj.info))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// cloneInfo copies object metadata so callers cannot mutate stored maps.
+func cloneInfo(info grub.ObjectInfo) grub.ObjectInfo {
+	info.Metadata = maps.Clone(info.Metadata)
+	return info
+}
@@ -0,0 +1,168 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/grub"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+
+	buf := []byte("one")
+	if err := m.Set(ctx, "a/1", buf, 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	buf[0] = 'X'
+	got, err := m.Get(ctx, "a/1")
+	if err != nil || string(got) != "one" {
+		t.Errorf("expected stored copy, got %q, %v", got, err)
+	}
+	if _, err := m.Get(ctx, "missing"); !errors.Is(err, grub.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	_ = m.SetBatch(ctx, map[string][]byte{"a/3": []byte("3"), "a/2": []byte("2"), "b/1": []byte("b")}, 0)
+	keys, _ := m.List(ctx, "a/", 0)
+	if strings.Join(keys, ",") != "a/1,a/2,a/3" {
+		t.Errorf("expected sorted prefix listing, got %v", keys)
+	}
+	keys, _ = m.List(ctx, "a/", 2)
+	if len(keys) != 2 {
+		t.Errorf("expected limit applied, got %v", keys)
+	}
+	batch, _ := m.GetBatch(ctx, []string{"a/2", "missing"})
+	if len(batch) != 1 || string(batch["a/2"]) != "2" {
+		t.Errorf("expected found keys only, got %v", batch)
+	}
+
+	if err := m.Delete(ctx, "a/1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ok, _ := m.Exists(ctx, "a/1"); ok {
+		t.Error("expected key deleted")
+	}
+}
+
+func TestMemoryStoreTTL(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	_ = m.Set(ctx, "short", []byte("x"), 20*time.Millisecond)
+	_ = m.Set(ctx, "forever", []byte("y"), 0)
+
+	if ok, _ := m.Exists(ctx, "short"); !ok {
+		t.Fatal("expected entry before expiry")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := m.Get(ctx, "short"); !errors.Is(err, grub.ErrNotFound) {
+		t.Errorf("expected expired entry gone, got %v", err)
+	}
+	keys, _ := m.List(ctx, "", 0)
+	if strings.Join(keys, ",") != "forever" {
+		t.Errorf("expected expired entries unlisted, got %v", keys)
+	}
+}
+
+func TestMemoryBucket(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBucket()
+
+	info := &grub.ObjectInfo{ContentType: "text/plain", Metadata: map[string]string{"owner": "a"}}
+	if err := b.Put(ctx, "docs/1", []byte("hello"), info); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	info.Metadata["owner"] = "changed"
+	data, got, err := b.Get(ctx, "docs/1")
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("Get: %q, %v", data, err)
+	}
+	if got.Key != "docs/1" || got.Size != 5 || got.ContentType != "text/plain" || got.Metadata["owner"] != "a" {
+		t.Errorf("unexpected metadata %+v", got)
+	}
+	if got.ETag == "" {
+		t.Error("expected ETag set")
+	}
+
+	_ = b.Put(ctx, "docs/0", []byte("x"), nil)
+	_ = b.Put(ctx, "other", []byte("x"), nil)
+	infos, _ := b.List(ctx, "docs/", 0)
+	if len(infos) != 2 || infos[0].Key != "docs/0" || infos[1].Key != "docs/1" {
+		t.Errorf("expected sorted prefix listing, got %+v", infos)
+	}
+	if _, _, err := b.Get(ctx, "missing"); !errors.Is(err, grub.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	_ = b.Delete(ctx, "docs/1")
+	if ok, _ := b.Exists(ctx, "docs/1"); ok {
+		t.Error("expected object deleted")
+	}
+}
+
+func TestMemoryFaults(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	boom := errors.New("boom")
+
+	m.Fail("set", boom)
+	if err := m.Set(ctx, "k", []byte("v"), 0); !errors.Is(err, boom) {
+		t.Errorf("expected injected error, got %v", err)
+	}
+	if _, err := m.Get(ctx, "k"); !errors.Is(err, grub.ErrNotFound) {
+		t.Errorf("expected other operations unaffected, got %v", err)
+	}
+	m.Fail("", boom)
+	if _, err := m.Exists(ctx, "k"); !errors.Is(err, boom) {
+		t.Errorf("expected catch-all error, got %v", err)
+	}
+	m.Clear()
+
+	m.Hook(func(_ context.Context, op, key string) error {
+		if op == "get" && key == "bad" {
+			return boom
+		}
+		return nil
+	})
+	_ = m.Set(ctx, "bad", []byte("v"), 0)
+	if _, err := m.Get(ctx, "bad"); !errors.Is(err, boom) {
+		t.Errorf("expected hook error, got %v", err)
+	}
+	m.Clear()
+
+	b := NewMemoryBucket()
+	b.Delay("get", time.Second)
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := b.Get(short, "k"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected delay cut short by context, got %v", err)
+	}
+}
+
+func TestMemoryProvidersWithStore(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New()
+	p := NewMemoryStore()
+	store, err := NewStore[testModel](p, "sessions")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	ctx := context.Background()
+	if err := store.Set(ctx, "a", &testModel{ID: "a"}, time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	m, err := store.Get(ctx, "a")
+	if err != nil || m.ID != "a" {
+		t.Errorf("expected round trip, got %+v, %v", m, err)
+	}
+	res, _ := svc().Resource("kv://sessions")
+	if res.Provider != "*sum.MemoryStore" {
+		t.Errorf("expected provider recorded, got %s", res.Provider)
+	}
+}
@@ -0,0 +1,449 @@
+package sum
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/slush"
+)
+
+// DefaultBuckets are the histogram bounds, in seconds, used for request latency.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metricKind identifies how a family is rendered.
+type metricKind string
+
+const (
+	kindCounter   metricKind = "counter"
+	kindGauge     metricKind = "gauge"
+	kindHistogram metricKind = "histogram"
+)
+
+// Metrics holds counters, gauges and histograms and renders them in the
+// Prometheus text exposition format. Metrics are identified by name;
+// asking for an existing name returns the same metric.
+type Metrics struct {
+	families map[string]*metricFamily
+	mu       sync.Mutex
+}
+
+// NewMetrics creates an empty metrics registry.
+func NewMetrics() *Metrics {
+	return &Metrics{families: make(map[string]*metricFamily)}
+}
+
+// metricFamily is one named metric and its labelled series.
+type metricFamily struct {
+	series  map[string]*metricSeries
+	name    string
+	help    string
+	kind    metricKind
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+}
+
+// metricSeries is one set of label values within a family.
+type metricSeries struct {
+	values []string
+	counts []uint64
+	value  float64
+	sum    float64
+	count  uint64
+}
+
+// family returns the named family, creating it on first use.
+// Panics if the name is already registered with a different kind or labels.
+func (m *Metrics) family(name, help string, kind metricKind, buckets []float64, labels []string) *metricFamily {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.families[name]; ok {
+		if f.kind != kind || strings.Join(f.labels, ",") != strings.Join(labels, ",") {
+			panic(fmt.Sprintf("sum: metric %q already registered as %s%v", name, f.kind, f.labels))
+		}
+		return f
+	}
+	f := &metricFamily{
+		series:  make(map[string]*metricSeries),
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+	}
+	m.families[name] = f
+	return f
+}
+
+// with returns the series for the given label values. Callers hold f.mu.
+func (f *metricFamily) with(values []string) *metricSeries {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("sum: metric %q expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &metricSeries{values: append([]string(nil), values...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// each calls fn with every series' label values and value.
+func (f *metricFamily) each(fn func(values []string, v float64)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, s := range f.series {
+		fn(s.values, s.value)
+	}
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct{ f *metricFamily }
+
+// Counter returns the named counter with the given label names.
+func (m *Metrics) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{f: m.family(name, help, kindCounter, nil, labels)}
+}
+
+// Inc adds one to the series for the given label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v to the series for the given label values.
+// Panics if v is negative.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("sum: counter %q cannot decrease", c.f.name))
+	}
+	c.f.mu.Lock()
+	c.f.with(values).value += v
+	c.f.mu.Unlock()
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct{ f *metricFamily }
+
+// Gauge returns the named gauge with the given label names.
+func (m *Metrics) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{f: m.family(name, help, kindGauge, nil, labels)}
+}
+
+// Set replaces the series value for the given label values.
+func (g *Gauge) Set(v float64, values ...string) {
+	g.f.mu.Lock()
+	g.f.with(values).value = v
+	g.f.mu.Unlock()
+}
+
+// Add adds v, which may be negative, to the series for the given label values.
+func (g *Gauge) Add(v float64, values ...string) {
+	g.f.mu.Lock()
+	g.f.with(values).value += v
+	g.f.mu.Unlock()
+}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct{ f *metricFamily }
+
+// Histogram returns the named histogram with the given bucket upper bounds
+// and label names. Nil buckets use DefaultBuckets.
+func (m *Metrics) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{f: m.family(name, help, kindHistogram, buckets, labels)}
+}
+
+// Observe records v in the series for the given label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.f.mu.Lock()
+	s := h.f.with(values)
+	if i := sort.SearchFloat64s(h.f.buckets, v); i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+	h.f.mu.Unlock()
+}
+
+// WriteTo renders every metric in the Prometheus text format,
+// families and series sorted for stable output.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	families := make([]*metricFamily, 0, len(m.families))
+	for _, f := range m.families {
+		families = append(families, f)
+	}
+	m.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	var b strings.Builder
+	for _, f := range families {
+		f.write(&b)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// write renders one family.
+func (f *metricFamily) write(b *strings.Builder) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(b, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", f.name, f.kind)
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := f.series[k]
+		if f.kind != kindHistogram {
+			fmt.Fprintf(b, "%s%s %s\n", f.name, formatLabels(f.labels, s.values, ""), formatFloat(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range f.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.values, formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.values, "+Inf"), s.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", f.name, formatLabels(f.labels, s.values, ""), formatFloat(s.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", f.name, formatLabels(f.labels, s.values, ""), s.count)
+	}
+}
+
+// formatLabels renders a label set, appending le when non-empty.
+func formatLabels(names, values []string, le string) string {
+	if len(names) == 0 && le == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatFloat renders a sample value.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = m.WriteTo(w)
+	})
+}
+
+// Metrics returns the service's metrics registry for custom instrumentation.
+func (s *Service) Metrics() *Metrics {
+	return s.metrics
+}
+
+// ServeMetrics mounts the Prometheus text-format endpoint at path, e.g.
+// "/metrics", and turns on the built-in instrumentation:
+//
+//   - sum_events_total: capitan emissions per signal and severity
+//   - sum_registry_access_total: registry lookups per interface and outcome
+//   - sum_http_request_duration_seconds: request latency per route and status
+//   - sum_store_operations_total: operations per catalog database, store and bucket
+//   - sum_catalog_resources: resources registered in the scio catalog
+//
+// Store and bucket operations are counted only for those created after it.
+func (s *Service) ServeMetrics(path string) {
+	s.Mount("GET "+path, s.metricsHandler())
+}
+
+// metricsHandler turns on instrumentation and returns the endpoint handler.
+func (s *Service) metricsHandler() http.Handler {
+	s.instrument()
+	handler := s.metrics.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.recordCatalog()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// instrument installs the signal observer and request middleware once.
+func (s *Service) instrument() {
+	s.mu.Lock()
+	if s.instrumented {
+		s.mu.Unlock()
+		return
+	}
+	s.instrumented = true
+	s.mu.Unlock()
+
+	events := s.metrics.Counter("sum_events_total", "Signals emitted, by signal and severity.", "signal", "severity")
+	access := s.metrics.Counter("sum_registry_access_total", "Service registry lookups, by interface and outcome.", "interface", "outcome")
+	obs := capitan.Observe(func(_ context.Context, ev *capitan.Event) {
+		name := ev.Signal().Name()
+		events.Inc(name, string(ev.Severity()))
+
+		var outcome string
+		switch name {
+		case SignalAccessed.Name():
+			outcome = "accessed"
+		case SignalDenied.Name():
+			outcome = "denied"
+		case SignalNotFound.Name():
+			outcome = "not_found"
+		default:
+			return
+		}
+		iface, _ := slush.KeyInterface.From(ev)
+		access.Inc(iface, outcome)
+	})
+
+	latency := s.metrics.Histogram("sum_http_request_duration_seconds", "HTTP request latency, by method, route and status.", nil, "method", "route", "status")
+
+	s.mu.Lock()
+	s.observer = obs
+	s.latency = latency
+	s.mu.Unlock()
+	s.onShutdown(func(ctx context.Context) error {
+		err := obs.Drain(ctx)
+		obs.Close()
+		return err
+	})
+}
+
+// measure records the latency and status of each request.
+func (s *Service) measure(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		latency := s.latency
+		s.mu.RUnlock()
+		if latency == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		latency.Observe(time.Since(start).Seconds(), r.Method, s.routeOf(r), strconv.Itoa(rec.status))
+	})
+}
+
+// unmatchedRoute labels requests that matched no known pattern, so scans of
+// arbitrary paths cannot grow the latency histogram without bound.
+const unmatchedRoute = "unmatched"
+
+// routeOf labels a served request with its matched pattern.
+func (s *Service) routeOf(r *http.Request) string {
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+	if _, pattern := s.mux.Handler(r); pattern != "" {
+		return pattern
+	}
+	return unmatchedRoute
+}
+
+// recordCatalog refreshes the catalog resource gauge.
+func (s *Service) recordCatalog() {
+	g := s.metrics.Gauge("sum_catalog_resources", "Resources registered in the data catalog.", "variant", "uri")
+	for _, r := range s.catalog.Sources() {
+		g.Set(1, string(r.Variant), r.URI)
+	}
+}
+
+// statusRecorder captures the response status while keeping the optional
+// interfaces streams and websockets rely on.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wrote {
+		r.status = code
+		r.wrote = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wrote = true
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	r.status = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// observing reports whether store and bucket operations need wrapping:
+// they are counted once metrics are served and traced once an exporter is
+// set. The counter is nil when only tracing is on.
+func (s *Service) observing() (*Counter, bool) {
+	s.mu.RLock()
+	counted, traced := s.instrumented, s.exporter != nil
+	s.mu.RUnlock()
+	if counted {
+		return s.storeOps(), true
+	}
+	return nil, traced
+}
+
+// storeOps returns the counter data operations report to.
+func (s *Service) storeOps() *Counter {
+	return s.metrics.Counter("sum_store_operations_total", "Database, store and bucket operations, by catalog resource, operation and outcome.", "uri", "op", "outcome")
+}
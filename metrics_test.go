@@ -0,0 +1,236 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/rocco"
+)
+
+func TestMetricsTextFormat(t *testing.T) {
+	m := NewMetrics()
+	m.Counter("test_requests_total", "Requests.", "path").Add(2, `/a"b`)
+	m.Gauge("test_temperature", "Temperature\nin celsius.").Set(21.5)
+	h := m.Histogram("test_latency_seconds", "Latency.", []float64{1, 0.1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	var b strings.Builder
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	want := `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.1"} 1
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 3.55
+test_latency_seconds_count 3
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{path="/a\"b"} 2
+# HELP test_temperature Temperature\nin celsius.
+# TYPE test_temperature gauge
+test_temperature 21.5
+`
+	if b.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestMetricsRegistrationConflicts(t *testing.T) {
+	m := NewMetrics()
+	c := m.Counter("test_total", "Total.", "a")
+	if m.Counter("test_total", "Total.", "a").f != c.f {
+		t.Error("expected the same counter for the same name")
+	}
+
+	assertPanics(t, "kind mismatch", func() { m.Gauge("test_total", "Total.", "a") })
+	assertPanics(t, "label mismatch", func() { m.Counter("test_total", "Total.", "b") })
+	assertPanics(t, "label count", func() { c.Inc() })
+	assertPanics(t, "negative counter", func() { c.Add(-1, "x") })
+}
+
+func assertPanics(t *testing.T, name string, fn func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s: expected panic", name)
+		}
+	}()
+	fn()
+}
+
+func TestServeMetricsInstrumentation(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	svc := New()
+	svc.ServeMetrics("/metrics")
+
+	k := Start()
+	Register[testGreeterIface](k, testGreeter{})
+	Freeze(k)
+
+	ctx := context.Background()
+	event := NewInfoEvent[testEventInt](capitan.NewSignal("test.metrics.event", "Metrics event"))
+	event.Emit(ctx, testEventInt{Value: 1})
+	event.Emit(ctx, testEventInt{Value: 2})
+	if _, err := Use[testGreeterIface](ctx); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+
+	store, err := NewStore[testInvoice](NewMemoryStore(), "invoices")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	if err := store.Set(ctx, "a", &testInvoice{ID: "a"}, 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := store.Get(ctx, "missing"); err == nil {
+		t.Fatal("expected error for missing key")
+	}
+
+	rec := httptest.NewRecorder()
+	svc.route(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`sum_events_total{signal="test.metrics.event",severity="INFO"} 2`,
+		`sum_registry_access_total{interface=`,
+		`outcome="accessed"} 1`,
+		`sum_store_operations_total{uri="kv://invoices",op="set",outcome="ok"} 1`,
+		`sum_store_operations_total{uri="kv://invoices",op="get",outcome="error"} 1`,
+		`sum_catalog_resources{variant="kv",uri="kv://invoices"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestMeasureRecordsRouteAndStatus(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	svc := New()
+	svc.instrument()
+	svc.Mount("POST /orders/{id}", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	h := svc.measure(svc.route(http.NotFoundHandler()))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders/42", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	var b strings.Builder
+	_, _ = svc.Metrics().WriteTo(&b)
+	for _, want := range []string{
+		`sum_http_request_duration_seconds_count{method="POST",route="POST /orders/{id}",status="201"} 1`,
+		`sum_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, b.String())
+		}
+	}
+}
+
+// serveTestEngine starts svc on a free port and returns the status of a GET
+// to path once the engine is listening.
+func serveTestEngine(t *testing.T, svc *Service, path string) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+	go func() { _ = svc.Start("127.0.0.1", port) }()
+	t.Cleanup(func() { _ = svc.Shutdown(context.Background()) })
+
+	url := "http://127.0.0.1:" + strconv.Itoa(port) + path
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("engine did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMeasureLabelsEngineRoutes(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	svc := New()
+	svc.Handle(rocco.GET("/invoices/{id}", func(*rocco.Request[rocco.NoBody]) (testInvoice, error) {
+		return testInvoice{ID: "inv_1"}, nil
+	}))
+	svc.ServeMetrics("/metrics")
+
+	if code := serveTestEngine(t, svc, "/invoices/42"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	// Latency is observed after the response is written, so poll for it.
+	var b strings.Builder
+	var line string
+	deadline := time.Now().Add(2 * time.Second)
+	for line == "" && time.Now().Before(deadline) {
+		b.Reset()
+		_, _ = svc.Metrics().WriteTo(&b)
+		for _, l := range strings.Split(b.String(), "\n") {
+			if strings.HasPrefix(l, "sum_http_request_duration_seconds_count{") && strings.Contains(l, `status="200"`) {
+				line = l
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !strings.Contains(line, "/invoices/{id}") {
+		t.Errorf("expected the engine route as label, got %q in:\n%s", line, b.String())
+	}
+}
+
+func TestServiceMiddlewareIgnoresCallOrder(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	svc := New()
+	svc.Mount("GET /early", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	exp := &testSpanExporter{}
+	svc.WithCorrelation().WithTracing(exp)
+	svc.instrument()
+
+	rec := httptest.NewRecorder()
+	svc.middleware(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/early", nil))
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected mounted handler, got %d", rec.Code)
+	}
+	if rec.Header().Get(HeaderRequestID) == "" {
+		t.Error("expected correlation ID on a route mounted before WithCorrelation")
+	}
+	if span := exp.find(t, "http GET"); span.Attributes["http.route"] != "GET /early" {
+		t.Errorf("unexpected request span %+v", span)
+	}
+	var b strings.Builder
+	_, _ = svc.Metrics().WriteTo(&b)
+	if want := `sum_http_request_duration_seconds_count{method="GET",route="GET /early",status="202"} 1`; !strings.Contains(b.String(), want) {
+		t.Errorf("missing %q in:\n%s", want, b.String())
+	}
+}
@@ -0,0 +1,303 @@
+package sum
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrNoDownMigration is returned when rolling back a migration that has no
+// down file.
+var ErrNoDownMigration = errors.New("migration has no down file")
+
+// migrationFile matches "<version>_<name>.up.sql" and "<version>_<name>.down.sql".
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// migrateMu serialises runs on databases without a cross-process lock.
+var migrateMu sync.Mutex
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Name    string
+	Up      string
+	Down    string
+	Version int64
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	AppliedAt time.Time `json:"applied_at,omitempty"`
+	Name      string    `json:"name"`
+	Version   int64     `json:"version"`
+	Applied   bool      `json:"applied"`
+}
+
+// MigrateOption configures a Migrator.
+type MigrateOption func(*Migrator)
+
+// WithMigrationsTable sets the table recording applied versions.
+// The default is "schema_migrations".
+func WithMigrationsTable(table string) MigrateOption {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// Migrator applies versioned SQL migrations to a database.
+//
+// Runs hold a lock for their duration so replicas starting together apply
+// each migration once: an advisory lock on postgres, GET_LOCK on mysql, and
+// a process-local mutex elsewhere. Files may hold several statements if the
+// driver allows it, e.g. mysql with multiStatements=true.
+//
+// Where DDL is transactional, as on postgres, sqlite and SQL Server, each
+// migration runs in a transaction together with its version record, so a
+// failed migration leaves no trace. MySQL and MariaDB commit DDL implicitly,
+// so there the body runs on its own and the version is recorded only once
+// it has succeeded; a body that fails part-way may leave its earlier
+// statements applied and unrecorded, to be cleaned up by hand.
+type Migrator struct {
+	db         *sqlx.DB
+	table      string
+	migrations []Migration
+}
+
+// NewMigrator loads migrations from dir in fsys, typically an embed.FS.
+// Files are named "<version>_<name>.up.sql" with an optional matching
+// ".down.sql"; other files are ignored.
+func NewMigrator(db *sqlx.DB, fsys fs.FS, dir string, opts ...MigrateOption) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	m := &Migrator{db: db, table: "schema_migrations", migrations: migrations}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// LoadMigrations reads migrations from dir in fsys, sorted by version.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name must be <version>_<name>.up.sql or .down.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d used by %q and %q", version, mig.Name, match[2])
+		}
+		if match[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+	out := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		out = append(out, *mig)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Migrations returns the loaded migrations in version order.
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// Up applies every pending migration in version order.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sqlx.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			record := m.db.Rebind("INSERT INTO " + m.table + " (version, name, applied_at) VALUES (?, ?, ?)")
+			if err := m.apply(ctx, conn, mig, mig.Up, record, mig.Version, mig.Name, time.Now().UTC()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the most recently applied steps migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.locked(ctx, func(conn *sqlx.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, ErrNoDownMigration)
+			}
+			record := m.db.Rebind("DELETE FROM " + m.table + " WHERE version = ?")
+			if err := m.apply(ctx, conn, mig, mig.Down, record, mig.Version); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// Status reports every loaded migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MigrationStatus, len(m.migrations))
+	for i, mig := range m.migrations {
+		at, ok := applied[mig.Version]
+		out[i] = MigrationStatus{Version: mig.Version, Name: mig.Name, Applied: ok, AppliedAt: at}
+	}
+	return out, nil
+}
+
+// applied creates the migrations table if needed and returns applied
+// versions with their timestamps.
+func (m *Migrator) applied(ctx context.Context, conn *sqlx.Conn) (map[int64]time.Time, error) {
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+m.table+
+		" (version BIGINT PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL)"); err != nil {
+		return nil, fmt.Errorf("create %s: %w", m.table, err)
+	}
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM "+m.table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		out[version] = at
+	}
+	return out, rows.Err()
+}
+
+// apply runs one migration body and its version bookkeeping, in one
+// transaction where the dialect's DDL is transactional.
+func (m *Migrator) apply(ctx context.Context, conn *sqlx.Conn, mig Migration, body, record string, args ...any) error {
+	if m.db.DriverName() == "mysql" {
+		if _, err := conn.ExecContext(ctx, body); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := conn.ExecContext(ctx, record, args...); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		return nil
+	}
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return tx.Commit()
+}
+
+// locked runs fn on a dedicated connection while holding the migration lock.
+func (m *Migrator) locked(ctx context.Context, fn func(*sqlx.Conn) error) (err error) {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(m.table))
+	key := int64(h.Sum64())
+
+	switch m.db.DriverName() {
+	case "postgres", "pgx", "pgx/v5":
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+			return fmt.Errorf("migration lock: %w", err)
+		}
+		defer func() {
+			_, uerr := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", key)
+			err = errors.Join(err, uerr)
+		}()
+	case "mysql":
+		var got sql.NullInt64
+		if err := conn.QueryRowxContext(ctx, "SELECT GET_LOCK(?, -1)", m.table).Scan(&got); err != nil {
+			return fmt.Errorf("migration lock: %w", err)
+		}
+		if got.Int64 != 1 {
+			return errors.New("migration lock: GET_LOCK failed")
+		}
+		defer func() {
+			_, uerr := conn.ExecContext(context.WithoutCancel(ctx), "SELECT RELEASE_LOCK(?)", m.table)
+			err = errors.Join(err, uerr)
+		}()
+	default:
+		migrateMu.Lock()
+		defer migrateMu.Unlock()
+	}
+	return fn(conn)
+}
+
+// Migrate applies pending migrations when the service starts, before the
+// engine accepts traffic. A failed migration fails Start.
+func (s *Service) Migrate(m *Migrator) *Service {
+	s.onStart(m.Up)
+	return s
+}
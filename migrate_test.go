@@ -0,0 +1,344 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// testMigrateDB is the in-memory database behind the shared "summigrate"
+// test driver. It understands the migrator's bookkeeping statements. Other statements are logged, and also recorded in
+// intx when run inside a transaction; any containing "FAIL" return an error.
+type testMigrateDB struct {
+	applied map[int64]time.Time
+	lock    sync.Mutex
+	log     []string
+	execErr func(query string) error
+	rows    func(query string) *testMigrateRows
+	intx    []string
+	txlog   []string
+	mu      sync.Mutex
+}
+
+var testMigrateDBs = struct {
+	m  map[string]*testMigrateDB
+	mu sync.Mutex
+}{m: make(map[string]*testMigrateDB)}
+
+func init() {
+	sql.Register("summigrate", testMigrateDriver{})
+}
+
+type testMigrateDriver struct{}
+
+func (testMigrateDriver) Open(name string) (driver.Conn, error) {
+	testMigrateDBs.mu.Lock()
+	defer testMigrateDBs.mu.Unlock()
+	db, ok := testMigrateDBs.m[name]
+	if !ok {
+		db = &testMigrateDB{applied: make(map[int64]time.Time)}
+		testMigrateDBs.m[name] = db
+	}
+	return &testMigrateConn{db: db}, nil
+}
+
+type testMigrateConn struct {
+	db   *testMigrateDB
+	intx bool
+}
+
+func (c *testMigrateConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *testMigrateConn) Close() error { return nil }
+func (c *testMigrateConn) Begin() (driver.Tx, error) {
+	c.intx = true
+	return testMigrateTx{conn: c}, nil
+}
+
+func (c *testMigrateConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	db := c.db
+	switch {
+	case strings.HasPrefix(query, "SELECT pg_advisory_lock"):
+		db.lock.Lock()
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "SELECT pg_advisory_unlock"), strings.HasPrefix(query, "SELECT RELEASE_LOCK"):
+		db.lock.Unlock()
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS"):
+		return driver.RowsAffected(0), nil
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.execErr != nil {
+		if err := db.execErr(query); err != nil {
+			return nil, err
+		}
+	}
+	bookkeeping := strings.Contains(query, "schema_migrations")
+	switch {
+	case bookkeeping && strings.HasPrefix(query, "INSERT INTO"):
+		db.applied[args[0].Value.(int64)] = args[2].Value.(time.Time)
+	case bookkeeping && strings.HasPrefix(query, "DELETE FROM"):
+		delete(db.applied, args[0].Value.(int64))
+	case strings.Contains(query, "FAIL"):
+		return nil, errors.New("syntax error")
+	default:
+		db.log = append(db.log, query)
+		if c.intx {
+			db.intx = append(db.intx, query)
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *testMigrateConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if strings.HasPrefix(query, "SELECT GET_LOCK") {
+		c.db.lock.Lock()
+		return &testMigrateRows{cols: []string{"lock"}, rows: [][]driver.Value{{int64(1)}}}, nil
+	}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	if c.db.execErr != nil {
+		if err := c.db.execErr(query); err != nil {
+			return nil, err
+		}
+	}
+	if c.db.rows != nil && !strings.Contains(query, "schema_migrations") {
+		if rows := c.db.rows(query); rows != nil {
+			return rows, nil
+		}
+	}
+	rows := &testMigrateRows{cols: []string{"version", "applied_at"}}
+	for v, at := range c.db.applied {
+		rows.rows = append(rows.rows, []driver.Value{v, at})
+	}
+	return rows, nil
+}
+
+type testMigrateTx struct{ conn *testMigrateConn }
+
+func (tx testMigrateTx) Commit() error   { return tx.end("COMMIT") }
+func (tx testMigrateTx) Rollback() error { return tx.end("ROLLBACK") }
+
+func (tx testMigrateTx) end(op string) error {
+	tx.conn.intx = false
+	db := tx.conn.db
+	db.mu.Lock()
+	db.txlog = append(db.txlog, op)
+	db.mu.Unlock()
+	return nil
+}
+
+// testMigrateRows is a canned result set. types and nullable, when set,
+// describe each column for callers inspecting column types.
+type testMigrateRows struct {
+	cols     []string
+	types    []string
+	nullable []bool
+	rows     [][]driver.Value
+}
+
+func (r *testMigrateRows) Columns() []string { return r.cols }
+func (r *testMigrateRows) Close() error      { return nil }
+func (r *testMigrateRows) ColumnTypeDatabaseTypeName(i int) string {
+	if i >= len(r.types) {
+		return ""
+	}
+	return r.types[i]
+}
+func (r *testMigrateRows) ColumnTypeNullable(i int) (bool, bool) {
+	if i >= len(r.nullable) {
+		return false, false
+	}
+	return r.nullable[i], true
+}
+func (r *testMigrateRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func openTestMigrateDB(t *testing.T, dialect string) (*sqlx.DB, *testMigrateDB) {
+	t.Helper()
+	db, err := sql.Open("summigrate", t.Name())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	testMigrateDBs.mu.Lock()
+	state := testMigrateDBs.m[t.Name()]
+	testMigrateDBs.mu.Unlock()
+	return sqlx.NewDb(db, dialect), state
+}
+
+var testMigrations = fstest.MapFS{
+	"migrations/0001_create_users.up.sql":    {Data: []byte("CREATE TABLE users")},
+	"migrations/0001_create_users.down.sql":  {Data: []byte("DROP TABLE users")},
+	"migrations/0002_add_email.up.sql":       {Data: []byte("ALTER TABLE users ADD email")},
+	"migrations/0002_add_email.down.sql":     {Data: []byte("ALTER TABLE users DROP email")},
+	"migrations/0010_create_orders.up.sql":   {Data: []byte("CREATE TABLE orders")},
+	"migrations/0010_create_orders.down.sql": {Data: []byte("DROP TABLE orders")},
+	"migrations/README.md":                   {Data: []byte("ignored")},
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(testMigrations, "migrations")
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	if len(migrations) != 3 || migrations[0].Version != 1 || migrations[2].Version != 10 {
+		t.Fatalf("unexpected migrations %+v", migrations)
+	}
+	if migrations[1].Name != "add_email" || migrations[1].Up != "ALTER TABLE users ADD email" || migrations[1].Down != "ALTER TABLE users DROP email" {
+		t.Errorf("unexpected migration %+v", migrations[1])
+	}
+
+	bad := fstest.MapFS{"m/create_users.sql": {Data: []byte("x")}}
+	if _, err := LoadMigrations(bad, "m"); err == nil {
+		t.Error("expected error for unversioned file")
+	}
+	downOnly := fstest.MapFS{"m/0001_x.down.sql": {Data: []byte("x")}}
+	if _, err := LoadMigrations(downOnly, "m"); err == nil {
+		t.Error("expected error for missing up file")
+	}
+	clash := fstest.MapFS{"m/0001_a.up.sql": {Data: []byte("x")}, "m/0001_b.up.sql": {Data: []byte("y")}}
+	if _, err := LoadMigrations(clash, "m"); err == nil {
+		t.Error("expected error for duplicate version")
+	}
+}
+
+func TestMigratorUpAndDown(t *testing.T) {
+	db, state := openTestMigrateDB(t, "sqlite3")
+	ctx := context.Background()
+	m, err := NewMigrator(db, testMigrations, "migrations")
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("second Up: %v", err)
+	}
+	want := []string{"CREATE TABLE users", "ALTER TABLE users ADD email", "CREATE TABLE orders"}
+	if strings.Join(state.log, ";") != strings.Join(want, ";") {
+		t.Errorf("expected each migration applied once in order, got %q", state.log)
+	}
+
+	if err := m.Down(ctx, 2); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !status[0].Applied || status[0].AppliedAt.IsZero() || status[1].Applied || status[2].Applied {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if got := state.log[len(state.log)-2:]; got[0] != "DROP TABLE orders" || got[1] != "ALTER TABLE users DROP email" {
+		t.Errorf("expected newest rolled back first, got %q", got)
+	}
+}
+
+func TestMigratorStopsOnFailure(t *testing.T) {
+	db, state := openTestMigrateDB(t, "sqlite3")
+	fsys := fstest.MapFS{
+		"m/0001_ok.up.sql":    {Data: []byte("CREATE TABLE ok")},
+		"m/0002_bad.up.sql":   {Data: []byte("FAIL")},
+		"m/0003_later.up.sql": {Data: []byte("CREATE TABLE later")},
+	}
+	m, err := NewMigrator(db, fsys, "m")
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	err = m.Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "2_bad") {
+		t.Fatalf("expected failure naming the migration, got %v", err)
+	}
+	if len(state.applied) != 1 || len(state.log) != 1 {
+		t.Errorf("expected only the first migration applied, got %v %q", state.applied, state.log)
+	}
+	if err := m.Down(context.Background(), 1); !errors.Is(err, ErrNoDownMigration) {
+		t.Errorf("expected ErrNoDownMigration, got %v", err)
+	}
+}
+
+func TestMigratorOnMySQLRecordsAfterBody(t *testing.T) {
+	db, state := openTestMigrateDB(t, "mysql")
+	fsys := fstest.MapFS{
+		"m/0001_ok.up.sql":  {Data: []byte("CREATE TABLE ok")},
+		"m/0002_bad.up.sql": {Data: []byte("FAIL")},
+	}
+	m, err := NewMigrator(db, fsys, "m")
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	if err := m.Up(context.Background()); err == nil || !strings.Contains(err.Error(), "2_bad") {
+		t.Fatalf("expected failure naming the migration, got %v", err)
+	}
+	if _, ok := state.applied[1]; !ok || len(state.applied) != 1 {
+		t.Errorf("expected only the successful migration recorded, got %v", state.applied)
+	}
+	if len(state.txlog) != 0 || len(state.intx) != 0 {
+		t.Errorf("expected no transaction around mysql DDL, got %q %q", state.txlog, state.intx)
+	}
+}
+
+func TestMigratorLocksAcrossReplicas(t *testing.T) {
+	db, state := openTestMigrateDB(t, "postgres")
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for range 4 {
+		m, err := NewMigrator(db, testMigrations, "migrations")
+		if err != nil {
+			t.Fatalf("NewMigrator: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- m.Up(context.Background())
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Up: %v", err)
+		}
+	}
+	if len(state.log) != 3 {
+		t.Errorf("expected each migration applied once, got %q", state.log)
+	}
+}
+
+func TestServiceMigratesBeforeStart(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	db, _ := openTestMigrateDB(t, "sqlite3")
+	m, err := NewMigrator(db, fstest.MapFS{"m/0001_bad.up.sql": {Data: []byte("FAIL")}}, "m")
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	if err := New().Migrate(m).Start("127.0.0.1", 0); err == nil || !strings.Contains(err.Error(), "1_bad") {
+		t.Errorf("expected Start to fail on migration, got %v", err)
+	}
+}
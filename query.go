@@ -0,0 +1,174 @@
+package sum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/sentinel"
+)
+
+// Query errors.
+var (
+	// ErrNoResponder indicates a query was asked before a responder was registered.
+	ErrNoResponder = errors.New("no responder registered for query")
+	// ErrResponderExists indicates a second responder tried to register.
+	ErrResponderExists = errors.New("query already has a responder")
+	// ErrResponderPanic indicates the responder panicked instead of answering.
+	ErrResponderPanic = errors.New("query responder panicked")
+)
+
+// DefaultQueryTimeout bounds Ask when the caller's context has no deadline,
+// so a responder that never answers cannot block the caller forever.
+const DefaultQueryTimeout = 30 * time.Second
+
+// queryTimeout is DefaultQueryTimeout, replaceable in tests.
+var queryTimeout = DefaultQueryTimeout
+
+// Query provides request/reply access to a signal with typed data.
+// Exactly one responder per signal answers via Handle; any number of
+// callers Ask. Responders are keyed by signal, so separately declared
+// Queries on the same signal share one responder.
+type Query[Req, Resp any] struct {
+	// Signal is the underlying capitan signal.
+	Signal capitan.Signal
+
+	// key carries the request envelope on the emission.
+	key capitan.GenericKey[*queryEnvelope[Req, Resp]]
+}
+
+// queryEnvelope pairs a request with the channel its answer is sent on.
+type queryEnvelope[Req, Resp any] struct {
+	request Req
+	reply   chan queryReply[Resp]
+}
+
+// queryReply is a responder's answer.
+type queryReply[Resp any] struct {
+	resp Resp
+	err  error
+}
+
+// responders holds the registered responder listener for each query signal.
+var responders = struct {
+	m  map[string]*Listener
+	mu sync.Mutex
+}{m: make(map[string]*Listener)}
+
+// responderFor returns the live responder for a signal. Entries whose
+// listener was closed by Shutdown are discarded. Callers hold responders.mu.
+func responderFor(signal string) *Listener {
+	l, ok := responders.m[signal]
+	if !ok {
+		return nil
+	}
+	if !l.live() {
+		delete(responders.m, signal)
+		return nil
+	}
+	return l
+}
+
+// NewQuery creates a Query on the given signal.
+// The variant is derived automatically from Req and Resp via sentinel.
+func NewQuery[Req, Resp any](signal capitan.Signal) Query[Req, Resp] {
+	req := sentinel.Inspect[Req]()
+	resp := sentinel.Inspect[Resp]()
+	return Query[Req, Resp]{
+		Signal: signal,
+		key:    capitan.NewKey[*queryEnvelope[Req, Resp]]("query", capitan.Variant(req.FQDN+"->"+resp.FQDN)),
+	}
+}
+
+// Responder is a registered query handler.
+type Responder struct {
+	listener *Listener
+	signal   string
+}
+
+// Close unregisters the handler so another responder may take its place.
+// It waits for queued queries to be answered, so calling it from inside the
+// handler deadlocks when capitan runs asynchronously; close from elsewhere,
+// or from a goroutine the handler starts.
+func (r *Responder) Close() {
+	responders.mu.Lock()
+	if responders.m[r.signal] == r.listener {
+		delete(responders.m, r.signal)
+	}
+	responders.mu.Unlock()
+	r.listener.Close()
+}
+
+// Handle registers the single responder for this query.
+// The handler receives the caller's context, including its deadline. A
+// handler panic is answered with ErrResponderPanic.
+// Returns ErrResponderExists if a responder is already registered.
+func (q Query[Req, Resp]) Handle(handler func(context.Context, Req) (Resp, error)) (*Responder, error) {
+	name := q.Signal.Name()
+	responders.mu.Lock()
+	defer responders.mu.Unlock()
+	if responderFor(name) != nil {
+		return nil, ErrResponderExists
+	}
+
+	l := capitan.Hook(q.Signal, func(ctx context.Context, ev *capitan.Event) {
+		env, ok := q.key.From(ev)
+		if !ok || env == nil {
+			return
+		}
+		if ctx.Err() != nil {
+			// The caller already gave up.
+			return
+		}
+		defer func() {
+			if p := recover(); p != nil {
+				env.reply <- queryReply[Resp]{err: fmt.Errorf("%w: %v", ErrResponderPanic, p)}
+			}
+		}()
+		resp, err := handler(ctx, env.request)
+		if ctx.Err() != nil {
+			// Late answers are dropped so the caller sees its deadline.
+			return
+		}
+		env.reply <- queryReply[Resp]{resp: resp, err: err}
+	})
+	listener := newListener(l)
+	responders.m[name] = listener
+	return &Responder{listener: listener, signal: name}, nil
+}
+
+// Ask sends req to the responder and waits for its answer.
+// Returns ErrNoResponder if nothing is registered, or the context error if
+// ctx ends before the responder replies. Without a deadline on ctx, Ask
+// waits at most DefaultQueryTimeout.
+func (q Query[Req, Resp]) Ask(ctx context.Context, req Req) (Resp, error) {
+	var zero Resp
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, queryTimeout)
+		defer cancel()
+	}
+
+	responders.mu.Lock()
+	registered := responderFor(q.Signal.Name()) != nil
+	responders.mu.Unlock()
+	if !registered {
+		return zero, ErrNoResponder
+	}
+
+	env := &queryEnvelope[Req, Resp]{
+		request: req,
+		reply:   make(chan queryReply[Resp], 1),
+	}
+	capitan.Emit(ctx, q.Signal, q.key.Field(env))
+
+	select {
+	case r := <-env.reply:
+		return r.resp, r.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
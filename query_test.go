@@ -0,0 +1,181 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/capitan"
+)
+
+type testPriceRequest struct {
+	SKU string
+}
+
+type testPriceResponse struct {
+	Cents int
+}
+
+func TestQueryAsk(t *testing.T) {
+	q := NewQuery[testPriceRequest, testPriceResponse](capitan.NewSignal("test.query.price", "Price lookup"))
+
+	r, err := q.Handle(func(_ context.Context, req testPriceRequest) (testPriceResponse, error) {
+		if req.SKU == "" {
+			return testPriceResponse{}, errors.New("sku required")
+		}
+		return testPriceResponse{Cents: len(req.SKU) * 100}, nil
+	})
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	defer r.Close()
+
+	resp, err := q.Ask(context.Background(), testPriceRequest{SKU: "abc"})
+	if err != nil {
+		t.Fatalf("Ask: %v", err)
+	}
+	if resp.Cents != 300 {
+		t.Errorf("expected 300, got %d", resp.Cents)
+	}
+
+	if _, err := q.Ask(context.Background(), testPriceRequest{}); err == nil || err.Error() != "sku required" {
+		t.Errorf("expected responder error, got %v", err)
+	}
+}
+
+func TestQueryNoResponder(t *testing.T) {
+	q := NewQuery[testPriceRequest, testPriceResponse](capitan.NewSignal("test.query.none", "No responder"))
+
+	if _, err := q.Ask(context.Background(), testPriceRequest{SKU: "x"}); !errors.Is(err, ErrNoResponder) {
+		t.Errorf("expected ErrNoResponder, got %v", err)
+	}
+}
+
+func TestQuerySingleResponder(t *testing.T) {
+	q := NewQuery[testPriceRequest, testPriceResponse](capitan.NewSignal("test.query.single", "Single responder"))
+	handler := func(context.Context, testPriceRequest) (testPriceResponse, error) {
+		return testPriceResponse{}, nil
+	}
+
+	r, err := q.Handle(handler)
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if _, err := q.Handle(handler); !errors.Is(err, ErrResponderExists) {
+		t.Errorf("expected ErrResponderExists, got %v", err)
+	}
+
+	r.Close()
+	r2, err := q.Handle(handler)
+	if err != nil {
+		t.Fatalf("Handle after Close: %v", err)
+	}
+	r2.Close()
+}
+
+func TestQueryDeadline(t *testing.T) {
+	q := NewQuery[testPriceRequest, testPriceResponse](capitan.NewSignal("test.query.slow", "Slow responder"))
+
+	r, err := q.Handle(func(ctx context.Context, _ testPriceRequest) (testPriceResponse, error) {
+		<-ctx.Done()
+		return testPriceResponse{}, nil
+	})
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.Ask(ctx, testPriceRequest{SKU: "x"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestQueryResponderPanic(t *testing.T) {
+	q := NewQuery[testPriceRequest, testPriceResponse](capitan.NewSignal("test.query.panic", "Panicking responder"))
+
+	r, err := q.Handle(func(context.Context, testPriceRequest) (testPriceResponse, error) {
+		panic("boom")
+	})
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	defer r.Close()
+
+	if _, err := q.Ask(context.Background(), testPriceRequest{SKU: "x"}); !errors.Is(err, ErrResponderPanic) {
+		t.Errorf("expected ErrResponderPanic, got %v", err)
+	}
+}
+
+func TestQueryDefaultTimeout(t *testing.T) {
+	prev := queryTimeout
+	queryTimeout = 20 * time.Millisecond
+	t.Cleanup(func() { queryTimeout = prev })
+
+	q := NewQuery[testPriceRequest, testPriceResponse](capitan.NewSignal("test.query.silent", "Silent responder"))
+	r, err := q.Handle(func(ctx context.Context, _ testPriceRequest) (testPriceResponse, error) {
+		<-ctx.Done()
+		return testPriceResponse{}, nil
+	})
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	defer r.Close()
+
+	if _, err := q.Ask(context.Background(), testPriceRequest{SKU: "x"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded without a caller deadline, got %v", err)
+	}
+}
+
+func TestQueryResponderSharedBySignal(t *testing.T) {
+	signal := capitan.NewSignal("test.query.shared", "Shared responder")
+	handler := func(context.Context, testPriceRequest) (testPriceResponse, error) {
+		return testPriceResponse{Cents: 42}, nil
+	}
+
+	r, err := NewQuery[testPriceRequest, testPriceResponse](signal).Handle(handler)
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	defer r.Close()
+
+	other := NewQuery[testPriceRequest, testPriceResponse](signal)
+	if _, err := other.Handle(handler); !errors.Is(err, ErrResponderExists) {
+		t.Errorf("expected ErrResponderExists from a separately declared query, got %v", err)
+	}
+	resp, err := other.Ask(context.Background(), testPriceRequest{SKU: "x"})
+	if err != nil || resp.Cents != 42 {
+		t.Errorf("expected answer from shared responder, got %v, %v", resp, err)
+	}
+
+	var zero Query[testPriceRequest, testPriceResponse]
+	if _, err := zero.Ask(context.Background(), testPriceRequest{}); !errors.Is(err, ErrNoResponder) {
+		t.Errorf("expected ErrNoResponder from zero-value query, got %v", err)
+	}
+}
+
+func TestQueryResponderReleasedOnShutdown(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	q := NewQuery[testPriceRequest, testPriceResponse](capitan.NewSignal("test.query.reset", "Reset responder"))
+	handler := func(context.Context, testPriceRequest) (testPriceResponse, error) {
+		return testPriceResponse{}, nil
+	}
+
+	if _, err := q.Handle(handler); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	closeListeners()
+	if _, err := q.Ask(context.Background(), testPriceRequest{}); !errors.Is(err, ErrNoResponder) {
+		t.Errorf("expected ErrNoResponder after listeners closed, got %v", err)
+	}
+	r, err := q.Handle(handler)
+	if err != nil {
+		t.Fatalf("Handle after listeners closed: %v", err)
+	}
+	r.Close()
+}
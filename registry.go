@@ -2,6 +2,7 @@ package sum
 
 import (
 	"context"
+	"reflect"
 
 	"github.com/zoobzio/capitan"
 	"github.com/zoobzio/slush"
@@ -26,6 +27,9 @@ type Handle[T any] struct {
 // Returns the Handle for chaining.
 func (h *Handle[T]) Guard(g Guard) *Handle[T] {
 	h.Handle.Guard(g)
+	record(fqdn(reflect.TypeFor[T]()), func(r *serviceRecord) {
+		r.guards = append(r.guards, GuardInfo{Kind: "custom", Name: guardName(g)})
+	})
 	return h
 }
 
@@ -33,6 +37,13 @@ func (h *Handle[T]) Guard(g Guard) *Handle[T] {
 // Equivalent to Guard(Require(tokens...)).
 func (h *Handle[T]) For(tokens ...Token) *Handle[T] {
 	h.Handle.Guard(Require(tokens...))
+	names := make([]string, len(tokens))
+	for i, t := range tokens {
+		names[i] = t.String()
+	}
+	record(fqdn(reflect.TypeFor[T]()), func(r *serviceRecord) {
+		r.guards = append(r.guards, GuardInfo{Kind: "token", Tokens: names})
+	})
 	return h
 }
 
@@ -74,19 +85,29 @@ func Freeze(k Key) {
 // Returns a Handle for optional guard configuration.
 // Panics if Start has not been called, key is invalid, or registry is frozen.
 func Register[T any](k Key, impl T) *Handle[T] {
-	return &Handle[T]{slush.Register[T](k, impl)}
+	h := &Handle[T]{slush.Register[T](k, impl)}
+	record(fqdn(reflect.TypeFor[T]()), func(r *serviceRecord) {
+		*r = serviceRecord{impl: fqdn(reflect.TypeOf(impl))}
+	})
+	return h
 }
 
 // Use retrieves a service by its contract type T.
 // Runs all registered guards with the provided context.
 // Returns ErrNotFound if not registered, ErrAccessDenied if a guard fails.
 func Use[T any](ctx context.Context) (T, error) {
-	return slush.Use[T](ctx)
+	ctx, span := StartSpan(ctx, "use "+reflect.TypeFor[T]().String())
+	defer span.End()
+	impl, err := slush.Use[T](ctx)
+	span.RecordError(err)
+	return impl, err
 }
 
 // MustUse retrieves a service by its contract type T.
 // Panics if the service is not registered or a guard fails.
 func MustUse[T any](ctx context.Context) T {
+	ctx, span := StartSpan(ctx, "use "+reflect.TypeFor[T]().String())
+	defer span.End()
 	return slush.MustUse[T](ctx)
 }
 
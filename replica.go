@@ -0,0 +1,279 @@
+package sum
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zoobzio/astql"
+	"github.com/zoobzio/grub"
+	"github.com/zoobzio/scio"
+)
+
+// ReplicaPolicy chooses which healthy replica serves a read.
+type ReplicaPolicy int
+
+// Replica policies.
+const (
+	// ReplicaRoundRobin spreads reads evenly across replicas.
+	ReplicaRoundRobin ReplicaPolicy = iota
+	// ReplicaLeastLatency sends reads to the replica with the lowest
+	// health-check latency.
+	ReplicaLeastLatency
+)
+
+// defaultReplicaInterval is how often replicas are pinged by default.
+const defaultReplicaInterval = 5 * time.Second
+
+// WithReplicaPolicy sets how NewReplicatedDatabase picks a replica.
+// The default is ReplicaRoundRobin.
+func WithReplicaPolicy(p ReplicaPolicy) ResourceOption {
+	return func(r *resourceRecord) {
+		r.replicaPolicy = p
+	}
+}
+
+// WithReplicaHealthInterval sets how often NewReplicatedDatabase pings each
+// replica. A replica that fails a ping serves no reads until one succeeds.
+// The default is 5 seconds.
+func WithReplicaHealthInterval(d time.Duration) ResourceOption {
+	return func(r *resourceRecord) {
+		r.replicaInterval = d
+	}
+}
+
+// primaryKey carries read routing preferences in a context.
+type primaryKey struct{}
+
+// primaryPref forces reads to the primary, either always or once a write
+// has been made with the context.
+type primaryPref struct {
+	written atomic.Bool
+	always  bool
+}
+
+// WithPrimaryReads returns a context whose reads go to the primary.
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, &primaryPref{always: true})
+}
+
+// WithReadYourWrites returns a context whose reads go to the primary once
+// any Database write has been made with it, so a request sees its own
+// writes despite replication lag.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, &primaryPref{})
+}
+
+// primaryRequired reports whether ctx routes reads to the primary.
+func primaryRequired(ctx context.Context) bool {
+	p, ok := ctx.Value(primaryKey{}).(*primaryPref)
+	return ok && (p.always || p.written.Load())
+}
+
+// markWritten records a write for WithReadYourWrites.
+func markWritten(ctx context.Context) {
+	if p, ok := ctx.Value(primaryKey{}).(*primaryPref); ok {
+		p.written.Store(true)
+	}
+}
+
+// ReplicaStatus reports a replica's health.
+type ReplicaStatus struct {
+	URI     string        `json:"uri"`
+	Latency time.Duration `json:"latency"`
+	Healthy bool          `json:"healthy"`
+}
+
+// replicaPool is one replica connection pool and its health.
+type replicaPool struct {
+	db      *sqlx.DB
+	uri     string
+	latency atomic.Int64
+	healthy atomic.Bool
+}
+
+// replicaSet routes reads across replicas and pings them in the background.
+type replicaSet struct {
+	stop     chan struct{}
+	pools    []*replicaPool
+	next     atomic.Uint64
+	interval time.Duration
+	policy   ReplicaPolicy
+	once     sync.Once
+}
+
+// NewReplicatedDatabase creates a Database[M] that writes to primary and
+// spreads reads across replicas. Get, Exists, ExecQuery and ExecSelect go
+// to a healthy replica unless the call is inside Transact, the context came
+// from WithPrimaryReads, or it came from WithReadYourWrites and has written.
+// With no healthy replica, reads fall back to the primary.
+//
+// The primary registers as "db://<table>" and each replica as
+// "db://<table>@replica-<n>", so operation counts show where reads landed.
+// Every replica is prepared and every URI checked before anything is
+// registered, so a bad replica or a taken URI leaves the catalog unchanged.
+// The catalog has no way to unregister, so if registration itself fails
+// part way, the primary and the replicas registered before the failure
+// stay in it.
+// Health checks stop when the service shuts down.
+func NewReplicatedDatabase[M any](primary *sqlx.DB, replicas []*sqlx.DB, table string, renderer astql.Renderer, opts ...ResourceOption) (*Database[M], error) {
+	s := svc()
+	uri := "db://" + table
+	gdbs := make([]*grub.Database[M], len(replicas))
+	uris := make([]string, len(replicas))
+	for i, db := range replicas {
+		gdb, err := grub.NewDatabase[M](db, table, renderer)
+		if err != nil {
+			return nil, err
+		}
+		gdbs[i] = gdb
+		uris[i] = fmt.Sprintf("%s@replica-%d", uri, i+1)
+	}
+	for _, u := range append([]string{uri}, uris...) {
+		if s.catalog.Resource(u) != nil {
+			return nil, fmt.Errorf("%w: %s", scio.ErrResourceExists, u)
+		}
+	}
+
+	d, err := NewDatabase[M](primary, table, renderer, opts...)
+	if err != nil {
+		return nil, err
+	}
+	cfg := newResourceRecord(reflect.TypeFor[M](), primary.DriverName(), opts)
+	rs := &replicaSet{
+		stop:     make(chan struct{}),
+		interval: cfg.replicaInterval,
+		policy:   cfg.replicaPolicy,
+	}
+	if rs.interval <= 0 {
+		rs.interval = defaultReplicaInterval
+	}
+	for i, db := range replicas {
+		r := newResourceRecord(reflect.TypeFor[M](), db.DriverName(), opts)
+		regOpts := append(r.registrationOptions(), scio.WithTag("role", "replica"), scio.WithTag("primary", d.uri))
+		if err := s.catalog.RegisterDatabase(uris[i], gdbs[i].Atomic(), regOpts...); err != nil {
+			return nil, err
+		}
+		s.addResource(uris[i], r)
+		pool := &replicaPool{db: db, uri: uris[i]}
+		pool.healthy.Store(true)
+		rs.pools = append(rs.pools, pool)
+	}
+	d.replicaDBs = gdbs
+	d.replicas = rs
+	go rs.run()
+	s.onShutdown(func(context.Context) error {
+		rs.close()
+		return nil
+	})
+	return d, nil
+}
+
+// Replicas reports the health of each replica, or nil if the database has
+// none.
+func (d *Database[M]) Replicas() []ReplicaStatus {
+	if d.replicas == nil {
+		return nil
+	}
+	out := make([]ReplicaStatus, len(d.replicas.pools))
+	for i, p := range d.replicas.pools {
+		out[i] = ReplicaStatus{URI: p.uri, Healthy: p.healthy.Load(), Latency: time.Duration(p.latency.Load())}
+	}
+	return out
+}
+
+// reader returns the database a read should use and the URI it is counted
+// against.
+func (d *Database[M]) reader(ctx context.Context) (*grub.Database[M], string) {
+	if d.replicas == nil || primaryRequired(ctx) {
+		return d.Database, d.uri
+	}
+	if _, ok := d.tx(ctx); ok {
+		return d.Database, d.uri
+	}
+	i := d.replicas.pick()
+	if i < 0 {
+		return d.Database, d.uri
+	}
+	return d.replicaDBs[i], d.replicas.pools[i].uri
+}
+
+// pick returns the index of the replica to read from, or -1 if none is
+// healthy.
+func (rs *replicaSet) pick() int {
+	n := len(rs.pools)
+	if rs.policy == ReplicaLeastLatency {
+		best := -1
+		for i, p := range rs.pools {
+			if p.healthy.Load() && (best < 0 || p.latency.Load() < rs.pools[best].latency.Load()) {
+				best = i
+			}
+		}
+		return best
+	}
+	start := int(rs.next.Add(1) - 1)
+	for k := 0; k < n; k++ {
+		i := (start + k) % n
+		if rs.pools[i].healthy.Load() {
+			return i
+		}
+	}
+	return -1
+}
+
+// run pings every replica each interval until closed. Replicas are assumed
+// healthy until the first check.
+func (rs *replicaSet) run() {
+	ticker := time.NewTicker(rs.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rs.stop:
+			return
+		case <-ticker.C:
+			rs.check(context.Background())
+		}
+	}
+}
+
+// check pings every replica concurrently, updating each one's health and
+// latency. A ping gets half the interval, so one hung replica neither delays
+// the others nor overlaps the next round. Latency is a moving average so one
+// slow ping does not reroute reads.
+func (rs *replicaSet) check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, p := range rs.pools {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.check(ctx, rs.interval/2)
+		}()
+	}
+	wg.Wait()
+}
+
+// check pings the replica within timeout.
+func (p *replicaPool) check(ctx context.Context, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	if err := p.db.PingContext(ctx); err != nil {
+		p.healthy.Store(false)
+		return
+	}
+	sample := int64(time.Since(start))
+	if prev := p.latency.Load(); prev > 0 {
+		sample = (prev*4 + sample) / 5
+	}
+	p.latency.Store(sample)
+	p.healthy.Store(true)
+}
+
+// close stops health checks.
+func (rs *replicaSet) close() {
+	rs.once.Do(func() { close(rs.stop) })
+}
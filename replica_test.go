@@ -0,0 +1,152 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zoobzio/astql/postgres"
+	"github.com/zoobzio/grub"
+	"github.com/zoobzio/scio"
+)
+
+func newTestReplicated(t *testing.T, replicas int, opts ...ResourceOption) (*Database[testModel], []*sqlx.DB) {
+	t.Helper()
+	primary, _ := newTestDB(t)
+	dbs := make([]*sqlx.DB, replicas)
+	for i := range dbs {
+		dbs[i], _ = openTestMigrateDB(t, "postgres")
+	}
+	opts = append([]ResourceOption{WithReplicaHealthInterval(time.Hour)}, opts...)
+	d, err := NewReplicatedDatabase[testModel](primary, dbs, "users", postgres.New(), opts...)
+	if err != nil {
+		t.Fatalf("NewReplicatedDatabase: %v", err)
+	}
+	t.Cleanup(d.replicas.close)
+	return d, dbs
+}
+
+func readURI(ctx context.Context, d *Database[testModel]) string {
+	_, uri := d.reader(ctx)
+	return uri
+}
+
+func TestReplicatedDatabaseRoutesReads(t *testing.T) {
+	d, _ := newTestReplicated(t, 2)
+	ctx := context.Background()
+
+	for _, want := range []string{"db://users@replica-1", "db://users@replica-2", "db://users@replica-1"} {
+		if got := readURI(ctx, d); got != want {
+			t.Errorf("expected round-robin read from %s, got %s", want, got)
+		}
+	}
+	if got := readURI(WithPrimaryReads(ctx), d); got != "db://users" {
+		t.Errorf("expected WithPrimaryReads to read from primary, got %s", got)
+	}
+	_ = TransactOn(ctx, d.db, func(ctx context.Context) error {
+		if got := readURI(ctx, d); got != "db://users" {
+			t.Errorf("expected reads inside Transact from primary, got %s", got)
+		}
+		return nil
+	})
+
+	ryw := WithReadYourWrites(ctx)
+	if got := readURI(ryw, d); got == "db://users" {
+		t.Error("expected replica read before any write")
+	}
+	if err := d.Set(ryw, "a", &testModel{ID: "a"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got := readURI(ryw, d); got != "db://users" {
+		t.Errorf("expected primary read after write, got %s", got)
+	}
+
+	if _, err := d.Get(ctx, "a"); err != nil && !errors.Is(err, grub.ErrNotFound) {
+		t.Fatalf("Get: %v", err)
+	}
+	primary, _ := svc().Resource("db://users")
+	if primary.Stats["set"].OK != 1 || primary.Stats["get"].OK != 0 {
+		t.Errorf("expected write counted on primary only, got %+v", primary.Stats)
+	}
+}
+
+func TestReplicatedDatabaseSkipsUnhealthy(t *testing.T) {
+	d, dbs := newTestReplicated(t, 2)
+	ctx := context.Background()
+
+	dbs[0].Close()
+	d.replicas.check(ctx)
+	status := d.Replicas()
+	if status[0].Healthy || !status[1].Healthy || status[1].Latency <= 0 {
+		t.Fatalf("unexpected replica status %+v", status)
+	}
+	for range 3 {
+		if got := readURI(ctx, d); got != "db://users@replica-2" {
+			t.Errorf("expected reads from the healthy replica, got %s", got)
+		}
+	}
+
+	dbs[1].Close()
+	d.replicas.check(ctx)
+	if got := readURI(ctx, d); got != "db://users" {
+		t.Errorf("expected primary fallback with no healthy replica, got %s", got)
+	}
+}
+
+func TestReplicatedDatabaseLeastLatency(t *testing.T) {
+	d, _ := newTestReplicated(t, 3, WithReplicaPolicy(ReplicaLeastLatency))
+	d.replicas.pools[0].latency.Store(int64(30 * time.Millisecond))
+	d.replicas.pools[1].latency.Store(int64(10 * time.Millisecond))
+	d.replicas.pools[2].latency.Store(int64(20 * time.Millisecond))
+	if got := readURI(context.Background(), d); got != "db://users@replica-2" {
+		t.Errorf("expected fastest replica, got %s", got)
+	}
+	d.replicas.pools[1].healthy.Store(false)
+	if got := readURI(context.Background(), d); got != "db://users@replica-3" {
+		t.Errorf("expected fastest healthy replica, got %s", got)
+	}
+}
+
+func TestReplicatedDatabaseRegistersPools(t *testing.T) {
+	d, _ := newTestReplicated(t, 2)
+	var uris []string
+	for _, r := range svc().Resources() {
+		uris = append(uris, r.URI)
+	}
+	if len(uris) != 3 || uris[0] != "db://users" || uris[1] != "db://users@replica-1" || uris[2] != "db://users@replica-2" {
+		t.Errorf("expected primary and replicas in the catalog, got %v", uris)
+	}
+	res := svc().Catalog().Resource("db://users@replica-1")
+	if res == nil || res.Metadata.Tags["role"] != "replica" || res.Metadata.Tags["primary"] != "db://users" {
+		t.Errorf("unexpected replica metadata %+v", res.Metadata)
+	}
+
+	if err := svc().Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	select {
+	case <-d.replicas.stop:
+	default:
+		t.Error("expected health checks stopped on shutdown")
+	}
+}
+
+func TestReplicatedDatabaseRegistersAllOrNothing(t *testing.T) {
+	primary, _ := newTestDB(t)
+	replica, _ := openTestMigrateDB(t, "postgres")
+	if _, err := NewDatabase[testModel](replica, "users@replica-1", postgres.New()); err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+
+	_, err := NewReplicatedDatabase[testModel](primary, []*sqlx.DB{replica}, "users", postgres.New())
+	if !errors.Is(err, scio.ErrResourceExists) {
+		t.Fatalf("expected ErrResourceExists, got %v", err)
+	}
+	if svc().Catalog().Resource("db://users") != nil {
+		t.Error("expected primary left unregistered when a replica cannot register")
+	}
+}
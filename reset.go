@@ -3,6 +3,8 @@
 package sum
 
 import (
+	"context"
+	"reflect"
 	"sync"
 
 	"github.com/zoobzio/slush"
@@ -18,15 +20,40 @@ func Reset() {
 		instance.encryptors = make(map[EncryptAlgo]Encryptor)
 		instance.hashers = make(map[HashAlgo]Hasher)
 		instance.maskers = make(map[MaskType]Masker)
+		instance.senders = make(map[reflect.Type]func(context.Context, any) (any, error))
 		instance.codec = nil
+		instance.closers = nil
+		logger := instance.logger
+		instance.logger = nil
+		observer := instance.observer
+		instance.observer = nil
 		instance.mu.Unlock()
+		if logger != nil {
+			logger.Close()
+		}
+		if observer != nil {
+			observer.Close()
+		}
 	}
+	instanceMu.Lock()
 	instance = nil
+	instanceMu.Unlock()
 	once = sync.Once{}
+	closeListeners()
+
+	records.mu.Lock()
+	records.m = make(map[string]*serviceRecord)
+	records.mu.Unlock()
+	introspection.mu.Lock()
+	introspection.token = nil
+	introspection.mu.Unlock()
 }
 
 // Unregister removes a service by type.
 // Only available in test builds.
 func Unregister[T any]() {
 	slush.Unregister[T]()
+	records.mu.Lock()
+	delete(records.m, fqdn(reflect.TypeFor[T]()))
+	records.mu.Unlock()
 }
@@ -0,0 +1,360 @@
+package sum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zoobzio/capitan"
+)
+
+// SagaStatus is the lifecycle state of a saga instance.
+type SagaStatus string
+
+// Saga states.
+const (
+	SagaRunning     SagaStatus = "running"
+	SagaCompleted   SagaStatus = "completed"
+	SagaCompensated SagaStatus = "compensated"
+	SagaFailed      SagaStatus = "failed"
+)
+
+// SignalSagaError is emitted at error level when an instance cannot be
+// loaded or persisted, so the event that triggered it is not silently lost.
+var SignalSagaError = capitan.NewSignal("sum.saga.error", "Saga state could not be loaded or saved")
+
+// Saga error signal fields.
+var (
+	KeySagaName  = capitan.NewStringKey("saga")
+	KeySagaID    = capitan.NewStringKey("saga_id")
+	KeySagaError = capitan.NewErrorKey("error")
+)
+
+// ErrSagaTimeout is recorded when a saga's deadline passes without a timeout handler.
+var ErrSagaTimeout = errors.New("saga timed out")
+
+// SagaState is the persisted state of one saga instance.
+type SagaState[S any] struct {
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Deadline  time.Time  `json:"deadline,omitzero"`
+	Data      S          `json:"data"`
+	ID        string     `json:"id"`
+	Status    SagaStatus `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	Steps     []string   `json:"steps,omitempty"`
+}
+
+// Complete records a finished step so it is compensated if the saga later fails.
+func (st *SagaState[S]) Complete(step string) {
+	st.Steps = append(st.Steps, step)
+}
+
+// Finish marks the saga completed. No further events or timeouts are handled.
+func (st *SagaState[S]) Finish() {
+	st.Status = SagaCompleted
+	st.Deadline = time.Time{}
+}
+
+// Timeout schedules a wake-up after d, replacing any earlier deadline.
+func (st *SagaState[S]) Timeout(d time.Duration) {
+	st.Deadline = time.Now().Add(d)
+}
+
+// ClearTimeout cancels the pending wake-up.
+func (st *SagaState[S]) ClearTimeout() {
+	st.Deadline = time.Time{}
+}
+
+// Saga coordinates a multi-step workflow across events, keyed by correlation ID.
+// State S is persisted in a Store after every event. A handler that returns an
+// error fails the saga and runs compensations for completed steps in reverse.
+type Saga[S any] struct {
+	store         *Store[SagaState[S]]
+	onTimeout     func(context.Context, *SagaState[S]) error
+	compensations map[string]func(context.Context, *SagaState[S]) error
+	timers        map[string]*time.Timer
+	active        map[string]bool
+	queued        map[string][]func()
+	name          string
+	prefix        string
+	listeners     []*Listener
+	closed        bool
+	mu            sync.Mutex
+}
+
+// SagaOption configures a Saga.
+type SagaOption[S any] func(*Saga[S])
+
+// WithSagaTimeout sets the handler run when a saga's deadline passes.
+// Returning nil keeps the saga running; returning an error fails it.
+// Without a handler, a timeout fails the saga with ErrSagaTimeout.
+func WithSagaTimeout[S any](fn func(context.Context, *SagaState[S]) error) SagaOption[S] {
+	return func(s *Saga[S]) {
+		s.onTimeout = fn
+	}
+}
+
+// NewSaga creates a saga persisting instances of S in store under name.
+// Sagas sharing a store are kept apart by name; ':' and '%' in it are
+// escaped so one saga's keys cannot fall under another's prefix.
+// The saga stops listening and cancels wake-ups when the Service shuts down.
+// Requires sum.New() to have been called first.
+func NewSaga[S any](name string, store *Store[SagaState[S]], opts ...SagaOption[S]) *Saga[S] {
+	s := &Saga[S]{
+		store:         store,
+		name:          name,
+		prefix:        "saga:" + sagaNameEscaper.Replace(name) + ":",
+		compensations: make(map[string]func(context.Context, *SagaState[S]) error),
+		timers:        make(map[string]*time.Timer),
+		active:        make(map[string]bool),
+		queued:        make(map[string][]func()),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	svc().onShutdown(func(context.Context) error {
+		s.Close()
+		return nil
+	})
+	return s
+}
+
+// Compensate registers the action that undoes step.
+func (s *Saga[S]) Compensate(step string, fn func(context.Context, *SagaState[S]) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compensations[step] = fn
+}
+
+// SagaStart handles e for the saga, creating a new instance if none exists
+// for the correlation ID.
+func SagaStart[S, T any](s *Saga[S], e Event[T], correlate func(T) string, handler func(context.Context, *SagaState[S], T) error) {
+	sagaListen(s, e, correlate, handler, true)
+}
+
+// SagaOn handles e for an existing saga instance. Events for unknown or
+// finished instances are ignored.
+func SagaOn[S, T any](s *Saga[S], e Event[T], correlate func(T) string, handler func(context.Context, *SagaState[S], T) error) {
+	sagaListen(s, e, correlate, handler, false)
+}
+
+// sagaListen registers a listener routing e into the saga.
+func sagaListen[S, T any](s *Saga[S], e Event[T], correlate func(T) string, handler func(context.Context, *SagaState[S], T) error, create bool) {
+	l := e.Listen(func(ctx context.Context, data T) {
+		id := correlate(data)
+		if id == "" {
+			return
+		}
+		s.run(id, func() {
+			s.process(ctx, id, create, func(ctx context.Context, st *SagaState[S]) error {
+				return handler(ctx, st, data)
+			})
+		})
+	})
+	if l == nil {
+		return
+	}
+	s.mu.Lock()
+	s.listeners = append(s.listeners, l)
+	s.mu.Unlock()
+}
+
+// run serializes work per correlation ID. Work arriving while an instance is
+// busy, including events emitted by its own handlers, is queued behind it.
+func (s *Saga[S]) run(id string, work func()) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	if s.active[id] {
+		s.queued[id] = append(s.queued[id], work)
+		s.mu.Unlock()
+		return
+	}
+	s.active[id] = true
+	s.mu.Unlock()
+
+	for work != nil {
+		work()
+		s.mu.Lock()
+		if q := s.queued[id]; len(q) > 0 {
+			work = q[0]
+			s.queued[id] = q[1:]
+		} else {
+			delete(s.active, id)
+			delete(s.queued, id)
+			work = nil
+		}
+		s.mu.Unlock()
+	}
+}
+
+// process loads an instance, applies fn, and persists the result. The store
+// is used without ctx's cancellation, so an emitter giving up cannot leave
+// the handler's effects unrecorded.
+func (s *Saga[S]) process(ctx context.Context, id string, create bool, fn func(context.Context, *SagaState[S]) error) {
+	storeCtx := context.WithoutCancel(ctx)
+	st, err := s.load(storeCtx, id)
+	if err != nil {
+		s.fail(ctx, id, fmt.Errorf("load: %w", err))
+		return
+	}
+	if st == nil {
+		if !create {
+			return
+		}
+		now := time.Now()
+		st = &SagaState[S]{ID: id, Status: SagaRunning, CreatedAt: now}
+	}
+	if st.Status != SagaRunning {
+		return
+	}
+
+	if err := fn(ctx, st); err != nil {
+		s.compensate(ctx, st, err)
+	}
+	st.UpdatedAt = time.Now()
+	if err := s.store.Set(storeCtx, s.key(id), st, 0); err != nil {
+		s.fail(ctx, id, fmt.Errorf("save: %w", err))
+		return
+	}
+	s.schedule(st)
+}
+
+// fail reports an instance whose state could not be loaded or saved.
+func (s *Saga[S]) fail(ctx context.Context, id string, err error) {
+	capitan.Error(ctx, SignalSagaError,
+		KeySagaName.Field(s.name),
+		KeySagaID.Field(id),
+		KeySagaError.Field(err),
+	)
+}
+
+// compensate undoes completed steps in reverse order.
+func (s *Saga[S]) compensate(ctx context.Context, st *SagaState[S], cause error) {
+	s.mu.Lock()
+	fns := make([]func(context.Context, *SagaState[S]) error, len(st.Steps))
+	for i, step := range st.Steps {
+		fns[i] = s.compensations[step]
+	}
+	s.mu.Unlock()
+
+	errs := []error{cause}
+	for i := len(st.Steps) - 1; i >= 0; i-- {
+		if fns[i] == nil {
+			continue
+		}
+		if err := fns[i](ctx, st); err != nil {
+			errs = append(errs, fmt.Errorf("compensate %s: %w", st.Steps[i], err))
+		}
+	}
+	st.Deadline = time.Time{}
+	st.Error = errors.Join(errs...).Error()
+	if len(errs) > 1 {
+		st.Status = SagaFailed
+	} else {
+		st.Status = SagaCompensated
+	}
+}
+
+// schedule arms or cancels the wake-up for an instance.
+func (s *Saga[S]) schedule(st *SagaState[S]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.timers[st.ID]; ok {
+		t.Stop()
+		delete(s.timers, st.ID)
+	}
+	if s.closed || st.Status != SagaRunning || st.Deadline.IsZero() {
+		return
+	}
+	id := st.ID
+	s.timers[id] = time.AfterFunc(time.Until(st.Deadline), func() {
+		s.run(id, func() { s.wake(id) })
+	})
+}
+
+// wake handles a passed deadline.
+func (s *Saga[S]) wake(id string) {
+	ctx := context.Background()
+	s.process(ctx, id, false, func(ctx context.Context, st *SagaState[S]) error {
+		if st.Deadline.IsZero() || time.Now().Before(st.Deadline) {
+			return nil
+		}
+		st.Deadline = time.Time{}
+		if s.onTimeout == nil {
+			return ErrSagaTimeout
+		}
+		return s.onTimeout(ctx, st)
+	})
+}
+
+// load returns an instance, or nil if it does not exist.
+func (s *Saga[S]) load(ctx context.Context, id string) (*SagaState[S], error) {
+	ok, err := s.store.Exists(ctx, s.key(id))
+	if err != nil || !ok {
+		return nil, err
+	}
+	return s.store.Get(ctx, s.key(id))
+}
+
+// sagaNameEscaper escapes the separator, and the escape character itself,
+// in saga names used as key prefixes.
+var sagaNameEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+// key namespaces instances by saga name.
+func (s *Saga[S]) key(id string) string {
+	return s.prefix + id
+}
+
+// State returns the persisted state of an instance, or nil if it does not exist.
+func (s *Saga[S]) State(ctx context.Context, id string) (*SagaState[S], error) {
+	return s.load(ctx, id)
+}
+
+// Resume re-arms wake-ups for running instances persisted by a previous process.
+// Deadlines that passed while the process was down fire immediately.
+func (s *Saga[S]) Resume(ctx context.Context) error {
+	keys, err := s.store.List(ctx, s.prefix, 0)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		st, err := s.store.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if st.ID == "" {
+			st.ID = strings.TrimPrefix(key, s.prefix)
+		}
+		s.schedule(st)
+	}
+	return nil
+}
+
+// Close stops listening and cancels pending wake-ups. Persisted state is kept
+// so a later process can Resume. Safe to call multiple times.
+func (s *Saga[S]) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	listeners := s.listeners
+	s.listeners = nil
+	for id, t := range s.timers {
+		t.Stop()
+		delete(s.timers, id)
+	}
+	s.mu.Unlock()
+
+	for _, l := range listeners {
+		l.Close()
+	}
+}
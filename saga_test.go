@@ -0,0 +1,312 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/capitan"
+)
+
+type testOrderPlaced struct {
+	OrderID string
+	Total   int
+}
+
+type testPaymentResult struct {
+	OrderID string
+	OK      bool
+}
+
+type testOrderSaga struct {
+	Total int    `json:"total"`
+	Log   string `json:"log"`
+}
+
+type testSagaEvents struct {
+	placed  Event[testOrderPlaced]
+	payment Event[testPaymentResult]
+}
+
+func newTestSaga(t *testing.T, name string, opts ...SagaOption[testOrderSaga]) (*Saga[testOrderSaga], testSagaEvents) {
+	t.Helper()
+	resetAll(t)
+	New()
+
+	store, err := NewStore[SagaState[testOrderSaga]](NewMemoryStore(), "sagas-"+name)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	s := NewSaga(name, store, opts...)
+	t.Cleanup(s.Close)
+
+	events := testSagaEvents{
+		placed:  NewInfoEvent[testOrderPlaced](capitan.NewSignal("test.saga."+name+".placed", "Order placed")),
+		payment: NewInfoEvent[testPaymentResult](capitan.NewSignal("test.saga."+name+".payment", "Payment result")),
+	}
+	return s, events
+}
+
+func sagaState(t *testing.T, s *Saga[testOrderSaga], id string) *SagaState[testOrderSaga] {
+	t.Helper()
+	st, err := s.State(context.Background(), id)
+	if err != nil {
+		t.Fatalf("State: %v", err)
+	}
+	return st
+}
+
+func TestSagaCompletes(t *testing.T) {
+	s, ev := newTestSaga(t, "complete")
+
+	SagaStart(s, ev.placed, func(o testOrderPlaced) string { return o.OrderID },
+		func(ctx context.Context, st *SagaState[testOrderSaga], o testOrderPlaced) error {
+			st.Data.Total = o.Total
+			st.Data.Log += "reserved;"
+			st.Complete("reserve")
+			// Follow-up emitted from inside a handler is queued behind it.
+			ev.payment.Emit(ctx, testPaymentResult{OrderID: o.OrderID, OK: true})
+			return nil
+		})
+	SagaOn(s, ev.payment, func(p testPaymentResult) string { return p.OrderID },
+		func(_ context.Context, st *SagaState[testOrderSaga], _ testPaymentResult) error {
+			st.Data.Log += "charged;"
+			st.Complete("charge")
+			st.Finish()
+			return nil
+		})
+
+	ev.placed.Emit(context.Background(), testOrderPlaced{OrderID: "o1", Total: 50})
+
+	st := sagaState(t, s, "o1")
+	if st.Status != SagaCompleted {
+		t.Fatalf("expected completed, got %s (%s)", st.Status, st.Error)
+	}
+	if st.Data.Total != 50 || st.Data.Log != "reserved;charged;" {
+		t.Errorf("unexpected data %+v", st.Data)
+	}
+	if strings.Join(st.Steps, ",") != "reserve,charge" {
+		t.Errorf("unexpected steps %v", st.Steps)
+	}
+}
+
+func TestSagaIgnoresUnknownInstance(t *testing.T) {
+	s, ev := newTestSaga(t, "unknown")
+
+	called := false
+	SagaOn(s, ev.payment, func(p testPaymentResult) string { return p.OrderID },
+		func(context.Context, *SagaState[testOrderSaga], testPaymentResult) error {
+			called = true
+			return nil
+		})
+
+	ev.payment.Emit(context.Background(), testPaymentResult{OrderID: "ghost"})
+
+	if called {
+		t.Error("handler should not run for unknown saga")
+	}
+	if st := sagaState(t, s, "ghost"); st != nil {
+		t.Errorf("expected no state, got %+v", st)
+	}
+}
+
+func TestSagaCompensatesInReverse(t *testing.T) {
+	s, ev := newTestSaga(t, "compensate")
+
+	var undone []string
+	s.Compensate("reserve", func(context.Context, *SagaState[testOrderSaga]) error {
+		undone = append(undone, "reserve")
+		return nil
+	})
+	s.Compensate("notify", func(context.Context, *SagaState[testOrderSaga]) error {
+		undone = append(undone, "notify")
+		return nil
+	})
+
+	SagaStart(s, ev.placed, func(o testOrderPlaced) string { return o.OrderID },
+		func(_ context.Context, st *SagaState[testOrderSaga], _ testOrderPlaced) error {
+			st.Complete("reserve")
+			st.Complete("notify")
+			return nil
+		})
+	SagaOn(s, ev.payment, func(p testPaymentResult) string { return p.OrderID },
+		func(context.Context, *SagaState[testOrderSaga], testPaymentResult) error {
+			return errors.New("card declined")
+		})
+
+	ctx := context.Background()
+	ev.placed.Emit(ctx, testOrderPlaced{OrderID: "o2"})
+	ev.payment.Emit(ctx, testPaymentResult{OrderID: "o2"})
+
+	st := sagaState(t, s, "o2")
+	if st.Status != SagaCompensated || st.Error != "card declined" {
+		t.Errorf("expected compensated with cause, got %s %q", st.Status, st.Error)
+	}
+	if strings.Join(undone, ",") != "notify,reserve" {
+		t.Errorf("expected reverse compensation, got %v", undone)
+	}
+
+	// Finished sagas ignore further events.
+	ev.payment.Emit(ctx, testPaymentResult{OrderID: "o2"})
+	if len(undone) != 2 {
+		t.Errorf("compensations ran again: %v", undone)
+	}
+}
+
+func TestSagaTimeout(t *testing.T) {
+	s, ev := newTestSaga(t, "timeout")
+
+	SagaStart(s, ev.placed, func(o testOrderPlaced) string { return o.OrderID },
+		func(_ context.Context, st *SagaState[testOrderSaga], _ testOrderPlaced) error {
+			st.Timeout(10 * time.Millisecond)
+			return nil
+		})
+
+	ev.placed.Emit(context.Background(), testOrderPlaced{OrderID: "o3"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if st := sagaState(t, s, "o3"); st.Status != SagaRunning {
+			if st.Status != SagaCompensated || st.Error != ErrSagaTimeout.Error() {
+				t.Errorf("expected timeout compensation, got %s %q", st.Status, st.Error)
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("saga did not time out")
+}
+
+func TestSagaTimeoutHandler(t *testing.T) {
+	woke := make(chan struct{}, 1)
+	s, ev := newTestSaga(t, "wake", WithSagaTimeout(func(_ context.Context, st *SagaState[testOrderSaga]) error {
+		st.Finish()
+		woke <- struct{}{}
+		return nil
+	}))
+
+	SagaStart(s, ev.placed, func(o testOrderPlaced) string { return o.OrderID },
+		func(_ context.Context, st *SagaState[testOrderSaga], _ testOrderPlaced) error {
+			st.Timeout(10 * time.Millisecond)
+			return nil
+		})
+
+	ev.placed.Emit(context.Background(), testOrderPlaced{OrderID: "o4"})
+
+	select {
+	case <-woke:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout handler not called")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if sagaState(t, s, "o4").Status == SagaCompleted {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("expected saga completed by timeout handler")
+}
+
+func TestSagaReportsSaveFailure(t *testing.T) {
+	resetAll(t)
+	New()
+
+	p := NewMemoryStore()
+	p.Fail("set", errors.New("disk full"))
+	store, err := NewStore[SagaState[testOrderSaga]](p, "sagas-fail")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	s := NewSaga("fail", store)
+	t.Cleanup(s.Close)
+	placed := NewInfoEvent[testOrderPlaced](capitan.NewSignal("test.saga.fail.placed", "Order placed"))
+	SagaStart(s, placed, func(o testOrderPlaced) string { return o.OrderID },
+		func(context.Context, *SagaState[testOrderSaga], testOrderPlaced) error { return nil })
+
+	failures := make(chan *capitan.Event, 1)
+	obs := capitan.Observe(func(_ context.Context, ev *capitan.Event) {
+		if ev.Signal() == SignalSagaError {
+			failures <- ev
+		}
+	}, SignalSagaError)
+	t.Cleanup(obs.Close)
+
+	placed.Emit(context.Background(), testOrderPlaced{OrderID: "o1"})
+
+	select {
+	case ev := <-failures:
+		if ev.Severity() != capitan.SeverityError {
+			t.Errorf("expected error severity, got %v", ev.Severity())
+		}
+		if name, _ := KeySagaName.From(ev); name != "fail" {
+			t.Errorf("expected saga name, got %q", name)
+		}
+		if id, _ := KeySagaID.From(ev); id != "o1" {
+			t.Errorf("expected saga id o1, got %q", id)
+		}
+		if err, _ := KeySagaError.From(ev); err == nil || !strings.Contains(err.Error(), "disk full") {
+			t.Errorf("expected save error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected saga error signal")
+	}
+}
+
+func TestSagaSavesDespiteCanceledEmitter(t *testing.T) {
+	resetAll(t)
+	New()
+
+	p := NewMemoryStore()
+	p.Hook(func(ctx context.Context, _, _ string) error { return ctx.Err() })
+	store, err := NewStore[SagaState[testOrderSaga]](p, "sagas-canceled")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	s := NewSaga("canceled", store)
+	t.Cleanup(s.Close)
+	placed := NewInfoEvent[testOrderPlaced](capitan.NewSignal("test.saga.canceled.placed", "Order placed"))
+	ctx, cancel := context.WithCancel(context.Background())
+	SagaStart(s, placed, func(o testOrderPlaced) string { return o.OrderID },
+		func(_ context.Context, st *SagaState[testOrderSaga], o testOrderPlaced) error {
+			st.Data.Total = o.Total
+			cancel() // the emitter gives up while the handler runs
+			return nil
+		})
+
+	placed.Emit(ctx, testOrderPlaced{OrderID: "o1", Total: 5})
+
+	p.Clear()
+	if st := sagaState(t, s, "o1"); st == nil || st.Data.Total != 5 {
+		t.Errorf("expected state saved despite the canceled emission, got %+v", st)
+	}
+}
+
+func TestSagaNamesDoNotCollide(t *testing.T) {
+	resetAll(t)
+	New()
+
+	store, err := NewStore[SagaState[testOrderSaga]](NewMemoryStore(), "sagas-shared")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	orders := NewSaga("orders", store)
+	t.Cleanup(orders.Close)
+	regional := NewSaga("orders:eu", store)
+	t.Cleanup(regional.Close)
+
+	if orders.key("eu:1") == regional.key("1") {
+		t.Fatalf("expected distinct keys, both were %q", orders.key("1"))
+	}
+	ctx := context.Background()
+	if err := store.Set(ctx, regional.key("1"), &SagaState[testOrderSaga]{ID: "1", Status: SagaRunning}, 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if st, err := orders.State(ctx, "eu:1"); err != nil || st != nil {
+		t.Errorf("expected no instance under the other saga, got %+v, %v", st, err)
+	}
+}
@@ -0,0 +1,273 @@
+package sum
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/sentinel"
+)
+
+// Schema mismatch kinds.
+const (
+	SchemaMissing  = "missing"  // a model field has no column
+	SchemaExtra    = "extra"    // a column has no model field
+	SchemaType     = "type"     // the column type cannot scan into the field
+	SchemaNullable = "nullable" // a nullable column scans into a non-nullable field
+)
+
+// SignalSchemaMismatch is emitted at warn level for each mismatch found by
+// a database created WithSchemaWarnings, and for each non-fatal mismatch
+// found WithSchemaCheck.
+var SignalSchemaMismatch = capitan.NewSignal("sum.schema.mismatch", "Model does not match its database table")
+
+// Schema mismatch signal fields.
+var (
+	KeySchemaTable    = capitan.NewStringKey("table")
+	KeySchemaColumn   = capitan.NewStringKey("column")
+	KeySchemaMismatch = capitan.NewStringKey("mismatch")
+	KeySchemaDetail   = capitan.NewStringKey("detail")
+)
+
+// SchemaMismatch is one difference between a model and its table.
+type SchemaMismatch struct {
+	Column string `json:"column"`
+	Field  string `json:"field,omitempty"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// SchemaError reports every mismatch between a model and its table.
+type SchemaError struct {
+	Table      string           `json:"table"`
+	Model      string           `json:"model"`
+	Mismatches []SchemaMismatch `json:"mismatches"`
+}
+
+func (e *SchemaError) Error() string {
+	parts := make([]string, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		parts[i] = m.Kind + " " + m.Column
+		if m.Detail != "" {
+			parts[i] += " (" + m.Detail + ")"
+		}
+	}
+	return fmt.Sprintf("schema: %s does not match table %s: %s", e.Model, e.Table, strings.Join(parts, ", "))
+}
+
+// schemaMode selects what NewDatabase does with schema mismatches.
+type schemaMode int
+
+const (
+	schemaOff schemaMode = iota
+	schemaStrict
+	schemaWarn
+)
+
+// WithSchemaCheck makes NewDatabase compare the model to the live table and
+// fail with a *SchemaError on missing or type-incompatible columns. Extra
+// columns and nullable mismatches, which cannot break a read or write, are
+// emitted as SignalSchemaMismatch instead. Stores and buckets ignore it.
+func WithSchemaCheck() ResourceOption {
+	return func(r *resourceRecord) {
+		r.schema = schemaStrict
+	}
+}
+
+// WithSchemaWarnings makes NewDatabase compare the model to the live table
+// and emit SignalSchemaMismatch for each mismatch instead of failing.
+func WithSchemaWarnings() ResourceOption {
+	return func(r *resourceRecord) {
+		r.schema = schemaWarn
+	}
+}
+
+// CheckSchema compares the db-tagged fields of M with the columns of table,
+// returning a *SchemaError listing missing, extra and type-incompatible
+// columns. Fields without a db tag, or tagged db:"-", are skipped as grub
+// skips them. Fields implementing sql.Scanner, string fields, which scan
+// from any column, and columns whose type the driver does not report, are
+// not type-checked. The table name may be schema-qualified.
+func CheckSchema[M any](ctx context.Context, db *sqlx.DB, table string) error {
+	rows, err := db.QueryxContext(ctx, "SELECT * FROM "+quoteTable(db.DriverName(), table)+" WHERE 1=0")
+	if err != nil {
+		return fmt.Errorf("schema: inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+	cols, err := rows.ColumnTypes()
+	if err != nil {
+		return fmt.Errorf("schema: inspect %s: %w", table, err)
+	}
+
+	meta := sentinel.Inspect[M]()
+	fields := make(map[string]sentinel.FieldMetadata, len(meta.Fields))
+	for _, f := range meta.Fields {
+		if name, ok := columnName(f); ok {
+			fields[name] = f
+		}
+	}
+
+	var mismatches []SchemaMismatch
+	seen := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		seen[col.Name()] = true
+		f, ok := fields[col.Name()]
+		if !ok {
+			mismatches = append(mismatches, SchemaMismatch{Column: col.Name(), Kind: SchemaExtra})
+			continue
+		}
+		if m, ok := columnMismatch(col, f); ok {
+			mismatches = append(mismatches, m)
+		}
+	}
+	for name, f := range fields {
+		if !seen[name] {
+			mismatches = append(mismatches, SchemaMismatch{Column: name, Field: f.Name, Kind: SchemaMissing})
+		}
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].Column < mismatches[j].Column })
+	return &SchemaError{Table: table, Model: meta.FQDN, Mismatches: mismatches}
+}
+
+// quoteTable quotes each part of a possibly schema-qualified table name
+// with the identifier quoting of driver.
+func quoteTable(driver, table string) string {
+	var quote func(string) string
+	switch driver {
+	case "mysql":
+		quote = quoteWith("`", "`")
+	case "sqlserver", "mssql":
+		quote = quoteWith("[", "]")
+	default:
+		quote = quoteWith(`"`, `"`)
+	}
+	parts := strings.Split(table, ".")
+	for i, p := range parts {
+		parts[i] = quote(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// columnName returns the column a field maps to, if any.
+func columnName(f sentinel.FieldMetadata) (string, bool) {
+	name := strings.Split(f.Tags["db"], ",")[0]
+	if name == "" || name == "-" {
+		return "", false
+	}
+	return name, true
+}
+
+// scannerType detects fields that decode columns themselves.
+var scannerType = reflect.TypeFor[sql.Scanner]()
+
+// columnMismatch reports whether col cannot be scanned into f.
+func columnMismatch(col *sql.ColumnType, f sentinel.FieldMetadata) (SchemaMismatch, bool) {
+	t := f.ReflectType
+	if t == nil || reflect.PointerTo(t).Implements(scannerType) {
+		return SchemaMismatch{}, false
+	}
+	nullableField := false
+	for t.Kind() == reflect.Ptr {
+		nullableField = true
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(scannerType) {
+		return SchemaMismatch{}, false
+	}
+	dbType := strings.ToUpper(col.DatabaseTypeName())
+	if dbType != "" && !columnAccepts(dbType, t) {
+		return SchemaMismatch{
+			Column: col.Name(),
+			Field:  f.Name,
+			Kind:   SchemaType,
+			Detail: dbType + " into " + f.Type,
+		}, true
+	}
+	if nullable, ok := col.Nullable(); ok && nullable && !nullableField {
+		return SchemaMismatch{Column: col.Name(), Field: f.Name, Kind: SchemaNullable, Detail: "into " + f.Type}, true
+	}
+	return SchemaMismatch{}, false
+}
+
+// columnTypes lists database type name fragments each Go kind scans from.
+// Strings are absent because database/sql converts any column to a string.
+var columnTypes = map[reflect.Kind][]string{
+	reflect.Bool:    {"BOOL", "BIT", "TINYINT"},
+	reflect.Int:     {"INT", "SERIAL", "NUMERIC", "DECIMAL"},
+	reflect.Float64: {"FLOAT", "REAL", "DOUBLE", "NUMERIC", "DECIMAL"},
+}
+
+// columnAccepts reports whether a column of dbType scans into t. Kinds with
+// no known mapping, such as structs and maps, are assumed compatible.
+func columnAccepts(dbType string, t reflect.Type) bool {
+	var accepted []string
+	switch {
+	case t == timeType:
+		accepted = []string{"TIME", "DATE"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		accepted = []string{"BLOB", "BYTEA", "BINARY", "JSON", "TEXT", "CHAR"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		accepted = columnTypes[reflect.Int]
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		accepted = columnTypes[reflect.Float64]
+	default:
+		accepted = columnTypes[t.Kind()]
+	}
+	if accepted == nil {
+		return true
+	}
+	for _, frag := range accepted {
+		if strings.Contains(dbType, frag) {
+			return true
+		}
+	}
+	return false
+}
+
+// fatal reports whether a mismatch fails a strict schema check. Extra
+// columns are ignored by grub and nullable columns may be untagged DDL
+// output, so only missing and type-incompatible columns are fatal.
+func (m SchemaMismatch) fatal() bool {
+	return m.Kind == SchemaMissing || m.Kind == SchemaType
+}
+
+// checkSchema applies the record's schema mode after a database is created.
+func (r *resourceRecord) checkSchema(ctx context.Context, table string, check func(context.Context) error) error {
+	if r.schema == schemaOff {
+		return nil
+	}
+	err := check(ctx)
+	if err == nil {
+		return nil
+	}
+	var serr *SchemaError
+	if !errors.As(err, &serr) {
+		return err
+	}
+	var fatal []SchemaMismatch
+	for _, m := range serr.Mismatches {
+		if r.schema == schemaStrict && m.fatal() {
+			fatal = append(fatal, m)
+			continue
+		}
+		capitan.Warn(ctx, SignalSchemaMismatch,
+			KeySchemaTable.Field(table),
+			KeySchemaColumn.Field(m.Column),
+			KeySchemaMismatch.Field(m.Kind),
+			KeySchemaDetail.Field(m.Detail),
+		)
+	}
+	if len(fatal) > 0 {
+		return &SchemaError{Table: serr.Table, Model: serr.Model, Mismatches: fatal}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zoobzio/capitan"
+)
+
+// testColumn describes a column reported by openTestSchemaDB.
+type testColumn struct {
+	name     string
+	dbType   string
+	nullable bool
+}
+
+// openTestSchemaDB opens the shared test driver with every query reporting
+// cols. With no columns, queries fail as if the table did not exist.
+func openTestSchemaDB(t *testing.T, cols ...testColumn) *sqlx.DB {
+	t.Helper()
+	db, state := openTestMigrateDB(t, "postgres")
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if len(cols) == 0 {
+		state.execErr = func(string) error { return errors.New("no such table") }
+		return db
+	}
+	state.rows = func(string) *testMigrateRows {
+		rows := &testMigrateRows{}
+		for _, c := range cols {
+			rows.cols = append(rows.cols, c.name)
+			rows.types = append(rows.types, c.dbType)
+			rows.nullable = append(rows.nullable, c.nullable)
+		}
+		return rows
+	}
+	return db
+}
+
+type testSchemaModel struct {
+	CreatedAt time.Time     `db:"created_at"`
+	Nickname  *string       `db:"nickname"`
+	Balance   sql.NullInt64 `db:"balance"`
+	ID        string        `db:"id"`
+	Email     string        `db:"email"`
+	Age       int           `db:"age"`
+	Internal  string        `db:"-"`
+}
+
+func TestCheckSchemaMatches(t *testing.T) {
+	db := openTestSchemaDB(t,
+		testColumn{"id", "UUID", false},
+		testColumn{"email", "VARCHAR", false},
+		testColumn{"age", "INT4", false},
+		testColumn{"nickname", "TEXT", true},
+		testColumn{"balance", "TEXT", true},
+		testColumn{"created_at", "TIMESTAMPTZ", false},
+	)
+	if err := CheckSchema[testSchemaModel](context.Background(), db, "users"); err != nil {
+		t.Errorf("expected matching schema, got %v", err)
+	}
+}
+
+func TestCheckSchemaReportsMismatches(t *testing.T) {
+	db := openTestSchemaDB(t,
+		testColumn{"id", "UUID", false},
+		testColumn{"email", "TEXT", true},
+		testColumn{"age", "TEXT", false},
+		testColumn{"nickname", "TEXT", true},
+		testColumn{"balance", "NUMERIC", true},
+		testColumn{"legacy", "TEXT", true},
+	)
+	err := CheckSchema[testSchemaModel](context.Background(), db, "users")
+	var serr *SchemaError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected *SchemaError, got %v", err)
+	}
+	want := []SchemaMismatch{
+		{Column: "age", Field: "Age", Kind: SchemaType, Detail: "TEXT into int"},
+		{Column: "created_at", Field: "CreatedAt", Kind: SchemaMissing},
+		{Column: "email", Field: "Email", Kind: SchemaNullable, Detail: "into string"},
+		{Column: "legacy", Kind: SchemaExtra},
+	}
+	if len(serr.Mismatches) != len(want) {
+		t.Fatalf("expected %d mismatches, got %+v", len(want), serr.Mismatches)
+	}
+	for i, m := range want {
+		if serr.Mismatches[i] != m {
+			t.Errorf("mismatch %d: expected %+v, got %+v", i, m, serr.Mismatches[i])
+		}
+	}
+	if serr.Table != "users" || serr.Model == "" {
+		t.Errorf("unexpected error %+v", serr)
+	}
+}
+
+func TestCheckSchemaStringsAcceptAnyColumn(t *testing.T) {
+	db := openTestSchemaDB(t,
+		testColumn{"id", "INT8", false},
+		testColumn{"email", "BOOL", false},
+		testColumn{"age", "INT4", false},
+		testColumn{"nickname", "NUMERIC", true},
+		testColumn{"balance", "TEXT", true},
+		testColumn{"created_at", "TIMESTAMPTZ", false},
+	)
+	if err := CheckSchema[testSchemaModel](context.Background(), db, "users"); err != nil {
+		t.Errorf("expected string fields to accept any column, got %v", err)
+	}
+}
+
+func TestCheckSchemaQuotesTable(t *testing.T) {
+	tests := []struct {
+		driver string
+		table  string
+		want   string
+	}{
+		{"postgres", "users", `SELECT * FROM "users" WHERE 1=0`},
+		{"postgres", "billing.users", `SELECT * FROM "billing"."users" WHERE 1=0`},
+		{"mysql", "users", "SELECT * FROM `users` WHERE 1=0"},
+		{"sqlserver", "users", "SELECT * FROM [users] WHERE 1=0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver+"/"+tt.table, func(t *testing.T) {
+			db, state := openTestMigrateDB(t, tt.driver)
+			var got string
+			state.mu.Lock()
+			state.rows = func(query string) *testMigrateRows {
+				got = query
+				return &testMigrateRows{cols: []string{"id"}, types: []string{"TEXT"}, nullable: []bool{false}}
+			}
+			state.mu.Unlock()
+			_ = CheckSchema[testSchemaModel](context.Background(), db, tt.table)
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckSchemaMissingTable(t *testing.T) {
+	db := openTestSchemaDB(t)
+	err := CheckSchema[testSchemaModel](context.Background(), db, "users")
+	var serr *SchemaError
+	if err == nil || errors.As(err, &serr) {
+		t.Errorf("expected inspection error, got %v", err)
+	}
+}
+
+func TestSchemaModes(t *testing.T) {
+	mismatch := func(context.Context) error {
+		return &SchemaError{Table: "users", Mismatches: []SchemaMismatch{
+			{Column: "age", Field: "Age", Kind: SchemaType, Detail: "TEXT into int"},
+			{Column: "email", Field: "Email", Kind: SchemaNullable, Detail: "into string"},
+			{Column: "legacy", Kind: SchemaExtra},
+		}}
+	}
+
+	var mu sync.Mutex
+	var warned []string
+	obs := capitan.Observe(func(_ context.Context, ev *capitan.Event) {
+		if ev.Signal() == SignalSchemaMismatch {
+			col, _ := KeySchemaColumn.From(ev)
+			mu.Lock()
+			warned = append(warned, col)
+			mu.Unlock()
+		}
+	})
+	t.Cleanup(obs.Close)
+	takeWarned := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		out := warned
+		warned = nil
+		return out
+	}
+
+	if err := newResourceRecord(reflect.TypeFor[testSchemaModel](), "", nil).checkSchema(context.Background(), "users", mismatch); err != nil {
+		t.Errorf("expected no check by default, got %v", err)
+	}
+
+	strict := newResourceRecord(reflect.TypeFor[testSchemaModel](), "", []ResourceOption{WithSchemaCheck()})
+	err := strict.checkSchema(context.Background(), "users", mismatch)
+	var serr *SchemaError
+	if !errors.As(err, &serr) || len(serr.Mismatches) != 1 || serr.Mismatches[0].Column != "age" {
+		t.Errorf("expected WithSchemaCheck to fail on the type mismatch only, got %v", err)
+	}
+	if got := takeWarned(); len(got) != 2 || got[0] != "email" || got[1] != "legacy" {
+		t.Errorf("expected nullable and extra columns as warnings, got %v", got)
+	}
+
+	lenient := func(context.Context) error {
+		return &SchemaError{Table: "users", Mismatches: []SchemaMismatch{{Column: "legacy", Kind: SchemaExtra}}}
+	}
+	if err := strict.checkSchema(context.Background(), "users", lenient); err != nil {
+		t.Errorf("expected extra columns not to fail WithSchemaCheck, got %v", err)
+	}
+	takeWarned()
+
+	warn := newResourceRecord(reflect.TypeFor[testSchemaModel](), "", []ResourceOption{WithSchemaWarnings()})
+	if err := warn.checkSchema(context.Background(), "users", mismatch); err != nil {
+		t.Errorf("expected WithSchemaWarnings not to fail, got %v", err)
+	}
+	if got := takeWarned(); len(got) != 3 {
+		t.Errorf("expected a warning per mismatch, got %v", got)
+	}
+}
@@ -2,56 +2,96 @@ package sum
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/jmoiron/sqlx"
+	"github.com/zoobzio/capitan"
 	"github.com/zoobzio/cereal"
 	"github.com/zoobzio/rocco"
 	"github.com/zoobzio/scio"
 )
 
-// service is the singleton instance.
+// service is the singleton instance, read through current.
 var (
-	instance *Service
-	once     sync.Once
+	instance   *Service
+	once       sync.Once
+	instanceMu sync.RWMutex
 )
 
 // Service wraps a rocco engine and scio catalog, providing application lifecycle.
 type Service struct {
-	encryptors map[cereal.EncryptAlgo]cereal.Encryptor
-	hashers    map[cereal.HashAlgo]cereal.Hasher
-	maskers    map[cereal.MaskType]cereal.Masker
-	engine     *rocco.Engine
-	catalog    *scio.Scio
-	codec      cereal.Codec
-	mu         sync.RWMutex
+	encryptors   map[cereal.EncryptAlgo]cereal.Encryptor
+	hashers      map[cereal.HashAlgo]cereal.Hasher
+	maskers      map[cereal.MaskType]cereal.Masker
+	senders      map[reflect.Type]func(context.Context, any) (any, error)
+	engine       *rocco.Engine
+	catalog      *scio.Scio
+	codec        cereal.Codec
+	mux          *http.ServeMux
+	starters     []func(context.Context) error
+	closers      []*shutdownHook
+	metrics      *Metrics
+	latency      *Histogram
+	exporter     SpanExporter
+	logger       *capitan.Observer
+	observer     *capitan.Observer
+	logging      bool
+	instrumented bool
+	correlated   bool
+	admin        *Admin
+	resources    map[string]*resourceRecord
+	dbs          []*sqlx.DB
+	tenants      TenantResolver
+	tenantCheck  TenantValidator
+	tenantLimit  int
+	mu           sync.RWMutex
 }
 
 // New creates or returns the singleton Service.
 // Subsequent calls return the existing instance.
 func New() *Service {
 	once.Do(func() {
-		instance = &Service{
-			engine:     rocco.NewEngine(),
-			catalog:    scio.New(),
-			encryptors: make(map[cereal.EncryptAlgo]cereal.Encryptor),
-			hashers:    make(map[cereal.HashAlgo]cereal.Hasher),
-			maskers:    make(map[cereal.MaskType]cereal.Masker),
+		s := &Service{
+			engine:      rocco.NewEngine(),
+			catalog:     scio.New(),
+			mux:         http.NewServeMux(),
+			metrics:     NewMetrics(),
+			encryptors:  make(map[cereal.EncryptAlgo]cereal.Encryptor),
+			hashers:     make(map[cereal.HashAlgo]cereal.Hasher),
+			maskers:     make(map[cereal.MaskType]cereal.Masker),
+			senders:     make(map[reflect.Type]func(context.Context, any) (any, error)),
+			tenantLimit: DefaultTenantLimit,
 		}
+		s.engine.WithMiddleware(s.middleware)
+		instanceMu.Lock()
+		instance = s
+		instanceMu.Unlock()
 	})
+	return current()
+}
+
+// current returns the singleton, or nil if not initialized.
+func current() *Service {
+	instanceMu.RLock()
+	defer instanceMu.RUnlock()
 	return instance
 }
 
 // svc returns the singleton, panicking if not initialized.
 func svc() *Service {
-	if instance == nil {
+	s := current()
+	if s == nil {
 		panic("sum: service not initialized, call New() first")
 	}
-	return instance
+	return s
 }
 
 // Handle registers endpoints with the underlying engine.
@@ -59,6 +99,64 @@ func (s *Service) Handle(endpoints ...rocco.Endpoint) {
 	s.engine.WithHandlers(endpoints...)
 }
 
+// Mount registers a raw http.Handler on the engine under the given pattern.
+// Use for endpoints that manage the connection themselves, such as streams.
+// Mounted patterns take precedence over endpoints registered with Handle.
+func (s *Service) Mount(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+// middleware wraps the engine in the service's own request handling, in a
+// fixed order whatever order it was enabled in: correlation, tracing,
+// metrics, then mounted patterns. Each layer passes requests through until
+// its feature is turned on.
+func (s *Service) middleware(next http.Handler) http.Handler {
+	return s.correlation(s.trace(s.measure(s.route(next))))
+}
+
+// route dispatches requests matching a mounted pattern, deferring to the engine otherwise.
+func (s *Service) route(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h, pattern := s.mux.Handler(r); pattern != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// onStart registers a function to run before the engine starts serving.
+func (s *Service) onStart(fn func(context.Context) error) {
+	s.mu.Lock()
+	s.starters = append(s.starters, fn)
+	s.mu.Unlock()
+}
+
+// shutdownHook is a function registered with onShutdown. Hooks are compared
+// by pointer so one can be removed again.
+type shutdownHook struct {
+	fn func(context.Context) error
+}
+
+// onShutdown registers a function to run before the engine stops. The
+// returned func removes it, for resources closed before shutdown.
+func (s *Service) onShutdown(fn func(context.Context) error) func() {
+	h := &shutdownHook{fn: fn}
+	s.mu.Lock()
+	s.closers = append(s.closers, h)
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, c := range s.closers {
+			if c == h {
+				s.closers = append(s.closers[:i:i], s.closers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
 // Tag registers an OpenAPI tag with a description.
 func (s *Service) Tag(name, description string) {
 	s.engine.WithTag(name, description)
@@ -107,28 +205,97 @@ func (s *Service) WithCodec(codec cereal.Codec) *Service {
 	return s
 }
 
+// codecOrDefault returns the configured codec, falling back to JSON.
+func (s *Service) codecOrDefault() Codec {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.codec != nil {
+		return s.codec
+	}
+	return jsonCodec{}
+}
+
 // Start begins serving. This method blocks until shutdown.
+// Startup hooks such as migrations run first, in registration order, and any
+// failure aborts Start before a port is bound. The admin server, if
+// configured, is bound next so a taken admin port fails Start immediately,
+// and is stopped again if the engine then fails to start.
 func (s *Service) Start(host string, port int) error {
-	return s.engine.Start(host, port)
+	return s.start(context.Background(), host, port)
+}
+
+// start runs the startup hooks with ctx, so Run can cancel a long migration
+// on a shutdown signal, then starts the admin server and engine.
+func (s *Service) start(ctx context.Context, host string, port int) error {
+	s.mu.RLock()
+	starters := s.starters
+	s.mu.RUnlock()
+	for _, fn := range starters {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+	}
+	a := s.Admin()
+	if a != nil {
+		if err := a.start(); err != nil {
+			return err
+		}
+	}
+	if err := s.engine.Start(host, port); err != nil {
+		if a != nil {
+			_ = a.shutdown(context.Background())
+		}
+		return err
+	}
+	return nil
 }
 
 // Shutdown gracefully stops the service.
+// Events already queued to listeners are drained first. Registered shutdown
+// hooks then run in reverse order, so buffers are flushed and long-lived
+// connections released before every remaining listener is closed and the
+// engine waits on in-flight requests. The admin server stops last, so health
+// and metrics stay reachable while the engine drains.
 func (s *Service) Shutdown(ctx context.Context) error {
 	if s.engine == nil {
 		return fmt.Errorf("service not started")
 	}
-	return s.engine.Shutdown(ctx)
+	s.mu.Lock()
+	closers := s.closers
+	s.closers = nil
+	s.mu.Unlock()
+
+	var errs []error
+	if err := drainListeners(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].fn(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	closeListeners()
+	if err := s.engine.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if a := s.Admin(); a != nil {
+		if err := a.shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // Run starts the service and blocks until a shutdown signal is received.
 // Handles SIGINT and SIGTERM, then performs graceful shutdown with a 30 second timeout.
+// Startup hooks see a context that is cancelled by the same signals.
 func (s *Service) Run(host string, port int) error {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- s.engine.Start(host, port)
+		errCh <- s.start(ctx, host, port)
 	}()
 
 	select {
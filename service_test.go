@@ -4,6 +4,9 @@ package sum
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
 	"time"
@@ -149,3 +152,79 @@ func TestServiceStartStop(t *testing.T) {
 		t.Error("timeout waiting for server to stop")
 	}
 }
+
+func TestServiceMountRoutesBeforeEngine(t *testing.T) {
+	instance = nil
+	once = sync.Once{}
+	t.Cleanup(func() {
+		instance = nil
+		once = sync.Once{}
+	})
+
+	svc := New()
+	svc.Mount("/raw", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	h := svc.route(http.NotFoundHandler())
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/raw", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("expected mounted handler, got status %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/other", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected fallthrough to engine, got status %d", rec.Code)
+	}
+}
+
+func TestServiceShutdownRunsHooksInReverse(t *testing.T) {
+	instance = nil
+	once = sync.Once{}
+	t.Cleanup(func() {
+		instance = nil
+		once = sync.Once{}
+	})
+
+	svc := New()
+
+	var order []int
+	svc.onShutdown(func(context.Context) error { order = append(order, 1); return nil })
+	svc.onShutdown(func(context.Context) error { order = append(order, 2); return nil })
+
+	_ = svc.Shutdown(context.Background())
+
+	if len(order) != 2 || order[0] != 2 || order[1] != 1 {
+		t.Errorf("expected hooks in reverse order, got %v", order)
+	}
+}
+
+func TestServiceStartersSeeRunContext(t *testing.T) {
+	instance = nil
+	once = sync.Once{}
+	t.Cleanup(func() {
+		instance = nil
+		once = sync.Once{}
+	})
+
+	svc := New()
+
+	stop := errors.New("stop")
+	var seen context.Context
+	svc.onStart(func(ctx context.Context) error {
+		seen = ctx
+		return stop
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := svc.start(ctx, "127.0.0.1", 0); !errors.Is(err, stop) {
+		t.Fatalf("expected starter error, got %v", err)
+	}
+	if seen == nil || seen.Err() == nil {
+		t.Error("expected starter to receive the cancelled run context")
+	}
+}
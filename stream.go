@@ -0,0 +1,290 @@
+package sum
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stream fans selected events out to HTTP clients as Server-Sent Events.
+// Mount it on the service with Service.Mount and attach events with StreamEvent.
+type Stream struct {
+	codec     Codec
+	resolve   func(*http.Request) (Token, bool)
+	clients   map[*streamClient]struct{}
+	done      chan struct{}
+	buffer    []streamMessage
+	listeners []*Listener
+	heartbeat time.Duration
+	replay    int
+	seq       uint64
+	closeOnce sync.Once
+	mu        sync.Mutex
+}
+
+// StreamOption configures a Stream.
+type StreamOption func(*Stream)
+
+// WithHeartbeat sets the interval between keep-alive comments.
+// A zero or negative interval disables heartbeats.
+func WithHeartbeat(d time.Duration) StreamOption {
+	return func(s *Stream) {
+		s.heartbeat = d
+	}
+}
+
+// WithReplay sets how many recent messages are retained for Last-Event-ID resume.
+func WithReplay(n int) StreamOption {
+	return func(s *Stream) {
+		s.replay = n
+	}
+}
+
+// WithStreamToken sets how a connection's token is resolved from its request.
+// The token is placed in the connection context and checked against event guards.
+func WithStreamToken(fn func(*http.Request) (Token, bool)) StreamOption {
+	return func(s *Stream) {
+		s.resolve = fn
+	}
+}
+
+// streamMessage is a single encoded event awaiting delivery.
+type streamMessage struct {
+	guard Guard
+	event string
+	data  []byte
+	id    uint64
+}
+
+// streamClient is a single connected subscriber.
+type streamClient struct {
+	ctx    context.Context
+	ch     chan streamMessage
+	kicked chan struct{}
+	once   sync.Once
+}
+
+// kick disconnects a client that cannot keep up.
+// The client resumes from the replay buffer when it reconnects.
+func (c *streamClient) kick() {
+	c.once.Do(func() { close(c.kicked) })
+}
+
+// streamClientBuffer is the per-connection queue depth before a client is dropped.
+const streamClientBuffer = 64
+
+// NewStream creates a Stream encoding with the Service codec.
+// The stream is closed automatically when the Service shuts down.
+func NewStream(opts ...StreamOption) *Stream {
+	s := svc()
+	st := &Stream{
+		codec:     s.codecOrDefault(),
+		clients:   make(map[*streamClient]struct{}),
+		done:      make(chan struct{}),
+		heartbeat: 15 * time.Second,
+		replay:    128,
+	}
+	for _, opt := range opts {
+		opt(st)
+	}
+	s.onShutdown(func(context.Context) error {
+		st.Close()
+		return nil
+	})
+	return st
+}
+
+// StreamEvent forwards emissions of e to connected clients.
+// If tokens are provided, only connections bearing one of them receive the event.
+// Payloads with a Boundary are sent as its Send output.
+func StreamEvent[T any](st *Stream, e Event[T], tokens ...Token) {
+	var guard Guard
+	if len(tokens) > 0 {
+		guard = Require(tokens...)
+	}
+	name := e.Signal.Name()
+	l := e.Listen(func(ctx context.Context, data T) {
+		encoded, err := marshalOutbound(ctx, st.codec, data)
+		if err != nil {
+			return
+		}
+		st.publish(name, encoded, guard)
+	})
+	if l == nil {
+		return
+	}
+	st.mu.Lock()
+	st.listeners = append(st.listeners, l)
+	st.mu.Unlock()
+}
+
+// publish assigns an id to a message, buffers it, and delivers it to clients.
+func (st *Stream) publish(event string, data []byte, guard Guard) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.seq++
+	msg := streamMessage{id: st.seq, event: event, data: data, guard: guard}
+	if st.replay > 0 {
+		if len(st.buffer) == st.replay {
+			st.buffer = append(st.buffer[:0], st.buffer[1:]...)
+		}
+		st.buffer = append(st.buffer, msg)
+	}
+
+	for c := range st.clients {
+		select {
+		case c.ch <- msg:
+		default:
+			c.kick()
+		}
+	}
+}
+
+// ServeHTTP holds the connection open and writes events until the client
+// disconnects or the stream is closed.
+func (st *Stream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+	if st.resolve != nil {
+		if t, ok := st.resolve(r); ok {
+			ctx = WithToken(ctx, t)
+		}
+	}
+
+	c := &streamClient{
+		ctx:    ctx,
+		ch:     make(chan streamMessage, streamClientBuffer),
+		kicked: make(chan struct{}),
+	}
+	backlog, ok := st.subscribe(c, lastEventID(r))
+	if !ok {
+		http.Error(w, "stream closed", http.StatusServiceUnavailable)
+		return
+	}
+	defer st.unsubscribe(c)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, msg := range backlog {
+		c.write(w, msg)
+	}
+	flusher.Flush()
+
+	var tick <-chan time.Time
+	if st.heartbeat > 0 {
+		ticker := time.NewTicker(st.heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case msg := <-c.ch:
+			if c.write(w, msg) {
+				flusher.Flush()
+			}
+		case <-tick:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-c.kicked:
+			return
+		case <-ctx.Done():
+			return
+		case <-st.done:
+			return
+		}
+	}
+}
+
+// subscribe registers a client and returns buffered messages newer than lastID.
+// Registration and the backlog snapshot share a lock so no message is missed.
+func (st *Stream) subscribe(c *streamClient, lastID uint64) ([]streamMessage, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	select {
+	case <-st.done:
+		return nil, false
+	default:
+	}
+
+	st.clients[c] = struct{}{}
+	if lastID == 0 {
+		return nil, true
+	}
+	var backlog []streamMessage
+	for _, msg := range st.buffer {
+		if msg.id > lastID {
+			backlog = append(backlog, msg)
+		}
+	}
+	return backlog, true
+}
+
+// unsubscribe removes a client.
+func (st *Stream) unsubscribe(c *streamClient) {
+	st.mu.Lock()
+	delete(st.clients, c)
+	st.mu.Unlock()
+}
+
+// write renders a message in event-stream format if the client may receive it.
+// Returns false if the message was withheld or the write failed.
+func (c *streamClient) write(w http.ResponseWriter, msg streamMessage) bool {
+	if msg.guard != nil && msg.guard(c.ctx) != nil {
+		return false
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "id: %d\nevent: %s\n", msg.id, msg.event)
+	for _, line := range strings.Split(string(msg.data), "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	_, err := w.Write([]byte(b.String()))
+	return err == nil
+}
+
+// lastEventID reads the resume position from the request, if any.
+func lastEventID(r *http.Request) uint64 {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("lastEventId")
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// Close unregisters all event listeners and disconnects every client.
+// Safe to call multiple times.
+func (st *Stream) Close() {
+	st.closeOnce.Do(func() {
+		st.mu.Lock()
+		close(st.done)
+		listeners := st.listeners
+		st.listeners = nil
+		st.mu.Unlock()
+
+		for _, l := range listeners {
+			l.Close()
+		}
+	})
+}
@@ -0,0 +1,229 @@
+//go:build testing
+
+package sum
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/cereal"
+)
+
+type testStreamData struct {
+	Message string `json:"message"`
+}
+
+// readStreamEvent reads lines until a blank line terminates an event.
+func readStreamEvent(t *testing.T, r *bufio.Reader) []string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			if len(lines) == 0 {
+				continue
+			}
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func openStream(t *testing.T, url string, header http.Header) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, bufio.NewReader(resp.Body)
+}
+
+func TestStreamDeliversEvents(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New()
+
+	event := NewInfoEvent[testStreamData](capitan.NewSignal("test.stream.deliver", "Stream delivery"))
+	st := NewStream(WithHeartbeat(0))
+	StreamEvent(st, event)
+	t.Cleanup(st.Close)
+
+	srv := httptest.NewServer(st)
+	t.Cleanup(srv.Close)
+
+	resp, r := openStream(t, srv.URL, nil)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %q", ct)
+	}
+
+	event.Emit(context.Background(), testStreamData{Message: "hello"})
+
+	lines := readStreamEvent(t, r)
+	want := []string{"id: 1", "event: test.stream.deliver", `data: {"message":"hello"}`}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("expected %v, got %v", want, lines)
+	}
+}
+
+func TestStreamAppliesBoundary(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New().WithEncryptor(cereal.EncryptAES, stubEncryptor{}).WithHasher(cereal.HashSHA256, stubHasher{})
+
+	k := Start()
+	if _, err := NewBoundary[testLogAccount](k); err != nil {
+		t.Fatalf("NewBoundary: %v", err)
+	}
+	Freeze(k)
+
+	event := NewInfoEvent[testLogAccount](capitan.NewSignal("test.stream.masked", "Stream masked"))
+	st := NewStream(WithHeartbeat(0))
+	StreamEvent(st, event)
+	t.Cleanup(st.Close)
+
+	srv := httptest.NewServer(st)
+	t.Cleanup(srv.Close)
+
+	_, r := openStream(t, srv.URL, nil)
+	event.Emit(context.Background(), testLogAccount{ID: "a1", Email: "alice@example.com", Token: "tok"})
+
+	data := readStreamEvent(t, r)[2]
+	if strings.Contains(data, "alice@example.com") || !strings.Contains(data, `"token":"***"`) {
+		t.Errorf("expected boundary-masked payload, got %s", data)
+	}
+}
+
+func TestStreamResumeFromLastEventID(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New()
+
+	event := NewInfoEvent[testStreamData](capitan.NewSignal("test.stream.resume", "Stream resume"))
+	st := NewStream(WithHeartbeat(0), WithReplay(2))
+	StreamEvent(st, event)
+	t.Cleanup(st.Close)
+
+	ctx := context.Background()
+	event.Emit(ctx, testStreamData{Message: "one"})
+	event.Emit(ctx, testStreamData{Message: "two"})
+	event.Emit(ctx, testStreamData{Message: "three"})
+
+	srv := httptest.NewServer(st)
+	t.Cleanup(srv.Close)
+
+	_, r := openStream(t, srv.URL, http.Header{"Last-Event-ID": {"1"}})
+
+	first := readStreamEvent(t, r)
+	if first[0] != "id: 2" {
+		t.Errorf("expected replay to start at id 2, got %v", first)
+	}
+	second := readStreamEvent(t, r)
+	if second[0] != "id: 3" {
+		t.Errorf("expected id 3, got %v", second)
+	}
+}
+
+func TestStreamTokenGuard(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New()
+
+	admin := NewToken("admin")
+	secret := NewInfoEvent[testStreamData](capitan.NewSignal("test.stream.secret", "Guarded stream event"))
+	public := NewInfoEvent[testStreamData](capitan.NewSignal("test.stream.public", "Public stream event"))
+
+	st := NewStream(WithHeartbeat(0), WithStreamToken(func(r *http.Request) (Token, bool) {
+		if r.Header.Get("X-Role") == "admin" {
+			return admin, true
+		}
+		return Token{}, false
+	}))
+	StreamEvent(st, secret, admin)
+	StreamEvent(st, public)
+	t.Cleanup(st.Close)
+
+	srv := httptest.NewServer(st)
+	t.Cleanup(srv.Close)
+
+	_, anon := openStream(t, srv.URL, nil)
+	_, priv := openStream(t, srv.URL, http.Header{"X-Role": {"admin"}})
+
+	ctx := context.Background()
+	secret.Emit(ctx, testStreamData{Message: "secret"})
+	public.Emit(ctx, testStreamData{Message: "public"})
+
+	if lines := readStreamEvent(t, anon); lines[1] != "event: test.stream.public" {
+		t.Errorf("anonymous client should only see public event, got %v", lines)
+	}
+	if lines := readStreamEvent(t, priv); lines[1] != "event: test.stream.secret" {
+		t.Errorf("admin client should see secret event first, got %v", lines)
+	}
+}
+
+func TestStreamHeartbeat(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New()
+
+	st := NewStream(WithHeartbeat(10 * time.Millisecond))
+	t.Cleanup(st.Close)
+
+	srv := httptest.NewServer(st)
+	t.Cleanup(srv.Close)
+
+	_, r := openStream(t, srv.URL, nil)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if line != ": heartbeat\n" {
+		t.Errorf("expected heartbeat comment, got %q", line)
+	}
+}
+
+func TestStreamClosedOnShutdown(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	svc := New()
+
+	st := NewStream(WithHeartbeat(0))
+	srv := httptest.NewServer(st)
+	t.Cleanup(srv.Close)
+
+	_, r := openStream(t, srv.URL, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// The engine was never started; only the shutdown hooks matter here.
+	_ = svc.Shutdown(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = r.ReadString('\n')
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream connection not closed on shutdown")
+	}
+}
@@ -0,0 +1,598 @@
+package sum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zoobzio/astql"
+	"github.com/zoobzio/edamame"
+	"github.com/zoobzio/grub"
+)
+
+var (
+	// ErrNoTenant is returned by tenant-aware data operations when the
+	// context carries no tenant.
+	ErrNoTenant = errors.New("no tenant in context")
+	// ErrInvalidTenant is returned for tenant IDs that are not safe to use in
+	// table, key and catalog names.
+	ErrInvalidTenant = errors.New("invalid tenant ID")
+	// ErrUnknownTenant is returned for tenants the validator set with
+	// WithTenantValidator does not know.
+	ErrUnknownTenant = errors.New("unknown tenant")
+	// ErrTenantLimit is returned when a tenant-aware wrapper already holds
+	// resources for as many tenants as WithTenantLimit allows.
+	ErrTenantLimit = errors.New("tenant limit reached")
+)
+
+// DefaultTenantLimit is how many tenants each tenant-aware wrapper creates
+// resources for unless WithTenantLimit changes it.
+const DefaultTenantLimit = 1000
+
+// tenantID restricts tenant IDs to characters safe in SQL identifiers,
+// storage keys and catalog URIs.
+var tenantID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,63}$`)
+
+// TenantResolver extracts the tenant from a request context, e.g. from the
+// authenticated principal.
+type TenantResolver func(context.Context) (string, bool)
+
+// TenantValidator reports whether a tenant exists, e.g. by looking it up in
+// an accounts table.
+type TenantValidator func(ctx context.Context, tenant string) (bool, error)
+
+type tenantKey struct{}
+
+// WithTenant returns a context carrying a tenant ID for the default resolver.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant set with WithTenant.
+func TenantFrom(ctx context.Context) (string, bool) {
+	t, ok := ctx.Value(tenantKey{}).(string)
+	return t, ok && t != ""
+}
+
+// WithTenantResolver replaces how tenant-aware data wrappers find the
+// tenant. The default reads WithTenant.
+func (s *Service) WithTenantResolver(r TenantResolver) *Service {
+	s.mu.Lock()
+	s.tenants = r
+	s.mu.Unlock()
+	return s
+}
+
+// WithTenantValidator makes tenant-aware data wrappers check a tenant with v
+// before creating and registering its resources. Unknown tenants fail with
+// ErrUnknownTenant. Without a validator any well-formed tenant ID is
+// accepted, up to the limit set with WithTenantLimit.
+func (s *Service) WithTenantValidator(v TenantValidator) *Service {
+	s.mu.Lock()
+	s.tenantCheck = v
+	s.mu.Unlock()
+	return s
+}
+
+// WithTenantLimit caps how many tenants each tenant-aware data wrapper
+// creates resources for; further tenants fail with ErrTenantLimit. The
+// default is DefaultTenantLimit, and n <= 0 removes the cap.
+func (s *Service) WithTenantLimit(n int) *Service {
+	s.mu.Lock()
+	s.tenantLimit = n
+	s.mu.Unlock()
+	return s
+}
+
+// tenantOf resolves and validates the tenant for ctx. With ident, the
+// tenant must also be a lowercase SQL identifier.
+func tenantOf(ctx context.Context, ident bool) (string, error) {
+	s := svc()
+	s.mu.RLock()
+	resolve := s.tenants
+	s.mu.RUnlock()
+	if resolve == nil {
+		resolve = TenantFrom
+	}
+	tenant, ok := resolve(ctx)
+	if !ok {
+		return "", ErrNoTenant
+	}
+	if !tenantID.MatchString(tenant) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTenant, tenant)
+	}
+	if ident && !tenantIdent.MatchString(tenant) {
+		return "", fmt.Errorf("%w: %q is not a lowercase SQL identifier", ErrInvalidTenant, tenant)
+	}
+	return tenant, nil
+}
+
+// tenantSet lazily creates one resource per tenant.
+type tenantSet[T any] struct {
+	create func(tenant string) (T, error)
+	m      map[string]T
+	ident  bool // tenants name SQL tables or schemas
+	mu     sync.Mutex
+}
+
+// get returns the tenant's resource, creating it on first use after the
+// validator and limit allow it. Failed creations are retried on the next
+// call.
+func (ts *tenantSet[T]) get(ctx context.Context) (T, error) {
+	var zero T
+	tenant, err := tenantOf(ctx, ts.ident)
+	if err != nil {
+		return zero, err
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if v, ok := ts.m[tenant]; ok {
+		return v, nil
+	}
+	s := svc()
+	s.mu.RLock()
+	check, limit := s.tenantCheck, s.tenantLimit
+	s.mu.RUnlock()
+	if limit > 0 && len(ts.m) >= limit {
+		return zero, fmt.Errorf("%w: %d tenants", ErrTenantLimit, limit)
+	}
+	if check != nil {
+		ok, err := check(ctx, tenant)
+		if err != nil {
+			return zero, fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+		if !ok {
+			return zero, fmt.Errorf("%w: %q", ErrUnknownTenant, tenant)
+		}
+	}
+	v, err := ts.create(tenant)
+	if err != nil {
+		return zero, fmt.Errorf("tenant %s: %w", tenant, err)
+	}
+	if ts.m == nil {
+		ts.m = make(map[string]T)
+	}
+	ts.m[tenant] = v
+	return v, nil
+}
+
+// tenantIdent restricts tenants used in table and schema names to
+// identifiers that need no quoting in DDL or raw SQL.
+var tenantIdent = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// TenantTable places a tenant's table, optionally in a schema.
+type TenantTable func(tenant string) (schema, table string)
+
+// TenantSchema places each tenant's table in a schema named for the tenant,
+// e.g. "acme"."users". Queries name the schema explicitly, so one connection
+// pool serves every tenant. The schemas must already exist.
+func TenantSchema(table string) TenantTable {
+	return func(tenant string) (string, string) { return tenant, table }
+}
+
+// TenantTablePrefix gives each tenant its own table, e.g. "acme_users".
+func TenantTablePrefix(table string) TenantTable {
+	return func(tenant string) (string, string) { return "", tenant + "_" + table }
+}
+
+// TenantDatabase routes Database[M] operations to the table of the tenant in
+// the context. Each tenant's table is created as a Database on first use and
+// registered in the catalog under its own name, e.g. "db://acme.users".
+// Tenant IDs must be lowercase SQL identifiers; others fail with
+// ErrInvalidTenant before anything is created.
+type TenantDatabase[M any] struct {
+	tenants tenantSet[*Database[M]]
+}
+
+// NewTenantDatabase creates a TenantDatabase[M] over db. Options apply to
+// every tenant's Database.
+// Requires sum.New() to have been called first.
+func NewTenantDatabase[M any](db *sqlx.DB, table TenantTable, renderer astql.Renderer, opts ...ResourceOption) *TenantDatabase[M] {
+	return &TenantDatabase[M]{tenants: tenantSet[*Database[M]]{
+		ident: true,
+		create: func(tenant string) (*Database[M], error) {
+			schema, name := table(tenant)
+			if schema == "" {
+				return NewDatabase[M](db, name, renderer, opts...)
+			}
+			sr, err := newSchemaRenderer(renderer, schema, name)
+			if err != nil {
+				return nil, err
+			}
+			return newDatabase[M](db, name, schema+"."+name, sr, opts)
+		},
+	}}
+}
+
+// For returns the Database of the tenant in ctx.
+func (t *TenantDatabase[M]) For(ctx context.Context) (*Database[M], error) {
+	return t.tenants.get(ctx)
+}
+
+// Get retrieves a record by primary key.
+func (t *TenantDatabase[M]) Get(ctx context.Context, key string) (*M, error) {
+	d, err := t.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return d.Get(ctx, key)
+}
+
+// Set inserts or updates a record by primary key.
+func (t *TenantDatabase[M]) Set(ctx context.Context, key string, v *M) error {
+	d, err := t.For(ctx)
+	if err != nil {
+		return err
+	}
+	return d.Set(ctx, key, v)
+}
+
+// Delete removes a record by primary key.
+func (t *TenantDatabase[M]) Delete(ctx context.Context, key string) error {
+	d, err := t.For(ctx)
+	if err != nil {
+		return err
+	}
+	return d.Delete(ctx, key)
+}
+
+// Exists reports whether a record exists.
+func (t *TenantDatabase[M]) Exists(ctx context.Context, key string) (bool, error) {
+	d, err := t.For(ctx)
+	if err != nil {
+		return false, err
+	}
+	return d.Exists(ctx, key)
+}
+
+// ExecQuery runs a query statement.
+func (t *TenantDatabase[M]) ExecQuery(ctx context.Context, stmt edamame.QueryStatement, params map[string]any) ([]*M, error) {
+	d, err := t.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return d.ExecQuery(ctx, stmt, params)
+}
+
+// ExecSelect runs a select statement.
+func (t *TenantDatabase[M]) ExecSelect(ctx context.Context, stmt edamame.SelectStatement, params map[string]any) (*M, error) {
+	d, err := t.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return d.ExecSelect(ctx, stmt, params)
+}
+
+// schemaRenderer renders statements on a table as schema-qualified, since
+// astql quotes a dotted table name as a single identifier.
+type schemaRenderer struct {
+	astql.Renderer
+	schema    string
+	table     string
+	qualified string
+	quote     func(string) string
+}
+
+// schemaTarget stands in for the table while rendering, so only the
+// statement's target is qualified.
+const schemaTarget = "sum_schema_target"
+
+// newSchemaRenderer qualifies table with schema in statements rendered by r.
+func newSchemaRenderer(r astql.Renderer, schema, table string) (*schemaRenderer, error) {
+	d, err := dialectOf(r)
+	if err != nil {
+		return nil, err
+	}
+	return &schemaRenderer{Renderer: r, schema: schema, table: table, qualified: d.quote(schema) + "." + d.quote(table), quote: d.quote}, nil
+}
+
+// Render renders ast with its target table qualified.
+func (r *schemaRenderer) Render(ast *astql.AST) (*astql.QueryResult, error) {
+	res, err := r.Renderer.Render(r.retarget(ast))
+	if err != nil {
+		return nil, err
+	}
+	return r.qualify(res), nil
+}
+
+// RenderCompound renders query with every operand on the table qualified.
+func (r *schemaRenderer) RenderCompound(query *astql.CompoundQuery) (*astql.QueryResult, error) {
+	q := *query
+	q.Base = r.retarget(q.Base)
+	q.Operands = make([]astql.SetOperand, len(query.Operands))
+	for i, op := range query.Operands {
+		op.AST = r.retarget(op.AST)
+		q.Operands[i] = op
+	}
+	res, err := r.Renderer.RenderCompound(&q)
+	if err != nil {
+		return nil, err
+	}
+	return r.qualify(res), nil
+}
+
+// retarget copies ast with the table replaced by schemaTarget.
+func (r *schemaRenderer) retarget(ast *astql.AST) *astql.AST {
+	if ast == nil || ast.Target.Name != r.table {
+		return ast
+	}
+	a := *ast
+	a.Target.Name = schemaTarget
+	return &a
+}
+
+// qualify swaps the rendered schemaTarget for the qualified table.
+func (r *schemaRenderer) qualify(res *astql.QueryResult) *astql.QueryResult {
+	out := *res
+	out.SQL = strings.ReplaceAll(res.SQL, r.quote(schemaTarget), r.qualified)
+	return &out
+}
+
+// TenantStores supplies a tenant's store provider.
+type TenantStores func(tenant string) (grub.StoreProvider, error)
+
+// SharedStore isolates tenants within one provider by prefixing every key
+// with "<tenant>/".
+func SharedStore(p grub.StoreProvider) TenantStores {
+	return func(tenant string) (grub.StoreProvider, error) {
+		return prefixedStore{StoreProvider: p, prefix: tenant + "/"}, nil
+	}
+}
+
+// TenantStore routes Store[M] operations to the store of the tenant in the
+// context. Each tenant's store is registered in the catalog on first use as
+// "kv://<name>@<tenant>".
+type TenantStore[M any] struct {
+	tenants tenantSet[*Store[M]]
+}
+
+// NewTenantStore creates a TenantStore[M].
+// Requires sum.New() to have been called first.
+func NewTenantStore[M any](providers TenantStores, name string, opts ...ResourceOption) *TenantStore[M] {
+	return &TenantStore[M]{tenants: tenantSet[*Store[M]]{
+		create: func(tenant string) (*Store[M], error) {
+			p, err := providers(tenant)
+			if err != nil {
+				return nil, err
+			}
+			return NewStore[M](p, name+"@"+tenant, opts...)
+		},
+	}}
+}
+
+// For returns the Store of the tenant in ctx.
+func (t *TenantStore[M]) For(ctx context.Context) (*Store[M], error) {
+	return t.tenants.get(ctx)
+}
+
+// Get retrieves a value by key.
+func (t *TenantStore[M]) Get(ctx context.Context, key string) (*M, error) {
+	s, err := t.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, key)
+}
+
+// Set stores a value with an optional TTL.
+func (t *TenantStore[M]) Set(ctx context.Context, key string, v *M, ttl time.Duration) error {
+	s, err := t.For(ctx)
+	if err != nil {
+		return err
+	}
+	return s.Set(ctx, key, v, ttl)
+}
+
+// Delete removes a key.
+func (t *TenantStore[M]) Delete(ctx context.Context, key string) error {
+	s, err := t.For(ctx)
+	if err != nil {
+		return err
+	}
+	return s.Delete(ctx, key)
+}
+
+// Exists reports whether a key exists.
+func (t *TenantStore[M]) Exists(ctx context.Context, key string) (bool, error) {
+	s, err := t.For(ctx)
+	if err != nil {
+		return false, err
+	}
+	return s.Exists(ctx, key)
+}
+
+// List returns the tenant's keys with the given prefix.
+func (t *TenantStore[M]) List(ctx context.Context, prefix string, limit int) ([]string, error) {
+	s, err := t.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.List(ctx, prefix, limit)
+}
+
+// TenantBuckets supplies a tenant's bucket provider. Return a provider for a
+// dedicated bucket to isolate tenants by bucket, or use SharedBucket.
+type TenantBuckets func(tenant string) (grub.BucketProvider, error)
+
+// SharedBucket isolates tenants within one bucket by prefixing every key
+// with "<tenant>/".
+func SharedBucket(p grub.BucketProvider) TenantBuckets {
+	return func(tenant string) (grub.BucketProvider, error) {
+		return prefixedBucket{BucketProvider: p, prefix: tenant + "/"}, nil
+	}
+}
+
+// TenantBucket routes Bucket[M] operations to the bucket of the tenant in
+// the context. Each tenant's bucket is registered in the catalog on first
+// use as "bcs://<name>@<tenant>".
+type TenantBucket[M any] struct {
+	tenants tenantSet[*Bucket[M]]
+}
+
+// NewTenantBucket creates a TenantBucket[M].
+// Requires sum.New() to have been called first.
+func NewTenantBucket[M any](providers TenantBuckets, name string, opts ...ResourceOption) *TenantBucket[M] {
+	return &TenantBucket[M]{tenants: tenantSet[*Bucket[M]]{
+		create: func(tenant string) (*Bucket[M], error) {
+			p, err := providers(tenant)
+			if err != nil {
+				return nil, err
+			}
+			return NewBucket[M](p, name+"@"+tenant, opts...)
+		},
+	}}
+}
+
+// For returns the Bucket of the tenant in ctx.
+func (t *TenantBucket[M]) For(ctx context.Context) (*Bucket[M], error) {
+	return t.tenants.get(ctx)
+}
+
+// Get retrieves an object by key.
+func (t *TenantBucket[M]) Get(ctx context.Context, key string) (*grub.Object[M], error) {
+	b, err := t.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return b.Get(ctx, key)
+}
+
+// Put stores an object.
+func (t *TenantBucket[M]) Put(ctx context.Context, obj *grub.Object[M]) error {
+	b, err := t.For(ctx)
+	if err != nil {
+		return err
+	}
+	return b.Put(ctx, obj)
+}
+
+// Delete removes an object.
+func (t *TenantBucket[M]) Delete(ctx context.Context, key string) error {
+	b, err := t.For(ctx)
+	if err != nil {
+		return err
+	}
+	return b.Delete(ctx, key)
+}
+
+// Exists reports whether an object exists.
+func (t *TenantBucket[M]) Exists(ctx context.Context, key string) (bool, error) {
+	b, err := t.For(ctx)
+	if err != nil {
+		return false, err
+	}
+	return b.Exists(ctx, key)
+}
+
+// List returns the tenant's objects with the given prefix.
+func (t *TenantBucket[M]) List(ctx context.Context, prefix string, limit int) ([]grub.ObjectInfo, error) {
+	b, err := t.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return b.List(ctx, prefix, limit)
+}
+
+// prefixedStore scopes a store provider to keys under prefix.
+type prefixedStore struct {
+	grub.StoreProvider
+	prefix string
+}
+
+func (p prefixedStore) backing() any { return p.StoreProvider }
+
+func (p prefixedStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return p.StoreProvider.Get(ctx, p.prefix+key)
+}
+
+func (p prefixedStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return p.StoreProvider.Set(ctx, p.prefix+key, value, ttl)
+}
+
+func (p prefixedStore) Delete(ctx context.Context, key string) error {
+	return p.StoreProvider.Delete(ctx, p.prefix+key)
+}
+
+func (p prefixedStore) Exists(ctx context.Context, key string) (bool, error) {
+	return p.StoreProvider.Exists(ctx, p.prefix+key)
+}
+
+func (p prefixedStore) List(ctx context.Context, prefix string, limit int) ([]string, error) {
+	keys, err := p.StoreProvider.List(ctx, p.prefix+prefix, limit)
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, p.prefix)
+	}
+	return keys, err
+}
+
+func (p prefixedStore) GetBatch(ctx context.Context, keys []string) (map[string][]byte, error) {
+	scoped := make([]string, len(keys))
+	for i, k := range keys {
+		scoped[i] = p.prefix + k
+	}
+	items, err := p.StoreProvider.GetBatch(ctx, scoped)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]byte, len(items))
+	for k, v := range items {
+		out[strings.TrimPrefix(k, p.prefix)] = v
+	}
+	return out, nil
+}
+
+func (p prefixedStore) SetBatch(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	scoped := make(map[string][]byte, len(items))
+	for k, v := range items {
+		scoped[p.prefix+k] = v
+	}
+	return p.StoreProvider.SetBatch(ctx, scoped, ttl)
+}
+
+// prefixedBucket scopes a bucket provider to keys under prefix.
+type prefixedBucket struct {
+	grub.BucketProvider
+	prefix string
+}
+
+func (p prefixedBucket) backing() any { return p.BucketProvider }
+
+func (p prefixedBucket) Get(ctx context.Context, key string) ([]byte, *grub.ObjectInfo, error) {
+	data, info, err := p.BucketProvider.Get(ctx, p.prefix+key)
+	if info != nil {
+		scoped := *info
+		scoped.Key = strings.TrimPrefix(scoped.Key, p.prefix)
+		info = &scoped
+	}
+	return data, info, err
+}
+
+func (p prefixedBucket) Put(ctx context.Context, key string, data []byte, info *grub.ObjectInfo) error {
+	if info != nil {
+		scoped := *info
+		scoped.Key = p.prefix + key
+		info = &scoped
+	}
+	return p.BucketProvider.Put(ctx, p.prefix+key, data, info)
+}
+
+func (p prefixedBucket) Delete(ctx context.Context, key string) error {
+	return p.BucketProvider.Delete(ctx, p.prefix+key)
+}
+
+func (p prefixedBucket) Exists(ctx context.Context, key string) (bool, error) {
+	return p.BucketProvider.Exists(ctx, p.prefix+key)
+}
+
+func (p prefixedBucket) List(ctx context.Context, prefix string, limit int) ([]grub.ObjectInfo, error) {
+	infos, err := p.BucketProvider.List(ctx, p.prefix+prefix, limit)
+	for i := range infos {
+		infos[i].Key = strings.TrimPrefix(infos[i].Key, p.prefix)
+	}
+	return infos, err
+}
@@ -0,0 +1,217 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/astql/postgres"
+	"github.com/zoobzio/grub"
+)
+
+func TestTenantStoreRequiresTenant(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New()
+	store := NewTenantStore[testModel](SharedStore(NewMemoryStore()), "sessions")
+
+	if err := store.Set(context.Background(), "k", &testModel{ID: "k"}, 0); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("expected ErrNoTenant, got %v", err)
+	}
+	if _, err := store.Get(WithTenant(context.Background(), "a/b"), "k"); !errors.Is(err, ErrInvalidTenant) {
+		t.Errorf("expected ErrInvalidTenant, got %v", err)
+	}
+	if len(svc().Resources()) != 0 {
+		t.Errorf("expected no resources registered, got %v", svc().Resources())
+	}
+}
+
+func TestTenantStoreIsolatesTenants(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New()
+	p := NewMemoryStore()
+	store := NewTenantStore[testModel](SharedStore(p), "sessions")
+	a := WithTenant(context.Background(), "a")
+	b := WithTenant(context.Background(), "b")
+
+	if err := store.Set(a, "k", &testModel{ID: "k", Name: "alpha"}, time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Set(b, "k", &testModel{ID: "k", Name: "beta"}, time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	got, err := store.Get(a, "k")
+	if err != nil || got.Name != "alpha" {
+		t.Errorf("expected tenant a's value, got %+v, %v", got, err)
+	}
+	if ok, _ := p.Exists(context.Background(), "a/k"); !ok {
+		keys, _ := p.List(context.Background(), "", 0)
+		t.Errorf("expected key stored under tenant prefix, got %v", keys)
+	}
+	keys, err := store.List(b, "", 0)
+	if err != nil || strings.Join(keys, ",") != "k" {
+		t.Errorf("expected unprefixed keys for tenant b, got %v, %v", keys, err)
+	}
+	if err := store.Delete(a, "k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ok, _ := store.Exists(b, "k"); !ok {
+		t.Error("expected tenant b's key unaffected by tenant a's delete")
+	}
+
+	res, ok := svc().Resource("kv://sessions@a")
+	if !ok {
+		t.Fatal("expected tenant store registered")
+	}
+	if res.Provider != "*sum.MemoryStore" {
+		t.Errorf("expected backing provider reported, got %s", res.Provider)
+	}
+	if len(svc().Resources()) != 2 {
+		t.Errorf("expected one resource per tenant, got %v", svc().Resources())
+	}
+}
+
+func TestTenantResolver(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New().WithTenantResolver(func(context.Context) (string, bool) { return "fixed", true })
+	store := NewTenantStore[testModel](SharedStore(NewMemoryStore()), "sessions")
+
+	if err := store.Set(context.Background(), "k", &testModel{ID: "k"}, 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, ok := svc().Resource("kv://sessions@fixed"); !ok {
+		t.Error("expected resolver's tenant used")
+	}
+}
+
+func TestTenantValidatorAndLimit(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	lookupErr := errors.New("accounts unavailable")
+	New().WithTenantLimit(2).WithTenantValidator(func(_ context.Context, tenant string) (bool, error) {
+		if tenant == "flaky" {
+			return false, lookupErr
+		}
+		return tenant != "ghost", nil
+	})
+	store := NewTenantStore[testModel](SharedStore(NewMemoryStore()), "sessions")
+	set := func(tenant string) error {
+		return store.Set(WithTenant(context.Background(), tenant), "k", &testModel{ID: "k"}, 0)
+	}
+
+	if err := set("ghost"); !errors.Is(err, ErrUnknownTenant) {
+		t.Errorf("expected ErrUnknownTenant, got %v", err)
+	}
+	if err := set("flaky"); !errors.Is(err, lookupErr) {
+		t.Errorf("expected the validator's error, got %v", err)
+	}
+	for _, tenant := range []string{"a", "b"} {
+		if err := set(tenant); err != nil {
+			t.Fatalf("Set %s: %v", tenant, err)
+		}
+	}
+	if err := set("c"); !errors.Is(err, ErrTenantLimit) {
+		t.Errorf("expected ErrTenantLimit, got %v", err)
+	}
+	if err := set("a"); err != nil {
+		t.Errorf("expected known tenants unaffected by the limit, got %v", err)
+	}
+	if got := len(svc().Resources()); got != 2 {
+		t.Errorf("expected 2 tenant stores registered, got %d", got)
+	}
+}
+
+func TestTenantBucket(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New()
+	p := NewMemoryBucket()
+	bucket := NewTenantBucket[testModel](SharedBucket(p), "docs")
+	a := WithTenant(context.Background(), "a")
+
+	if err := bucket.Put(context.Background(), &grub.Object[testModel]{Key: "x"}); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("expected ErrNoTenant, got %v", err)
+	}
+	if err := bucket.Put(a, &grub.Object[testModel]{Key: "x", Data: testModel{ID: "x"}}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if ok, _ := p.Exists(context.Background(), "a/x"); !ok {
+		objs, _ := p.List(context.Background(), "", 0)
+		t.Errorf("expected object stored under tenant prefix, got %v", objs)
+	}
+	obj, err := bucket.Get(a, "x")
+	if err != nil || obj.Key != "x" || obj.Data.ID != "x" {
+		t.Errorf("expected unprefixed object, got %+v, %v", obj, err)
+	}
+	infos, err := bucket.List(a, "", 0)
+	if err != nil || len(infos) != 1 || infos[0].Key != "x" {
+		t.Errorf("expected unprefixed listing, got %+v, %v", infos, err)
+	}
+	if ok, _ := bucket.Exists(WithTenant(context.Background(), "b"), "x"); ok {
+		t.Error("expected tenant b not to see tenant a's object")
+	}
+	if _, ok := svc().Resource("bcs://docs@a"); !ok {
+		t.Error("expected tenant bucket registered")
+	}
+}
+
+func TestTenantDatabase(t *testing.T) {
+	db, _ := newTestDB(t)
+	users := NewTenantDatabase[testModel](db, TenantTablePrefix("users"), postgres.New())
+
+	if _, err := users.Get(context.Background(), "1"); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("expected ErrNoTenant, got %v", err)
+	}
+	if len(svc().Resources()) != 0 {
+		t.Fatalf("expected tables registered lazily, got %v", svc().Resources())
+	}
+
+	ctx := WithTenant(context.Background(), "acme")
+	first, err := users.For(ctx)
+	if err != nil {
+		t.Fatalf("For: %v", err)
+	}
+	second, _ := users.For(ctx)
+	if first != second {
+		t.Error("expected one Database per tenant")
+	}
+	if _, ok := svc().Resource("db://acme_users"); !ok {
+		t.Error("expected tenant table registered")
+	}
+	if _, err := users.For(WithTenant(context.Background(), "acme-corp")); !errors.Is(err, ErrInvalidTenant) {
+		t.Errorf("expected tenant rejected as a table name, got %v", err)
+	}
+}
+
+func TestTenantSchema(t *testing.T) {
+	db, _ := newTestDB(t)
+	users := NewTenantDatabase[testModel](db, TenantSchema("users"), postgres.New())
+
+	d, err := users.For(WithTenant(context.Background(), "acme"))
+	if err != nil {
+		t.Fatalf("For: %v", err)
+	}
+	if _, ok := svc().Resource("db://acme.users"); !ok {
+		t.Error("expected tenant table registered under its qualified name")
+	}
+	sql, err := d.Executor().RenderDelete(testDeleteUser)
+	if err != nil {
+		t.Fatalf("RenderDelete: %v", err)
+	}
+	if !strings.Contains(sql, `"acme"."users"`) || strings.Contains(sql, `"acme.users"`) {
+		t.Errorf("expected schema and table quoted separately, got %s", sql)
+	}
+	stmts, err := d.DDL()
+	if err != nil {
+		t.Fatalf("DDL: %v", err)
+	}
+	if !strings.HasPrefix(stmts[0], `CREATE TABLE "acme"."users"`) {
+		t.Errorf("expected schema-qualified DDL, got %s", stmts[0])
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"database/sql"
 	"os"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -216,3 +217,55 @@ func TestBucketIntegration(t *testing.T) {
 		t.Error("expected non-nil bucket in embedded store")
 	}
 }
+
+func TestMigrationsIntegration(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set - skipping migrations integration test")
+	}
+
+	ctx := sumtest.TestContext(t)
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "postgres")
+	t.Cleanup(func() {
+		sqlxDB.Exec(`DROP TABLE IF EXISTS migrated_models`)
+		sqlxDB.Exec(`DROP TABLE IF EXISTS test_schema_migrations`)
+	})
+
+	fsys := fstest.MapFS{
+		"migrations/0001_create_models.up.sql":   {Data: []byte(`CREATE TABLE migrated_models (id TEXT PRIMARY KEY)`)},
+		"migrations/0001_create_models.down.sql": {Data: []byte(`DROP TABLE migrated_models`)},
+		"migrations/0002_add_name.up.sql":        {Data: []byte(`ALTER TABLE migrated_models ADD COLUMN name TEXT`)},
+		"migrations/0002_add_name.down.sql":      {Data: []byte(`ALTER TABLE migrated_models DROP COLUMN name`)},
+	}
+	m, err := sum.NewMigrator(sqlxDB, fsys, "migrations", sum.WithMigrationsTable("test_schema_migrations"))
+	if err != nil {
+		t.Fatalf("NewMigrator failed: %v", err)
+	}
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if _, err := sqlxDB.ExecContext(ctx, `INSERT INTO migrated_models (id, name) VALUES ('a', 'A')`); err != nil {
+		t.Errorf("expected migrated schema, got %v", err)
+	}
+
+	if err := m.Down(ctx, 2); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, st := range status {
+		if st.Applied {
+			t.Errorf("expected %d_%s rolled back", st.Version, st.Name)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+//go:build testing
+
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zoobzio/astql/postgres"
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/sum"
+)
+
+type testCounter struct {
+	Total int `json:"total"`
+}
+
+type testIncremented struct {
+	By int `json:"by"`
+}
+
+func TestEventStoreIntegration(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set - skipping event store integration test")
+	}
+
+	sum.Reset()
+	t.Cleanup(sum.Reset)
+	sum.New()
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "postgres")
+
+	_, err = sqlxDB.Exec(`
+		CREATE TABLE IF NOT EXISTS test_events (
+			id BIGSERIAL PRIMARY KEY,
+			key TEXT NOT NULL UNIQUE,
+			stream TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			signal TEXT NOT NULL,
+			event_version INTEGER NOT NULL,
+			payload BYTEA NOT NULL,
+			recorded_at TIMESTAMPTZ NOT NULL
+		)
+	`)
+	if err != nil {
+		t.Fatalf("failed to create events table: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlxDB.Exec(`DROP TABLE IF EXISTS test_events`)
+	})
+
+	store, err := sum.NewEventStore(sqlxDB, "test_events", postgres.New())
+	if err != nil {
+		t.Fatalf("NewEventStore failed: %v", err)
+	}
+
+	incremented := sum.NewInfoEvent[testIncremented](capitan.NewSignal("test.integration.incremented", "Counter incremented"))
+	agg := sum.NewAggregate[testCounter](store)
+	sum.Fold(agg, incremented, func(c *testCounter, e testIncremented) { c.Total += e.By })
+
+	projected := 0
+	l := incremented.Listen(func(_ context.Context, e testIncremented) { projected += e.By })
+	defer l.Close()
+
+	ctx := context.Background()
+	c1, _ := sum.NewChange(incremented, testIncremented{By: 2})
+	c2, _ := sum.NewChange(incremented, testIncremented{By: 3})
+
+	version, err := store.Append(ctx, "counter-1", 0, c1, c2)
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if version != 2 {
+		t.Errorf("expected version 2, got %d", version)
+	}
+
+	if _, err := store.Append(ctx, "counter-1", 0, c1); !errors.Is(err, sum.ErrConcurrency) {
+		t.Errorf("expected ErrConcurrency, got %v", err)
+	}
+
+	state, loaded, err := agg.Load(ctx, "counter-1")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if state.Total != 5 || loaded != 2 {
+		t.Errorf("expected total 5 at version 2, got %d at %d", state.Total, loaded)
+	}
+	if projected != 5 {
+		t.Errorf("expected appended events re-emitted, projection saw %d", projected)
+	}
+}
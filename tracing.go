@@ -0,0 +1,296 @@
+package sum
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderTraceparent is the W3C trace context header.
+const HeaderTraceparent = "traceparent"
+
+// SpanData is a finished span as handed to exporters.
+type SpanData struct {
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Name       string            `json:"name"`
+	TraceID    string            `json:"trace_id"`
+	SpanID     string            `json:"span_id"`
+	ParentID   string            `json:"parent_id,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// Duration returns how long the span ran.
+func (d SpanData) Duration() time.Duration {
+	return d.End.Sub(d.Start)
+}
+
+// SpanExporter receives spans as they finish.
+// Exporters that implement io.Closer are closed when the Service shuts down.
+type SpanExporter interface {
+	ExportSpan(SpanData) error
+}
+
+// Span is an in-flight unit of work. A nil *Span is valid and does nothing,
+// so instrumented code need not check whether tracing is enabled.
+type Span struct {
+	exporter SpanExporter
+	data     SpanData
+	mu       sync.Mutex
+	ended    bool
+}
+
+// spanKey carries the current span on a context.
+type spanKey struct{}
+
+// remoteKey carries a parent span context extracted from a traceparent header.
+type remoteKey struct{}
+
+// remoteParent identifies a span started in another process.
+type remoteParent struct {
+	traceID string
+	spanID  string
+}
+
+// WithTracing exports spans for HTTP requests, registry lookups, event
+// emissions and listener callbacks, and data operations to exp. Incoming
+// traceparent headers continue the caller's trace. Use NewJSONExporter or
+// NewFileExporter for a ready-made exporter.
+func (s *Service) WithTracing(exp SpanExporter) *Service {
+	s.mu.Lock()
+	first := s.exporter == nil
+	s.exporter = exp
+	s.mu.Unlock()
+	if first {
+		s.onShutdown(func(context.Context) error {
+			s.mu.Lock()
+			exp := s.exporter
+			s.exporter = nil
+			s.mu.Unlock()
+			if c, ok := exp.(io.Closer); ok {
+				return c.Close()
+			}
+			return nil
+		})
+	}
+	return s
+}
+
+// activeExporter returns the configured exporter, or nil if tracing is off.
+func activeExporter() SpanExporter {
+	s := current()
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.exporter
+}
+
+// StartSpan starts a span as a child of the span in ctx, or of a remote
+// parent extracted from a traceparent header. Returns a nil span and ctx
+// unchanged when tracing is not enabled.
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	exp := activeExporter()
+	if exp == nil {
+		return ctx, nil
+	}
+	span := &Span{exporter: exp, data: SpanData{Name: name, SpanID: newID(8), Start: time.Now()}}
+	switch {
+	case SpanFromContext(ctx) != nil:
+		parent := SpanFromContext(ctx)
+		span.data.TraceID = parent.data.TraceID
+		span.data.ParentID = parent.data.SpanID
+	case ctx.Value(remoteKey{}) != nil:
+		remote := ctx.Value(remoteKey{}).(remoteParent)
+		span.data.TraceID = remote.traceID
+		span.data.ParentID = remote.spanID
+	default:
+		span.data.TraceID = newID(16)
+	}
+	if id := CorrelationID(ctx); id != "" {
+		span.data.Attributes = map[string]string{"correlation_id": id}
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFromContext returns the current span, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SetAttr records a key/value attribute on the span.
+func (s *Span) SetAttr(key, value string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]string)
+	}
+	s.data.Attributes[key] = value
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed. A nil error is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Error = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and hands it to the exporter.
+// Calling End more than once has no effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	_ = s.exporter.ExportSpan(data)
+}
+
+// TraceID returns the span's trace identifier.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.data.TraceID
+}
+
+// Traceparent formats the span in ctx as a W3C traceparent header value.
+// Returns "" if ctx has no span.
+func Traceparent(ctx context.Context) string {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	return "00-" + span.data.TraceID + "-" + span.data.SpanID + "-01"
+}
+
+// InjectTrace sets the traceparent header for an outgoing request.
+func InjectTrace(ctx context.Context, h http.Header) {
+	if tp := Traceparent(ctx); tp != "" {
+		h.Set(HeaderTraceparent, tp)
+	}
+}
+
+// ExtractTrace returns ctx carrying the remote parent described by a
+// traceparent header, so the next span joins the caller's trace.
+// Malformed headers are ignored.
+func ExtractTrace(ctx context.Context, h http.Header) context.Context {
+	traceID, spanID, ok := parseTraceparent(h.Get(HeaderTraceparent))
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, remoteParent{traceID: traceID, spanID: spanID})
+}
+
+// parseTraceparent validates a version 00 traceparent value.
+func parseTraceparent(v string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", "", false
+	}
+	for _, p := range parts[1:] {
+		if _, err := hex.DecodeString(p); err != nil {
+			return "", "", false
+		}
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return "", "", false
+	}
+	return strings.ToLower(parts[1]), strings.ToLower(parts[2]), true
+}
+
+// newID returns n random bytes hex encoded.
+func newID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// trace starts a span for each request, continuing any incoming trace.
+func (s *Service) trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := StartSpan(ExtractTrace(r.Context(), r.Header), "http "+r.Method)
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer span.End()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(rec, r)
+		route := s.routeOf(r)
+		span.mu.Lock()
+		span.data.Name = "http " + r.Method + " " + route
+		span.mu.Unlock()
+		span.SetAttr("http.method", r.Method)
+		span.SetAttr("http.route", route)
+		span.SetAttr("http.status", strconv.Itoa(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("%d %s", rec.status, http.StatusText(rec.status)))
+		}
+	})
+}
+
+// JSONExporter writes each span as a line of JSON.
+type JSONExporter struct {
+	w   io.Writer
+	enc *json.Encoder
+	mu  sync.Mutex
+}
+
+// NewJSONExporter writes spans to w, e.g. os.Stdout.
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{w: w, enc: json.NewEncoder(w)}
+}
+
+// NewFileExporter appends spans to the file at path, creating it if needed.
+// The file is closed when the Service shuts down.
+func NewFileExporter(path string) (*JSONExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONExporter(f), nil
+}
+
+// ExportSpan writes one span.
+func (e *JSONExporter) ExportSpan(span SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(span)
+}
+
+// Close closes the underlying writer if it is a file, leaving stdout and
+// stderr open.
+func (e *JSONExporter) Close() error {
+	f, ok := e.w.(*os.File)
+	if !ok || f == os.Stdout || f == os.Stderr {
+		return nil
+	}
+	return f.Close()
+}
@@ -0,0 +1,236 @@
+//go:build testing
+
+package sum
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/rocco"
+)
+
+// testSpanExporter records finished spans.
+type testSpanExporter struct {
+	spans []SpanData
+	mu    sync.Mutex
+}
+
+func (e *testSpanExporter) ExportSpan(s SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, s)
+	return nil
+}
+
+// find returns the first span whose name starts with prefix.
+func (e *testSpanExporter) find(t *testing.T, prefix string) SpanData {
+	t.Helper()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range e.spans {
+		if strings.HasPrefix(s.Name, prefix) {
+			return s
+		}
+	}
+	t.Fatalf("no span starting with %q in %v", prefix, e.spans)
+	return SpanData{}
+}
+
+func TestTracingSpansShareRequestTrace(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	exp := &testSpanExporter{}
+	svc := New().WithTracing(exp)
+
+	k := Start()
+	Register[testGreeterIface](k, testGreeter{})
+	Freeze(k)
+
+	store, err := NewStore[testInvoice](NewMemoryStore(), "traced")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	event := NewInfoEvent[testEventInt](capitan.NewSignal("test.tracing.event", "Traced event"))
+	event.Listen(func(ctx context.Context, _ testEventInt) {
+		_, span := StartSpan(ctx, "work")
+		span.End()
+	})
+
+	svc.Mount("POST /traced", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if _, err := Use[testGreeterIface](ctx); err != nil {
+			t.Errorf("Use: %v", err)
+		}
+		event.Emit(ctx, testEventInt{Value: 1})
+		_ = store.Set(ctx, "a", &testInvoice{ID: "a"}, 0)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "/traced", nil)
+	req.Header.Set(HeaderTraceparent, "00-"+traceID+"-00f067aa0ba902b7-01")
+	svc.trace(svc.route(http.NotFoundHandler())).ServeHTTP(httptest.NewRecorder(), req)
+
+	root := exp.find(t, "http POST")
+	if root.Name != "http POST POST /traced" || root.ParentID != "00f067aa0ba902b7" || root.Attributes["http.status"] != "202" {
+		t.Errorf("unexpected request span %+v", root)
+	}
+	use := exp.find(t, "use ")
+	emit := exp.find(t, "emit test.tracing.event")
+	listen := exp.find(t, "listen test.tracing.event")
+	work := exp.find(t, "work")
+	set := exp.find(t, "set kv://traced")
+
+	for _, s := range []SpanData{root, use, emit, listen, work, set} {
+		if s.TraceID != traceID {
+			t.Errorf("span %q has trace %q, want %q", s.Name, s.TraceID, traceID)
+		}
+	}
+	for child, parent := range map[*SpanData]SpanData{&use: root, &emit: root, &listen: emit, &work: listen, &set: root} {
+		if child.ParentID != parent.SpanID {
+			t.Errorf("span %q parent %q, want %q (%s)", child.Name, child.ParentID, parent.SpanID, parent.Name)
+		}
+	}
+}
+
+func TestTracingLabelsEngineRoutes(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	exp := &testSpanExporter{}
+	svc := New().WithTracing(exp)
+	svc.Handle(rocco.GET("/invoices/{id}", func(*rocco.Request[rocco.NoBody]) (testInvoice, error) {
+		return testInvoice{ID: "inv_1"}, nil
+	}))
+
+	if code := serveTestEngine(t, svc, "/invoices/42"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	// The span ends after the response is written, so wait for the export.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		exp.mu.Lock()
+		n := len(exp.spans)
+		exp.mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	span := exp.find(t, "http GET")
+	if route := span.Attributes["http.route"]; route == unmatchedRoute || !strings.Contains(route, "/invoices/{id}") {
+		t.Errorf("expected the engine route on the request span, got %+v", span)
+	}
+}
+
+func TestTracingRecordsErrors(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	exp := &testSpanExporter{}
+	New().WithTracing(exp)
+	Start()
+
+	if _, err := Use[testMissing](context.Background()); err == nil {
+		t.Fatal("expected error for unregistered service")
+	}
+	if span := exp.find(t, "use "); !strings.Contains(span.Error, "not registered") {
+		t.Errorf("expected error recorded on span, got %+v", span)
+	}
+}
+
+func TestTracingDisabled(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New()
+
+	ctx := context.Background()
+	got, span := StartSpan(ctx, "noop")
+	if span != nil || got != ctx {
+		t.Error("expected no span when tracing is disabled")
+	}
+	span.SetAttr("k", "v")
+	span.RecordError(errors.New("boom"))
+	span.End()
+}
+
+func TestTraceparentPropagation(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New().WithTracing(&testSpanExporter{})
+
+	ctx, span := StartSpan(context.Background(), "outgoing")
+	h := http.Header{}
+	InjectTrace(ctx, h)
+	traceID, spanID, ok := parseTraceparent(h.Get(HeaderTraceparent))
+	if !ok || traceID != span.TraceID() || spanID != span.data.SpanID {
+		t.Errorf("unexpected traceparent %q", h.Get(HeaderTraceparent))
+	}
+
+	for _, bad := range []string{
+		"",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+	} {
+		if _, _, ok := parseTraceparent(bad); ok {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestFileExporterWritesJSONLines(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exp, err := NewFileExporter(path)
+	if err != nil {
+		t.Fatalf("NewFileExporter: %v", err)
+	}
+	svc := New().WithTracing(exp)
+
+	ctx, parent := StartSpan(context.Background(), "parent")
+	_, child := StartSpan(ctx, "child")
+	child.SetAttr("k", "v")
+	child.End()
+	child.End()
+	parent.End()
+	if err := svc.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	var spans []SpanData
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s SpanData
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatalf("decode %q: %v", scanner.Text(), err)
+		}
+		spans = append(spans, s)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Name != "child" || spans[0].Attributes["k"] != "v" || spans[0].ParentID != spans[1].SpanID {
+		t.Errorf("unexpected spans %+v", spans)
+	}
+	if spans[0].Duration() < 0 {
+		t.Error("expected non-negative duration")
+	}
+}
@@ -0,0 +1,232 @@
+package sum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/zoobzio/capitan"
+)
+
+var (
+	// ErrNoDatabase is returned by Transact before any Database is created.
+	ErrNoDatabase = errors.New("transact: no database registered")
+	// ErrMultipleDatabases is returned by Transact when Databases were
+	// created on more than one *sqlx.DB; use TransactOn to pick one.
+	ErrMultipleDatabases = errors.New("transact: databases use more than one connection pool, use TransactOn")
+)
+
+// Transaction signals. Nested transactions carry the savepoint name.
+var (
+	SignalTxCommit   = capitan.NewSignal("sum.tx.commit", "Transaction committed")
+	SignalTxRollback = capitan.NewSignal("sum.tx.rollback", "Transaction rolled back")
+)
+
+// Transaction signal fields.
+var (
+	KeyTxSavepoint = capitan.NewStringKey("savepoint")
+	KeyTxDuration  = capitan.NewDurationKey("duration")
+	KeyTxError     = capitan.NewErrorKey("error")
+)
+
+// txKey carries the open transaction in a context.
+type txKey struct{}
+
+// txState is a transaction in progress on one connection pool. A
+// transaction opened on another pool inside it keeps it as parent, so
+// both stay reachable from the inner context.
+type txState struct {
+	db         *sqlx.DB
+	tx         *sqlx.Tx
+	parent     *txState
+	onCommit   []func()
+	savepoints int
+}
+
+// txOn returns the transaction in ctx on db, if any.
+func txOn(ctx context.Context, db *sqlx.DB) (*txState, bool) {
+	t, _ := ctx.Value(txKey{}).(*txState)
+	for ; t != nil; t = t.parent {
+		if t.db == db {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Transact runs fn as a unit of work on the connection pool shared by every
+// Database. Database calls made with the context passed to fn run inside the
+// transaction, which commits when fn returns nil and rolls back when it
+// returns an error or panics.
+//
+// Calling Transact inside fn opens a savepoint, so an inner failure rolls
+// back only the inner work. The inner call targets the only pool if there
+// is one, or else the pool of the enclosing transaction. When that is not
+// the enclosing transaction's pool, the inner call starts a separate
+// transaction on it instead, which commits on its own whatever the outer
+// transaction does later.
+//
+// On the transaction's pool, these join it: the Database methods Get, Set,
+// Delete, Exists, ExecQuery, ExecSelect, ExecInsert, ExecUpdate, ExecDelete
+// and ExecAggregate; Cached Get, Set and Delete, which also defer cache
+// invalidation to commit; EventStore.Append, which also defers emitting its
+// events to commit; and TxFrom, which returns the innermost transaction.
+// Statements run through Database.Executor() do not join; pass TxFrom's
+// transaction to the Database Tx methods instead.
+//
+// The transaction is bound to one connection; do not use its context from
+// several goroutines at once.
+func Transact(ctx context.Context, fn func(context.Context) error) error {
+	s := svc()
+	s.mu.RLock()
+	dbs := s.dbs
+	s.mu.RUnlock()
+	switch len(dbs) {
+	case 0:
+		return ErrNoDatabase
+	case 1:
+		return TransactOn(ctx, dbs[0], fn)
+	}
+	if outer, ok := ctx.Value(txKey{}).(*txState); ok {
+		return TransactOn(ctx, outer.db, fn)
+	}
+	return ErrMultipleDatabases
+}
+
+// TransactOn is Transact on an explicit connection pool. Inside a
+// transaction on db it opens a savepoint; inside one on another pool it
+// starts a separate transaction on db.
+func TransactOn(ctx context.Context, db *sqlx.DB, fn func(context.Context) error) (err error) {
+	if outer, ok := txOn(ctx, db); ok {
+		return outer.savepoint(ctx, fn)
+	}
+	ctx, span := StartSpan(ctx, "transaction")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	start := time.Now()
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			txDone(ctx, SignalTxRollback, "", start, fmt.Errorf("panic: %v", p))
+			panic(p)
+		}
+	}()
+
+	parent, _ := ctx.Value(txKey{}).(*txState)
+	state := &txState{db: db, tx: tx, parent: parent}
+	if err = fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+		txDone(ctx, SignalTxRollback, "", start, err)
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		txDone(ctx, SignalTxRollback, "", start, err)
+		return err
+	}
+	txDone(ctx, SignalTxCommit, "", start, nil)
+	for _, hook := range state.onCommit {
+		hook()
+	}
+	return nil
+}
+
+// savepoint runs fn inside a savepoint of the open transaction.
+func (t *txState) savepoint(ctx context.Context, fn func(context.Context) error) (err error) {
+	t.savepoints++
+	name := fmt.Sprintf("sum_sp_%d", t.savepoints)
+	start := time.Now()
+	hooks := len(t.onCommit)
+	if _, err := t.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = t.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			t.onCommit = t.onCommit[:hooks]
+			txDone(ctx, SignalTxRollback, name, start, fmt.Errorf("panic: %v", p))
+			panic(p)
+		}
+	}()
+
+	if err = fn(ctx); err != nil {
+		if _, rerr := t.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+		t.onCommit = t.onCommit[:hooks]
+		txDone(ctx, SignalTxRollback, name, start, err)
+		return err
+	}
+	if _, err = t.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		txDone(ctx, SignalTxRollback, name, start, err)
+		return err
+	}
+	txDone(ctx, SignalTxCommit, name, start, nil)
+	return nil
+}
+
+// txDone emits a commit or rollback signal.
+func txDone(ctx context.Context, signal capitan.Signal, savepoint string, start time.Time, err error) {
+	fields := append([]capitan.Field{
+		KeyTxSavepoint.Field(savepoint),
+		KeyTxDuration.Field(time.Since(start)),
+	}, correlationFields(ctx)...)
+	if err != nil {
+		capitan.Warn(ctx, signal, append(fields, KeyTxError.Field(err))...)
+		return
+	}
+	capitan.Info(ctx, signal, fields...)
+}
+
+// TxFrom returns the innermost transaction Transact placed in ctx, for raw
+// sqlx work that should commit or roll back with it.
+func TxFrom(ctx context.Context) (*sqlx.Tx, bool) {
+	t, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		return nil, false
+	}
+	return t.tx, true
+}
+
+// afterCommit runs fn once the transaction in ctx on db commits, or at once
+// if ctx has no transaction on db. Hooks added inside a savepoint that rolls
+// back are dropped.
+func afterCommit(ctx context.Context, db *sqlx.DB, fn func()) {
+	t, ok := txOn(ctx, db)
+	if !ok {
+		fn()
+		return
+	}
+	t.onCommit = append(t.onCommit, fn)
+}
+
+// tx returns the transaction in ctx if it is on this database's pool.
+func (d *Database[M]) tx(ctx context.Context) (*sqlx.Tx, bool) {
+	t, ok := txOn(ctx, d.db)
+	if !ok {
+		return nil, false
+	}
+	return t.tx, true
+}
+
+// useDB records a connection pool Transact may use.
+func (s *Service) useDB(db *sqlx.DB) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, known := range s.dbs {
+		if known == db {
+			return
+		}
+	}
+	s.dbs = append(s.dbs, db)
+}
@@ -0,0 +1,254 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/edamame"
+)
+
+func TestTransactCommitsAndRollsBack(t *testing.T) {
+	db, state := newTestDB(t)
+	svc().useDB(db)
+
+	if err := Transact(context.Background(), func(ctx context.Context) error {
+		if _, ok := TxFrom(ctx); !ok {
+			t.Error("expected transaction in context")
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Transact: %v", err)
+	}
+
+	boom := errors.New("boom")
+	if err := Transact(context.Background(), func(context.Context) error { return boom }); !errors.Is(err, boom) {
+		t.Errorf("expected fn error returned, got %v", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic to propagate")
+			}
+		}()
+		_ = Transact(context.Background(), func(context.Context) error { panic("boom") })
+	}()
+
+	if strings.Join(state.txlog, ",") != "COMMIT,ROLLBACK,ROLLBACK" {
+		t.Errorf("unexpected transaction outcomes %q", state.txlog)
+	}
+}
+
+func TestTransactSavepoints(t *testing.T) {
+	db, state := newTestDB(t)
+
+	err := TransactOn(context.Background(), db, func(ctx context.Context) error {
+		if err := TransactOn(ctx, db, func(context.Context) error { return nil }); err != nil {
+			return err
+		}
+		if err := TransactOn(ctx, db, func(context.Context) error { return errors.New("inner") }); err == nil {
+			t.Error("expected inner error")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TransactOn: %v", err)
+	}
+	want := []string{
+		"SAVEPOINT sum_sp_1", "RELEASE SAVEPOINT sum_sp_1",
+		"SAVEPOINT sum_sp_2", "ROLLBACK TO SAVEPOINT sum_sp_2",
+	}
+	if strings.Join(state.log, ";") != strings.Join(want, ";") {
+		t.Errorf("unexpected savepoints %q", state.log)
+	}
+	if strings.Join(state.txlog, ",") != "COMMIT" {
+		t.Errorf("expected outer commit only, got %q", state.txlog)
+	}
+}
+
+func TestTransactSignals(t *testing.T) {
+	db, _ := newTestDB(t)
+
+	var mu sync.Mutex
+	var got []string
+	obs := capitan.Observe(func(_ context.Context, ev *capitan.Event) {
+		if ev.Signal() != SignalTxCommit && ev.Signal() != SignalTxRollback {
+			return
+		}
+		sp, _ := KeyTxSavepoint.From(ev)
+		_, failed := KeyTxError.From(ev)
+		mu.Lock()
+		got = append(got, ev.Signal().Name()+"/"+sp+"/"+map[bool]string{true: "err", false: "ok"}[failed])
+		mu.Unlock()
+	})
+	t.Cleanup(obs.Close)
+
+	_ = TransactOn(context.Background(), db, func(ctx context.Context) error {
+		_ = TransactOn(ctx, db, func(context.Context) error { return errors.New("inner") })
+		return nil
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := "sum.tx.rollback/sum_sp_1/err,sum.tx.commit//ok"
+	if strings.Join(got, ",") != want {
+		t.Errorf("expected %s, got %v", want, got)
+	}
+}
+
+func TestTransactNeedsOneDatabase(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	New()
+	noop := func(context.Context) error { return nil }
+	if err := Transact(context.Background(), noop); !errors.Is(err, ErrNoDatabase) {
+		t.Errorf("expected ErrNoDatabase, got %v", err)
+	}
+
+	a, _ := openTestMigrateDB(t, "postgres")
+	svc().useDB(a)
+	svc().useDB(a)
+	if err := Transact(context.Background(), noop); err != nil {
+		t.Errorf("expected one database, got %v", err)
+	}
+
+	b, _ := openTestMigrateDB(t, "postgres")
+	svc().useDB(b)
+	if err := Transact(context.Background(), noop); !errors.Is(err, ErrMultipleDatabases) {
+		t.Errorf("expected ErrMultipleDatabases, got %v", err)
+	}
+}
+
+func TestDatabaseUsesContextTransaction(t *testing.T) {
+	db, _ := newTestDB(t)
+	other, _ := openTestMigrateDB(t, "postgres")
+	onDB := &Database[testModel]{db: db}
+	onOther := &Database[testModel]{db: other}
+
+	if _, ok := onDB.tx(context.Background()); ok {
+		t.Error("expected no transaction outside Transact")
+	}
+	_ = TransactOn(context.Background(), db, func(ctx context.Context) error {
+		if _, ok := onDB.tx(ctx); !ok {
+			t.Error("expected database on the same pool to join the transaction")
+		}
+		if _, ok := onOther.tx(ctx); ok {
+			t.Error("expected database on another pool to stay outside the transaction")
+		}
+		return nil
+	})
+}
+
+func TestTransactNestsAcrossPools(t *testing.T) {
+	a, state := newTestDB(t)
+	b, _ := openTestMigrateDB(t, "postgres")
+	svc().useDB(a)
+	svc().useDB(b)
+
+	err := TransactOn(context.Background(), a, func(ctx context.Context) error {
+		return Transact(ctx, func(context.Context) error { return nil })
+	})
+	if err != nil {
+		t.Fatalf("expected nested Transact to open a savepoint, got %v", err)
+	}
+	if strings.Join(state.log, ";") != "SAVEPOINT sum_sp_1;RELEASE SAVEPOINT sum_sp_1" {
+		t.Errorf("unexpected statements %q", state.log)
+	}
+}
+
+func TestTransactOnOtherPoolStartsSeparateTransaction(t *testing.T) {
+	a, state := newTestDB(t)
+	b, _ := openTestMigrateDB(t, "postgres")
+	svc().useDB(b)
+
+	err := TransactOn(context.Background(), a, func(ctx context.Context) error {
+		outer, _ := TxFrom(ctx)
+		return Transact(ctx, func(ctx context.Context) error {
+			inner, _ := TxFrom(ctx)
+			if inner == outer {
+				t.Error("expected a separate transaction on the other pool")
+			}
+			if _, ok := txOn(ctx, a); !ok {
+				t.Error("expected the outer transaction still reachable")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("Transact: %v", err)
+	}
+	// Both pools share the fake driver's state for this test.
+	if len(state.log) != 0 {
+		t.Errorf("expected no savepoints, got %q", state.log)
+	}
+	if strings.Join(state.txlog, ",") != "COMMIT,COMMIT" {
+		t.Errorf("expected two transactions committed, got %q", state.txlog)
+	}
+}
+
+var testDeleteUser = edamame.NewDeleteStatement("delete-user", "Delete a user", edamame.DeleteSpec{
+	Where: []edamame.ConditionSpec{{Field: "id", Operator: "=", Param: "id"}},
+})
+
+func TestDatabaseWritesJoinTransaction(t *testing.T) {
+	users, state := newTestDatabase(t)
+
+	err := Transact(context.Background(), func(ctx context.Context) error {
+		if err := users.Set(ctx, "a", &testModel{ID: "a"}); err != nil {
+			return err
+		}
+		_, err := users.ExecDelete(ctx, testDeleteUser, map[string]any{"id": "a"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Transact: %v", err)
+	}
+	if len(state.intx) != 1 || !strings.HasPrefix(state.intx[0], "DELETE FROM") {
+		t.Errorf("expected delete inside the transaction, got %q", state.intx)
+	}
+	if strings.Join(state.txlog, ",") != "COMMIT" {
+		t.Errorf("expected commit, got %q", state.txlog)
+	}
+
+	if _, err := users.ExecDelete(context.Background(), testDeleteUser, map[string]any{"id": "a"}); err != nil {
+		t.Fatalf("ExecDelete: %v", err)
+	}
+	if len(state.intx) != 1 || len(state.log) != 2 {
+		t.Errorf("expected delete outside Transact to run on the pool, got intx %q, log %q", state.intx, state.log)
+	}
+	res, _ := svc().Resource("db://users")
+	if res.Stats["delete"].OK != 2 || res.Stats["set"].OK != 1 {
+		t.Errorf("expected writes counted, got %+v", res.Stats)
+	}
+}
+
+func TestAfterCommit(t *testing.T) {
+	db, _ := newTestDB(t)
+
+	var ran []string
+	afterCommit(context.Background(), db, func() { ran = append(ran, "now") })
+	_ = TransactOn(context.Background(), db, func(ctx context.Context) error {
+		afterCommit(ctx, db, func() { ran = append(ran, "outer") })
+		_ = TransactOn(ctx, db, func(ctx context.Context) error {
+			afterCommit(ctx, db, func() { ran = append(ran, "rolled back") })
+			return errors.New("inner")
+		})
+		if len(ran) != 1 {
+			t.Error("expected hooks deferred until commit")
+		}
+		return nil
+	})
+	_ = TransactOn(context.Background(), db, func(ctx context.Context) error {
+		afterCommit(ctx, db, func() { ran = append(ran, "failed") })
+		return errors.New("boom")
+	})
+	if strings.Join(ran, ",") != "now,outer" {
+		t.Errorf("unexpected hooks %q", ran)
+	}
+}
@@ -0,0 +1,184 @@
+package sum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/sentinel"
+)
+
+// ErrUnknownVersion indicates a payload version with no upcaster path to the current version.
+var ErrUnknownVersion = errors.New("unknown event version")
+
+// upcaster converts a payload from one version to the next.
+type upcaster struct {
+	convert func(any) (any, error)
+	decode  func(Codec, []byte) (any, error)
+}
+
+// upcasters holds registered conversions keyed by signal name, then source version.
+// Like the event catalog, registrations are package-level and survive Reset.
+var upcasters = struct {
+	steps map[string]map[int]upcaster
+	mu    sync.RWMutex
+}{steps: make(map[string]map[int]upcaster)}
+
+// Upcast registers fn to convert version from payloads of signal into version from+1.
+// Chain registrations to carry old payloads forward, e.g. v1→v2 then v2→v3.
+func Upcast[From, To any](signal capitan.Signal, from int, fn func(From) (To, error)) {
+	step := upcaster{
+		convert: func(v any) (any, error) {
+			old, ok := v.(From)
+			if !ok {
+				return nil, fmt.Errorf("upcast %s v%d: unexpected payload %T", signal.Name(), from, v)
+			}
+			return fn(old)
+		},
+		decode: func(codec Codec, data []byte) (any, error) {
+			var old From
+			if err := codec.Unmarshal(data, &old); err != nil {
+				return nil, err
+			}
+			return old, nil
+		},
+	}
+
+	upcasters.mu.Lock()
+	defer upcasters.mu.Unlock()
+	if upcasters.steps[signal.Name()] == nil {
+		upcasters.steps[signal.Name()] = make(map[int]upcaster)
+	}
+	upcasters.steps[signal.Name()][from] = step
+}
+
+// upcastStep returns the conversion out of version from, if registered.
+func upcastStep(signal string, from int) (upcaster, bool) {
+	upcasters.mu.RLock()
+	defer upcasters.mu.RUnlock()
+	step, ok := upcasters.steps[signal][from]
+	return step, ok
+}
+
+// versionVariant is the stable capitan variant for a versioned payload.
+// It is independent of the Go type name so payload types can be renamed
+// without breaking recorded emissions.
+func versionVariant(signal capitan.Signal, version int) capitan.Variant {
+	return capitan.Variant(signal.Name() + "@v" + strconv.Itoa(version))
+}
+
+// variantVersion parses the version from a variant built by versionVariant.
+func variantVersion(signal capitan.Signal, variant capitan.Variant) (int, bool) {
+	rest, ok := strings.CutPrefix(string(variant), signal.Name()+"@v")
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.Atoi(rest)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// WithVersion returns a copy of the event carrying an explicit payload version.
+// Versioned events use a variant derived from the signal and version rather than
+// the payload's FQDN, and their listeners upcast older emissions to T.
+func (e Event[T]) WithVersion(version int) Event[T] {
+	e.version = version
+	e.Key = capitan.NewKey[T]("data", versionVariant(e.Signal, version))
+	registerEvent(e.Signal, e.level, sentinel.Inspect[T](), version)
+	return e
+}
+
+// Version returns the event's payload version, or 0 if unversioned.
+func (e Event[T]) Version() int {
+	return e.version
+}
+
+// upcast carries a payload from version through every registered step to the
+// event's current version.
+func (e Event[T]) upcast(version int, payload any) (T, error) {
+	var zero T
+	for v := version; v < e.version; v++ {
+		step, ok := upcastStep(e.Signal.Name(), v)
+		if !ok {
+			return zero, fmt.Errorf("%w: %s v%d", ErrUnknownVersion, e.Signal.Name(), v)
+		}
+		next, err := step.convert(payload)
+		if err != nil {
+			return zero, err
+		}
+		payload = next
+	}
+	data, ok := payload.(T)
+	if !ok {
+		return zero, fmt.Errorf("%w: %s v%d does not upcast to %T", ErrUnknownVersion, e.Signal.Name(), version, zero)
+	}
+	return data, nil
+}
+
+// extract returns the current payload from a capitan event, upcasting
+// emissions made by older versions of a versioned event.
+func (e Event[T]) extract(ev *capitan.Event) (T, bool) {
+	if data, ok := e.Key.From(ev); ok {
+		return data, true
+	}
+	var zero T
+	if e.version == 0 {
+		return zero, false
+	}
+	f := ev.Get(e.Key)
+	if f == nil {
+		return zero, false
+	}
+	version, ok := variantVersion(e.Signal, f.Variant())
+	if !ok || version >= e.version {
+		return zero, false
+	}
+	data, err := e.upcast(version, f.Value())
+	if err != nil {
+		return zero, false
+	}
+	return data, true
+}
+
+// Decode decodes a payload serialized at the given version with the Service
+// codec and upcasts it to the current T.
+func (e Event[T]) Decode(version int, data []byte) (T, error) {
+	var zero T
+	codec := svc().codecOrDefault()
+	if version == e.version {
+		var v T
+		if err := codec.Unmarshal(data, &v); err != nil {
+			return zero, err
+		}
+		return v, nil
+	}
+	if version > e.version {
+		return zero, fmt.Errorf("%w: %s v%d is newer than v%d", ErrUnknownVersion, e.Signal.Name(), version, e.version)
+	}
+	step, ok := upcastStep(e.Signal.Name(), version)
+	if !ok {
+		return zero, fmt.Errorf("%w: %s v%d", ErrUnknownVersion, e.Signal.Name(), version)
+	}
+	old, err := step.decode(codec, data)
+	if err != nil {
+		return zero, err
+	}
+	return e.upcast(version, old)
+}
+
+// Relay decodes a payload serialized at the given version and emits it as the
+// current T. Use it to replay recorded or outboxed emissions.
+func (e Event[T]) Relay(ctx context.Context, version int, data []byte) error {
+	v, err := e.Decode(version, data)
+	if err != nil {
+		return err
+	}
+	e.Emit(ctx, v)
+	return nil
+}
@@ -0,0 +1,120 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zoobzio/capitan"
+)
+
+type testOrderV1 struct {
+	Name string `json:"name"`
+}
+
+type testOrderV2 struct {
+	First string `json:"first"`
+	Last  string `json:"last"`
+}
+
+type testOrderV3 struct {
+	First string `json:"first"`
+	Last  string `json:"last"`
+	Email string `json:"email"`
+}
+
+// versionedSignal registers a v1→v2→v3 upcaster chain for a fresh signal.
+func versionedSignal(name string) capitan.Signal {
+	signal := capitan.NewSignal(name, "Versioned order")
+	Upcast(signal, 1, func(o testOrderV1) (testOrderV2, error) {
+		first, last, _ := strings.Cut(o.Name, " ")
+		return testOrderV2{First: first, Last: last}, nil
+	})
+	Upcast(signal, 2, func(o testOrderV2) (testOrderV3, error) {
+		return testOrderV3{First: o.First, Last: o.Last, Email: "unknown"}, nil
+	})
+	return signal
+}
+
+func TestEventWithVersion(t *testing.T) {
+	signal := capitan.NewSignal("test.version.variant", "Variant")
+	event := NewInfoEvent[testOrderV1](signal).WithVersion(4)
+
+	if event.Version() != 4 {
+		t.Errorf("expected version 4, got %d", event.Version())
+	}
+	if event.Key.Variant() != "test.version.variant@v4" {
+		t.Errorf("expected stable variant, got %q", event.Key.Variant())
+	}
+}
+
+func TestVersionedListenerUpcastsOlderEmissions(t *testing.T) {
+	signal := versionedSignal("test.version.listen")
+	v1 := NewInfoEvent[testOrderV1](signal).WithVersion(1)
+	v3 := NewInfoEvent[testOrderV3](signal).WithVersion(3)
+
+	var got []testOrderV3
+	l := v3.Listen(func(_ context.Context, o testOrderV3) {
+		got = append(got, o)
+	})
+	defer l.Close()
+
+	ctx := context.Background()
+	v1.Emit(ctx, testOrderV1{Name: "Ada Lovelace"})
+	v3.Emit(ctx, testOrderV3{First: "Alan", Last: "Turing", Email: "alan@example.com"})
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 deliveries, got %d", len(got))
+	}
+	if got[0] != (testOrderV3{First: "Ada", Last: "Lovelace", Email: "unknown"}) {
+		t.Errorf("unexpected upcast payload %+v", got[0])
+	}
+	if got[1].Email != "alan@example.com" {
+		t.Errorf("unexpected current payload %+v", got[1])
+	}
+}
+
+func TestEventRelayDecodesOlderVersion(t *testing.T) {
+	resetAll(t)
+	New()
+
+	signal := versionedSignal("test.version.relay")
+	v3 := NewInfoEvent[testOrderV3](signal).WithVersion(3)
+
+	var got testOrderV3
+	l := v3.Listen(func(_ context.Context, o testOrderV3) { got = o })
+	defer l.Close()
+
+	if err := v3.Relay(context.Background(), 1, []byte(`{"name":"Grace Hopper"}`)); err != nil {
+		t.Fatalf("Relay: %v", err)
+	}
+	if got.First != "Grace" || got.Last != "Hopper" {
+		t.Errorf("unexpected relayed payload %+v", got)
+	}
+
+	if _, err := v3.Decode(7, []byte(`{}`)); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("expected ErrUnknownVersion for newer version, got %v", err)
+	}
+	if _, err := v3.Decode(0, []byte(`{}`)); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("expected ErrUnknownVersion without upcaster, got %v", err)
+	}
+}
+
+func TestVersionedCatalogKeepsLatest(t *testing.T) {
+	signal := capitan.NewSignal("test.version.catalog", "Catalog")
+	NewInfoEvent[testOrderV3](signal).WithVersion(3)
+	NewInfoEvent[testOrderV1](signal).WithVersion(1)
+
+	for _, spec := range Events() {
+		if spec.Signal == "test.version.catalog" {
+			if spec.Version != 3 || spec.Payload.TypeName != "testOrderV3" {
+				t.Errorf("expected latest version in catalog, got v%d %s", spec.Version, spec.Payload.TypeName)
+			}
+			return
+		}
+	}
+	t.Fatal("event not catalogued")
+}
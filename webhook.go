@@ -0,0 +1,452 @@
+package sum
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zoobzio/grub"
+)
+
+// Webhook request headers.
+const (
+	HeaderWebhookID        = "X-Webhook-ID"
+	HeaderWebhookSignal    = "X-Webhook-Signal"
+	HeaderWebhookTimestamp = "X-Webhook-Timestamp"
+	HeaderWebhookSignature = "X-Webhook-Signature"
+)
+
+// WebhookStatus is the delivery state of a webhook.
+type WebhookStatus string
+
+// Webhook delivery states.
+const (
+	WebhookPending   WebhookStatus = "pending"
+	WebhookDelivered WebhookStatus = "delivered"
+	WebhookDead      WebhookStatus = "dead"
+)
+
+// Webhook errors.
+var (
+	// ErrWebhookNotFound indicates no delivery exists with the given ID.
+	ErrWebhookNotFound = errors.New("webhook delivery not found")
+	// ErrWebhookNotDead indicates a redelivery of a delivery that has not
+	// exhausted its retries.
+	ErrWebhookNotDead = errors.New("webhook delivery is not dead-lettered")
+	// ErrWebhookInFlight indicates a redelivery of a delivery that is
+	// already being sent.
+	ErrWebhookInFlight = errors.New("webhook delivery is in flight")
+)
+
+// WebhookEndpoint is a customer-configured destination for deliveries.
+type WebhookEndpoint struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+// WebhookEndpoints looks up an endpoint by ID. It is called before every
+// attempt, so rotated secrets and moved URLs apply to pending deliveries.
+type WebhookEndpoints func(ctx context.Context, id string) (WebhookEndpoint, error)
+
+// WebhookDelivery records one payload's delivery to one endpoint.
+type WebhookDelivery struct {
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	ID            string        `json:"id"`
+	CorrelationID string        `json:"correlation_id,omitempty"`
+	Signal        string        `json:"signal"`
+	ContentType   string        `json:"content_type"`
+	LastError     string        `json:"last_error,omitempty"`
+	Status        WebhookStatus `json:"status"`
+	EndpointID    string        `json:"endpoint_id"`
+	Payload       []byte        `json:"payload"`
+	Attempts      int           `json:"attempts"`
+}
+
+// Webhooks delivers events to external URLs with signing and retries.
+// Every attempt is recorded in the backing Store so failed deliveries
+// can be inspected and redelivered. Records name their endpoint by ID;
+// endpoint secrets are never stored with them.
+type Webhooks struct {
+	store      *Store[WebhookDelivery]
+	endpoints  WebhookEndpoints
+	client     *http.Client
+	codec      Codec
+	done       chan struct{}
+	listeners  []*Listener
+	running    map[string]bool
+	wg         sync.WaitGroup
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	retention  time.Duration
+	closed     bool
+	mu         sync.Mutex
+}
+
+// WebhookOption configures Webhooks.
+type WebhookOption func(*Webhooks)
+
+// WithWebhookClient sets the HTTP client used for deliveries.
+func WithWebhookClient(c *http.Client) WebhookOption {
+	return func(w *Webhooks) {
+		w.client = c
+	}
+}
+
+// WithWebhookRetry sets the attempt limit and exponential backoff bounds.
+// The delay after attempt n is backoff * 2^(n-1), capped at maxBackoff.
+func WithWebhookRetry(attempts int, backoff, maxBackoff time.Duration) WebhookOption {
+	return func(w *Webhooks) {
+		w.attempts = attempts
+		w.backoff = backoff
+		w.maxBackoff = maxBackoff
+	}
+}
+
+// WithWebhookRetention sets how long delivered records are kept. Pending
+// and dead-lettered records are kept until they are delivered. Zero keeps
+// delivered records forever.
+func WithWebhookRetention(d time.Duration) WebhookOption {
+	return func(w *Webhooks) {
+		w.retention = d
+	}
+}
+
+// webhookKeyPrefix namespaces delivery records in the store.
+const webhookKeyPrefix = "webhook:"
+
+// NewWebhooks creates a dispatcher recording deliveries in store and
+// resolving their destinations with endpoints.
+// Payloads are encoded with the Service codec. In-flight deliveries are
+// drained when the Service shuts down; call Resume on startup to continue
+// deliveries a previous process left pending. Delivered records are kept
+// for a week unless WithWebhookRetention says otherwise.
+func NewWebhooks(store *Store[WebhookDelivery], endpoints WebhookEndpoints, opts ...WebhookOption) *Webhooks {
+	s := svc()
+	w := &Webhooks{
+		store:      store,
+		endpoints:  endpoints,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		codec:      s.codecOrDefault(),
+		done:       make(chan struct{}),
+		running:    make(map[string]bool),
+		attempts:   5,
+		backoff:    time.Second,
+		maxBackoff: time.Minute,
+		retention:  7 * 24 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	s.onShutdown(w.Close)
+	return w
+}
+
+// WebhookEvent delivers emissions of e to the endpoint IDs returned by
+// resolve. The resolver receives the emission context and payload, so
+// endpoints can be chosen per customer. Payloads with a Boundary are sent,
+// and recorded, as its Send output.
+func WebhookEvent[T any](w *Webhooks, e Event[T], resolve func(context.Context, T) []string) {
+	signal := e.Signal.Name()
+	l := e.Listen(func(ctx context.Context, data T) {
+		endpoints := resolve(ctx, data)
+		if len(endpoints) == 0 {
+			return
+		}
+		payload, err := marshalOutbound(ctx, w.codec, data)
+		if err != nil {
+			return
+		}
+		for _, ep := range endpoints {
+			w.enqueue(ctx, signal, ep, payload)
+		}
+	})
+	if l == nil {
+		return
+	}
+	w.mu.Lock()
+	w.listeners = append(w.listeners, l)
+	w.mu.Unlock()
+}
+
+// enqueue records a new delivery and starts sending it.
+func (w *Webhooks) enqueue(ctx context.Context, signal, endpoint string, payload []byte) {
+	now := time.Now()
+	d := WebhookDelivery{
+		ID:            uuid.New().String(),
+		CorrelationID: CorrelationID(ctx),
+		Signal:        signal,
+		EndpointID:    endpoint,
+		ContentType:   w.codec.ContentType(),
+		Payload:       payload,
+		Status:        WebhookPending,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := w.save(ctx, &d); err != nil {
+		return
+	}
+	w.start(d)
+}
+
+// start launches the delivery loop unless the dispatcher is closed.
+func (w *Webhooks) start(d WebhookDelivery) {
+	if w.claim(d.ID) {
+		w.launch(d)
+	}
+}
+
+// claim marks a delivery as in flight. It reports false if the delivery is
+// already being sent or the dispatcher is closed.
+func (w *Webhooks) claim(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed || w.running[id] {
+		return false
+	}
+	w.running[id] = true
+	return true
+}
+
+// release clears a delivery's claim.
+func (w *Webhooks) release(id string) {
+	w.mu.Lock()
+	delete(w.running, id)
+	w.mu.Unlock()
+}
+
+// launch runs a claimed delivery, releasing it if the dispatcher has closed.
+func (w *Webhooks) launch(d WebhookDelivery) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		delete(w.running, d.ID)
+		return
+	}
+	w.wg.Add(1)
+	go w.run(d)
+}
+
+// run attempts delivery until success, exhaustion, or shutdown.
+// A delivery interrupted by shutdown stays pending with its attempts
+// recorded, for Resume to pick up.
+func (w *Webhooks) run(d WebhookDelivery) {
+	defer w.wg.Done()
+	defer w.release(d.ID)
+	ctx := context.Background()
+
+	for {
+		err := w.send(ctx, &d)
+		d.Attempts++
+		d.UpdatedAt = time.Now()
+		switch {
+		case err == nil:
+			d.Status = WebhookDelivered
+			d.LastError = ""
+		case d.Attempts >= w.attempts:
+			d.Status = WebhookDead
+			d.LastError = err.Error()
+		default:
+			d.LastError = err.Error()
+		}
+		_ = w.save(ctx, &d)
+		if d.Status != WebhookPending {
+			return
+		}
+
+		timer := time.NewTimer(w.delay(d.Attempts))
+		select {
+		case <-timer.C:
+		case <-w.done:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// delay returns the backoff before the next attempt.
+func (w *Webhooks) delay(attempts int) time.Duration {
+	d := w.backoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= w.maxBackoff {
+			return w.maxBackoff
+		}
+	}
+	return d
+}
+
+// send resolves the delivery's endpoint and performs a single signed POST.
+func (w *Webhooks) send(ctx context.Context, d *WebhookDelivery) error {
+	ep, err := w.endpoints(ctx, d.EndpointID)
+	if err != nil {
+		return fmt.Errorf("resolve endpoint %s: %w", d.EndpointID, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", d.ContentType)
+	req.Header.Set(HeaderWebhookID, d.ID)
+	req.Header.Set(HeaderWebhookSignal, d.Signal)
+	req.Header.Set(HeaderWebhookTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderWebhookSignature, SignWebhook(ep.Secret, ts, d.Payload))
+	if d.CorrelationID != "" {
+		req.Header.Set(HeaderRequestID, d.CorrelationID)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook endpoint responded %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignWebhook computes the signature header value for a payload.
+// Receivers verify deliveries by recomputing it with their shared secret
+// and the X-Webhook-Timestamp header.
+func SignWebhook(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// save writes a delivery record. Delivered records expire after the
+// retention period.
+func (w *Webhooks) save(ctx context.Context, d *WebhookDelivery) error {
+	var ttl time.Duration
+	if d.Status == WebhookDelivered {
+		ttl = w.retention
+	}
+	return w.store.Set(ctx, webhookKeyPrefix+d.ID, d, ttl)
+}
+
+// Delivery returns the record for a single delivery.
+func (w *Webhooks) Delivery(ctx context.Context, id string) (*WebhookDelivery, error) {
+	d, err := w.store.Get(ctx, webhookKeyPrefix+id)
+	if errors.Is(err, grub.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrWebhookNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// DeadLetters returns deliveries that exhausted their retries.
+func (w *Webhooks) DeadLetters(ctx context.Context) ([]WebhookDelivery, error) {
+	return w.list(ctx, WebhookDead)
+}
+
+// Pending returns deliveries that have not yet succeeded or exhausted their
+// retries, including ones a previous process left behind.
+func (w *Webhooks) Pending(ctx context.Context) ([]WebhookDelivery, error) {
+	return w.list(ctx, WebhookPending)
+}
+
+// list returns the deliveries with the given status.
+func (w *Webhooks) list(ctx context.Context, status WebhookStatus) ([]WebhookDelivery, error) {
+	keys, err := w.store.List(ctx, webhookKeyPrefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out []WebhookDelivery
+	for _, key := range keys {
+		d, err := w.store.Get(ctx, key)
+		if err != nil {
+			continue
+		}
+		if d.Status == status {
+			out = append(out, *d)
+		}
+	}
+	return out, nil
+}
+
+// Resume restarts pending deliveries that are not already in flight, such
+// as ones cut off by Close or a restart. Attempts already made still count
+// toward the retry limit.
+func (w *Webhooks) Resume(ctx context.Context) error {
+	pending, err := w.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	for _, d := range pending {
+		w.start(d)
+	}
+	return nil
+}
+
+// Redeliver resets a dead-lettered delivery's attempts and sends it again.
+// Returns ErrWebhookNotDead for deliveries that are delivered or pending,
+// and ErrWebhookInFlight if the delivery is already being sent.
+func (w *Webhooks) Redeliver(ctx context.Context, id string) error {
+	if !w.claim(id) {
+		return fmt.Errorf("%w: %s", ErrWebhookInFlight, id)
+	}
+	d, err := w.Delivery(ctx, id)
+	if err == nil && d.Status != WebhookDead {
+		err = fmt.Errorf("%w: %s is %s", ErrWebhookNotDead, id, d.Status)
+	}
+	if err == nil {
+		d.Status = WebhookPending
+		d.Attempts = 0
+		d.UpdatedAt = time.Now()
+		err = w.save(ctx, d)
+	}
+	if err != nil {
+		w.release(id)
+		return err
+	}
+	w.launch(*d)
+	return nil
+}
+
+// Close stops listening, abandons pending retries, and waits for in-flight
+// attempts to finish or ctx to expire.
+func (w *Webhooks) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	listeners := w.listeners
+	w.listeners = nil
+	w.mu.Unlock()
+
+	for _, l := range listeners {
+		l.Close()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
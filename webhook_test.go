@@ -0,0 +1,379 @@
+//go:build testing
+
+package sum
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zoobzio/capitan"
+	"github.com/zoobzio/cereal"
+)
+
+type testInvoice struct {
+	ID     string `json:"id"`
+	Amount int    `json:"amount"`
+}
+
+// testEndpoints resolves endpoints from a fixed set.
+func testEndpoints(endpoints ...WebhookEndpoint) WebhookEndpoints {
+	return func(_ context.Context, id string) (WebhookEndpoint, error) {
+		for _, ep := range endpoints {
+			if ep.ID == id {
+				return ep, nil
+			}
+		}
+		return WebhookEndpoint{}, errors.New("unknown endpoint")
+	}
+}
+
+func newTestWebhooks(t *testing.T, attempts int, endpoints ...WebhookEndpoint) *Webhooks {
+	t.Helper()
+	store, err := NewStore[WebhookDelivery](NewMemoryStore(), "webhooks")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	w := NewWebhooks(store, testEndpoints(endpoints...), WithWebhookRetry(attempts, time.Millisecond, 5*time.Millisecond))
+	t.Cleanup(func() { _ = w.Close(context.Background()) })
+	return w
+}
+
+// waitForDelivery polls until the only delivery reaches status.
+func waitForDelivery(t *testing.T, w *Webhooks, status WebhookStatus) WebhookDelivery {
+	t.Helper()
+	ctx := context.Background()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		keys, _ := w.store.List(ctx, webhookKeyPrefix, 0)
+		if len(keys) == 1 {
+			d, err := w.store.Get(ctx, keys[0])
+			if err == nil && d.Status == status {
+				return *d
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for delivery status %q", status)
+	return WebhookDelivery{}
+}
+
+// waitIdle polls until no delivery is in flight.
+func waitIdle(t *testing.T, w *Webhooks) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		w.mu.Lock()
+		n := len(w.running)
+		w.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("timeout waiting for in-flight deliveries")
+}
+
+func TestWebhookDeliversSignedPayload(t *testing.T) {
+	resetAll(t)
+	New()
+
+	type received struct {
+		header http.Header
+		body   string
+	}
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{header: r.Header.Clone(), body: string(body)}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	w := newTestWebhooks(t, 3, WebhookEndpoint{ID: "ep_1", URL: srv.URL, Secret: "shh"})
+	event := NewInfoEvent[testInvoice](capitan.NewSignal("test.webhook.paid", "Invoice paid"))
+	WebhookEvent(w, event, func(context.Context, testInvoice) []string {
+		return []string{"ep_1"}
+	})
+
+	event.Emit(context.Background(), testInvoice{ID: "inv_1", Amount: 42})
+
+	var r received
+	select {
+	case r = <-got:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for webhook")
+	}
+
+	if r.body != `{"id":"inv_1","amount":42}` {
+		t.Errorf("unexpected body %q", r.body)
+	}
+	if sig := r.header.Get(HeaderWebhookSignal); sig != "test.webhook.paid" {
+		t.Errorf("expected signal header, got %q", sig)
+	}
+	ts, err := strconv.ParseInt(r.header.Get(HeaderWebhookTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("bad timestamp header: %v", err)
+	}
+	if want := SignWebhook("shh", ts, []byte(r.body)); r.header.Get(HeaderWebhookSignature) != want {
+		t.Errorf("signature mismatch: got %q want %q", r.header.Get(HeaderWebhookSignature), want)
+	}
+
+	d := waitForDelivery(t, w, WebhookDelivered)
+	if d.Attempts != 1 || d.ID != r.header.Get(HeaderWebhookID) || d.EndpointID != "ep_1" {
+		t.Errorf("unexpected delivery record %+v", d)
+	}
+	raw, _ := w.store.provider.Get(context.Background(), webhookKeyPrefix+d.ID)
+	if strings.Contains(string(raw), "shh") {
+		t.Errorf("expected endpoint secret kept out of the delivery record, got %s", raw)
+	}
+}
+
+func TestWebhookAppliesBoundary(t *testing.T) {
+	resetAll(t)
+	New().WithEncryptor(cereal.EncryptAES, stubEncryptor{}).WithHasher(cereal.HashSHA256, stubHasher{})
+
+	k := Start()
+	if _, err := NewBoundary[testLogAccount](k); err != nil {
+		t.Fatalf("NewBoundary: %v", err)
+	}
+	Freeze(k)
+
+	got := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- string(body)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	w := newTestWebhooks(t, 1, WebhookEndpoint{ID: "ep_1", URL: srv.URL})
+	event := NewInfoEvent[testLogAccount](capitan.NewSignal("test.webhook.masked", "Webhook masked"))
+	WebhookEvent(w, event, func(context.Context, testLogAccount) []string {
+		return []string{"ep_1"}
+	})
+
+	event.Emit(context.Background(), testLogAccount{ID: "a1", Email: "alice@example.com", Token: "tok"})
+
+	select {
+	case body := <-got:
+		if strings.Contains(body, "alice@example.com") || !strings.Contains(body, `"token":"***"`) {
+			t.Errorf("expected boundary-masked payload, got %s", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for webhook")
+	}
+}
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	resetAll(t)
+	New()
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) < 3 {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	w := newTestWebhooks(t, 5, WebhookEndpoint{ID: "ep_1", URL: srv.URL})
+	event := NewInfoEvent[testInvoice](capitan.NewSignal("test.webhook.retry", "Invoice retry"))
+	WebhookEvent(w, event, func(context.Context, testInvoice) []string {
+		return []string{"ep_1"}
+	})
+
+	event.Emit(context.Background(), testInvoice{ID: "inv_2"})
+
+	d := waitForDelivery(t, w, WebhookDelivered)
+	if d.Attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", d.Attempts)
+	}
+}
+
+func TestWebhookDeadLetterAndRedeliver(t *testing.T) {
+	resetAll(t)
+	New()
+
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		if !healthy.Load() {
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	w := newTestWebhooks(t, 2, WebhookEndpoint{ID: "ep_1", URL: srv.URL})
+	event := NewInfoEvent[testInvoice](capitan.NewSignal("test.webhook.dead", "Invoice dead"))
+	WebhookEvent(w, event, func(context.Context, testInvoice) []string {
+		return []string{"ep_1"}
+	})
+
+	event.Emit(context.Background(), testInvoice{ID: "inv_3"})
+	dead := waitForDelivery(t, w, WebhookDead)
+	if !strings.Contains(dead.LastError, "502") {
+		t.Errorf("expected last error to record status, got %q", dead.LastError)
+	}
+
+	ctx := context.Background()
+	letters, err := w.DeadLetters(ctx)
+	if err != nil {
+		t.Fatalf("DeadLetters: %v", err)
+	}
+	if len(letters) != 1 || letters[0].ID != dead.ID {
+		t.Fatalf("expected dead letter %s, got %+v", dead.ID, letters)
+	}
+
+	healthy.Store(true)
+	waitIdle(t, w)
+	if err := w.Redeliver(ctx, dead.ID); err != nil {
+		t.Fatalf("Redeliver: %v", err)
+	}
+	waitForDelivery(t, w, WebhookDelivered)
+	waitIdle(t, w)
+
+	if err := w.Redeliver(ctx, dead.ID); !errors.Is(err, ErrWebhookNotDead) {
+		t.Errorf("expected ErrWebhookNotDead for a delivered record, got %v", err)
+	}
+	if err := w.Redeliver(ctx, "missing"); !errors.Is(err, ErrWebhookNotFound) {
+		t.Errorf("expected ErrWebhookNotFound, got %v", err)
+	}
+}
+
+func TestWebhookDeliveryStoreErrors(t *testing.T) {
+	resetAll(t)
+	New()
+	boom := errors.New("store down")
+	p := NewMemoryStore()
+	store, err := NewStore[WebhookDelivery](p, "webhooks")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	w := NewWebhooks(store, testEndpoints())
+	t.Cleanup(func() { _ = w.Close(context.Background()) })
+
+	p.Fail("get", boom)
+	if _, err := w.Delivery(context.Background(), "any"); !errors.Is(err, boom) || errors.Is(err, ErrWebhookNotFound) {
+		t.Errorf("expected store error passed through, got %v", err)
+	}
+}
+
+func TestWebhookResumesPendingDeliveries(t *testing.T) {
+	resetAll(t)
+	New()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		<-release
+		rw.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	})
+
+	w := newTestWebhooks(t, 3, WebhookEndpoint{ID: "ep_1", URL: srv.URL})
+	ctx := context.Background()
+	// Left pending by a previous process after one failed attempt.
+	stranded := WebhookDelivery{ID: "d1", EndpointID: "ep_1", Signal: "test", Status: WebhookPending, Attempts: 1}
+	if err := w.save(ctx, &stranded); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	pending, err := w.Pending(ctx)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("expected one pending delivery, got %+v, %v", pending, err)
+	}
+
+	if err := w.Resume(ctx); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if err := w.Resume(ctx); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	close(release)
+	d := waitForDelivery(t, w, WebhookDelivered)
+	if d.Attempts != 2 {
+		t.Errorf("expected earlier attempts kept, got %d", d.Attempts)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected an in-flight delivery resumed once, got %d sends", n)
+	}
+}
+
+func TestWebhookRedeliverClaimsDelivery(t *testing.T) {
+	resetAll(t)
+	New()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		<-release
+		rw.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	w := newTestWebhooks(t, 3, WebhookEndpoint{ID: "ep_1", URL: srv.URL})
+	ctx := context.Background()
+	dead := WebhookDelivery{ID: "d1", EndpointID: "ep_1", Signal: "test", Status: WebhookDead, Attempts: 3}
+	if err := w.save(ctx, &dead); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	if err := w.Redeliver(ctx, "d1"); err != nil {
+		t.Fatalf("Redeliver: %v", err)
+	}
+	if err := w.Redeliver(ctx, "d1"); !errors.Is(err, ErrWebhookInFlight) {
+		t.Errorf("expected ErrWebhookInFlight for a concurrent redelivery, got %v", err)
+	}
+	close(release)
+	waitForDelivery(t, w, WebhookDelivered)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected one send, got %d", n)
+	}
+}
+
+func TestWebhookDeliveredRecordsExpire(t *testing.T) {
+	resetAll(t)
+	New()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	store, err := NewStore[WebhookDelivery](NewMemoryStore(), "webhooks")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	w := NewWebhooks(store, testEndpoints(WebhookEndpoint{ID: "ep_1", URL: srv.URL}), WithWebhookRetention(200*time.Millisecond))
+	t.Cleanup(func() { _ = w.Close(context.Background()) })
+	event := NewInfoEvent[testInvoice](capitan.NewSignal("test.webhook.retention", "Invoice retained"))
+	WebhookEvent(w, event, func(context.Context, testInvoice) []string {
+		return []string{"ep_1"}
+	})
+
+	event.Emit(context.Background(), testInvoice{ID: "inv_9"})
+	d := waitForDelivery(t, w, WebhookDelivered)
+	time.Sleep(300 * time.Millisecond)
+	if _, err := w.Delivery(context.Background(), d.ID); !errors.Is(err, ErrWebhookNotFound) {
+		t.Errorf("expected delivered record expired, got %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package sum
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // Required by RFC 6455 for the accept key, not used for security.
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket opcodes from RFC 6455.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// wsGUID is the fixed value appended to the client key during the handshake.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxMessage caps the size of a single inbound message.
+const wsMaxMessage = 1 << 20
+
+// errWSClosed indicates the peer closed the connection.
+var errWSClosed = errors.New("websocket closed")
+
+// wsConn is a minimal server-side WebSocket connection.
+// Reads must come from a single goroutine; writes are serialized internally.
+// With idle set, a read that waits longer than idle for a frame fails.
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	idle time.Duration
+	wmu  sync.Mutex
+}
+
+// wsUpgrade performs the opening handshake and hijacks the connection.
+// Browser requests must come from the request's own host or one of origins;
+// requests without an Origin header, from non-browser clients, are allowed.
+func wsUpgrade(w http.ResponseWriter, r *http.Request, origins []string) (*wsConn, error) {
+	if !wsOriginAllowed(r, origins) {
+		http.Error(w, "websocket origin not allowed", http.StatusForbidden)
+		return nil, errors.New("websocket: origin not allowed")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing websocket key", http.StatusBadRequest)
+		return nil, errors.New("websocket: missing key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket unsupported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: hijack: %w", err)
+	}
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", wsAcceptKey(key))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: handshake: %w", err)
+	}
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+// wsOriginAllowed reports whether the request's Origin matches its Host or
+// one of origins. An origin of "*" allows every origin.
+func wsOriginAllowed(r *http.Request, origins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, o := range origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// wsAcceptKey derives the Sec-WebSocket-Accept value for a client key.
+func wsAcceptKey(key string) string {
+	h := sha1.New() //nolint:gosec // See import comment.
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContains reports whether a comma-separated header includes token.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next complete data message, answering pings and
+// reassembling fragments along the way. Continuations without a starting
+// frame, and new data frames mid-message, fail with 1002; text messages
+// that are not valid UTF-8 fail with 1007.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var msg []byte
+	var first byte
+	started := false
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case op == wsContinuation && !started:
+			return nil, c.protocolError("continuation without a starting frame")
+		case (op == wsText || op == wsBinary) && started:
+			return nil, c.protocolError("new message before the previous one finished")
+		case op == wsText || op == wsBinary:
+			first, started = op, true
+		}
+		switch op {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+		case wsPong:
+		case wsClose:
+			_ = c.writeFrame(wsClose, payload)
+			return nil, errWSClosed
+		case wsText, wsBinary, wsContinuation:
+			if len(msg)+len(payload) > wsMaxMessage {
+				_ = c.writeFrame(wsClose, wsCloseCode(1009))
+				return nil, errors.New("websocket: message too large")
+			}
+			msg = append(msg, payload...)
+			if !fin {
+				continue
+			}
+			if first == wsText && !utf8.Valid(msg) {
+				_ = c.writeFrame(wsClose, wsCloseCode(1007))
+				return nil, errors.New("websocket: invalid UTF-8 in text message")
+			}
+			return msg, nil
+		default:
+			return nil, c.protocolError(fmt.Sprintf("unknown opcode %d", op))
+		}
+	}
+}
+
+// readFrame reads a single frame, unmasking the payload. Frames with
+// reserved bits set, since no extension is negotiated, and fragmented or
+// oversized control frames fail the connection with 1002.
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	if c.idle > 0 {
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.idle))
+	}
+	var head [2]byte
+	if _, err = io.ReadFull(c.rw, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = head[0]&0x80 != 0
+	op = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, c.protocolError("reserved bits set")
+	}
+	if op&0x8 != 0 && (!fin || length > 125) {
+		return false, 0, nil, c.protocolError("invalid control frame")
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxMessage {
+		return false, 0, nil, errors.New("websocket: frame too large")
+	}
+	if !masked {
+		return false, 0, nil, errors.New("websocket: client frame not masked")
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.rw, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.rw, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// protocolError closes the connection with 1002 and returns the reason.
+func (c *wsConn) protocolError(reason string) error {
+	_ = c.writeFrame(wsClose, wsCloseCode(1002))
+	return errors.New("websocket: " + reason)
+}
+
+// WriteText sends a text message.
+func (c *wsConn) WriteText(data []byte) error {
+	return c.writeFrame(wsText, data)
+}
+
+// writeFrame sends a single unmasked, final frame.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	var head [10]byte
+	head[0] = 0x80 | op
+	n := 2
+	switch l := len(payload); {
+	case l < 126:
+		head[1] = byte(l)
+	case l <= 0xFFFF:
+		head[1] = 126
+		binary.BigEndian.PutUint16(head[2:], uint16(l))
+		n = 4
+	default:
+		head[1] = 127
+		binary.BigEndian.PutUint64(head[2:], uint64(l))
+		n = 10
+	}
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.rw.Write(head[:n]); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// Ping sends a ping; the peer's pong counts as activity for the idle timeout.
+func (c *wsConn) Ping() error {
+	return c.writeFrame(wsPing, nil)
+}
+
+// Close sends a normal close frame and releases the connection.
+func (c *wsConn) Close() error {
+	_ = c.writeFrame(wsClose, wsCloseCode(1000))
+	return c.conn.Close()
+}
+
+// wsCloseCode encodes a close status code as a frame payload.
+func wsCloseCode(code uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, code)
+	return b
+}